@@ -0,0 +1,21 @@
+package audit
+
+import "context"
+
+// Anonymous is the actor of requests nobody authenticated
+const Anonymous = "anonymous"
+
+type actorKey struct{}
+
+// WithActor returns ctx carrying the actor its changes are attributed to
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the actor of ctx, Anonymous if none was set
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return Anonymous
+}
@@ -0,0 +1,203 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"slices"
+	"time"
+
+	"sample/database"
+	"sample/logger"
+	"sample/repository"
+)
+
+// Actions of an Entry
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionMerge  = "merge"  // a customer merged into another, see customerservice.Service.Merge
+	ActionReview = "review" // an identification verified or rejected, see customerservice.Service.Review
+	ActionMove   = "move"   // a stock movement of a product, see merchantservice.ProductService.Move
+)
+
+// Entry is one change to a resource, stored in the audit_log table
+type Entry struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Actor      string    `gorm:"size:100;index;not null" json:"actor"`
+	RequestID  string    `gorm:"size:64" json:"request_id"`
+	Resource   string    `gorm:"size:50;not null;index:idx_audit_log_resource" json:"resource"`
+	ResourceID uint      `gorm:"not null;index:idx_audit_log_resource" json:"resource_id"`
+	Action     string    `gorm:"size:10;not null" json:"action"`
+	Diff       Diff      `gorm:"type:text" json:"diff"`
+	CreatedAt  time.Time `gorm:"not null;index" json:"created_at"`
+}
+
+func (Entry) TableName() string {
+	return "audit_log"
+}
+
+// Change is the value of a field before and after a write, null when absent
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// Diff holds the changed fields by JSON name, stored as JSON text
+type Diff map[string]Change
+
+func (d Diff) Value() (driver.Value, error) {
+	b, err := json.Marshal(d)
+	return string(b), err
+}
+
+func (d *Diff) Scan(value any) error {
+	switch v := value.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	case []byte:
+		return json.Unmarshal(v, d)
+	}
+	return errors.New("audit.Diff: unsupported column type")
+}
+
+// Compare returns the fields whose JSON value differs between before and
+// after. A create compares with the zero value of the model, so that unset
+// fields are left out, and a delete with nil, so that all of them are kept.
+func Compare(before, after any) (Diff, error) {
+	a, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	b, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := Diff{}
+	for name, value := range a {
+		if !reflect.DeepEqual(value, b[name]) {
+			diff[name] = Change{Before: value, After: b[name]}
+		}
+	}
+	for name, value := range b {
+		if _, ok := a[name]; !ok {
+			diff[name] = Change{Before: nil, After: value}
+		}
+	}
+	// The ID, actor and time are in the entry itself
+	delete(diff, "id")
+	for _, name := range StampFields {
+		delete(diff, name)
+	}
+	diff.redact()
+	return diff, nil
+}
+
+// redact replaces the values of the database.SensitiveColumns, at any depth
+// of the fields, e.g. the ID numbers of the identifications of a customer.
+// The diff still tells they changed.
+func (d Diff) redact() {
+	for name, change := range d {
+		if slices.Contains(database.SensitiveColumns, name) {
+			d[name] = Change{Before: redactValue(change.Before), After: redactValue(change.After)}
+			continue
+		}
+		d[name] = Change{Before: redactNested(change.Before), After: redactNested(change.After)}
+	}
+}
+
+// redactNested redacts the sensitive fields of the objects in v
+func redactNested(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for name, value := range v {
+			if slices.Contains(database.SensitiveColumns, name) {
+				v[name] = redactValue(value)
+			} else {
+				v[name] = redactNested(value)
+			}
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = redactNested(value)
+		}
+	}
+	return v
+}
+
+// redactValue keeps an absent or empty value as it is, so that the diff
+// tells when one was set or cleared
+func redactValue(v any) any {
+	if v == nil || v == "" {
+		return v
+	}
+	return database.Redacted
+}
+
+func fields(item any) (map[string]any, error) {
+	b, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	return m, json.Unmarshal(b, &m)
+}
+
+// Log records the entries through a repository, so they are written in the
+// transaction of the change, see repository.Transactor
+type Log struct {
+	Entries repository.Repository[Entry]
+	Now     func() time.Time
+}
+
+// NewLog creates a Log storing to entries
+func NewLog(entries repository.Repository[Entry], now func() time.Time) *Log {
+	return &Log{Entries: entries, Now: now}
+}
+
+// Record stores the change of a resource by the actor and request of ctx.
+// An update that changed nothing is not recorded.
+func (l *Log) Record(ctx context.Context, resource string, id uint, action string, before, after any) error {
+	diff, err := Compare(before, after)
+	if err != nil {
+		return err
+	}
+	if action == ActionUpdate && len(diff) == 0 {
+		return nil
+	}
+
+	return l.Entries.Create(ctx, &Entry{
+		Actor:      Actor(ctx),
+		RequestID:  logger.RequestID(ctx),
+		Resource:   resource,
+		ResourceID: id,
+		Action:     action,
+		Diff:       diff,
+		CreatedAt:  l.Now(),
+	})
+}
+
+// List returns the entries of one resource, newest first. The entries
+// recorded before the sensitive values were redacted are redacted too.
+func (l *Log) List(ctx context.Context, resource string, id uint, limit, offset int) ([]Entry, error) {
+	entries, err := l.Entries.List(ctx, repository.ListOptions{
+		Filters: []repository.Filter{
+			{Field: "Resource", Op: repository.OpEq, Value: resource},
+			{Field: "ResourceID", Op: repository.OpEq, Value: id},
+		},
+		Sort:   []repository.Sort{{Field: "ID", Desc: true}},
+		Limit:  limit,
+		Offset: offset,
+	})
+	for _, entry := range entries {
+		entry.Diff.redact()
+	}
+	return entries, err
+}
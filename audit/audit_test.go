@@ -0,0 +1,77 @@
+package audit_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"sample/audit"
+	"sample/database"
+	"sample/servertest"
+)
+
+func TestAuditTrail(t *testing.T) {
+	s := servertest.New(t)
+	customer := map[string]any{
+		"full_name": "Juan", "last_name": "Dela Cruz", "date_of_birth": "1990-01-02T00:00:00Z",
+		"taxpayer_identification_number": "123-456-789",
+		"identification": []map[string]any{
+			{"id_type": "passport", "id_number": "P1234567A", "id_expiry_date": "2099-01-01T00:00:00Z"},
+		},
+	}
+	status, body := servertest.Do(t, s.App, "POST", "/api/customer", customer, "X-Actor", "juan")
+	if status != 200 {
+		t.Fatalf("create: %d %v", status, body)
+	}
+	id := body["data"].(float64)
+	path := fmt.Sprintf("/api/customer/%.0f", id)
+	if status, body := servertest.Do(t, s.App, "PATCH", path, map[string]any{"full_name": "Juan Carlos"}, "X-Actor", "maria"); status != 200 {
+		t.Fatalf("patch: %d %v", status, body)
+	}
+
+	trail := fmt.Sprintf("/api/audit?resource=customer&id=%.0f", id)
+	if status, _ := servertest.Do(t, s.App, "GET", trail, nil); status != 401 {
+		t.Errorf("audit log read anonymously = %d, want 401", status)
+	}
+	if status, _ := servertest.Do(t, s.App, "GET", trail, nil, "X-Actor", "juan"); status != 403 {
+		t.Errorf("audit log read by juan = %d, want 403", status)
+	}
+	status, body = servertest.Do(t, s.App, "GET", trail, nil, "X-Actor", "auditor")
+	if status != 200 {
+		t.Fatalf("audit log read by the auditor: %d %v", status, body)
+	}
+
+	entries := body["data"].([]any)
+	if len(entries) != 2 {
+		t.Fatalf("entries = %v, want the update then the create", entries)
+	}
+	update, create := entries[0].(map[string]any), entries[1].(map[string]any)
+	if update["action"] != audit.ActionUpdate || update["actor"] != "maria" || update["request_id"] == "" {
+		t.Errorf("update entry = %v, want an update by maria with its request ID", update)
+	}
+	if diff := update["diff"].(map[string]any); len(diff) != 1 || fmt.Sprint(diff["full_name"]) != "map[after:Juan Carlos before:Juan]" {
+		t.Errorf("update diff = %v, want only the full name from Juan to Juan Carlos", diff)
+	}
+	if create["action"] != audit.ActionCreate || create["actor"] != "juan" {
+		t.Errorf("create entry = %v, want a create by juan", create)
+	}
+	diff := create["diff"].(map[string]any)
+	if tin := diff["taxpayer_identification_number"].(map[string]any); fmt.Sprint(tin["before"]) != "" || tin["after"] != database.Redacted {
+		t.Errorf("TIN in the diff = %v, want it set and redacted", tin)
+	}
+	identification := diff["identification"].(map[string]any)["after"].([]any)[0].(map[string]any)
+	if identification["id_number"] != database.Redacted || identification["id_type"] != "passport" {
+		t.Errorf("identification in the diff = %v, want its number redacted", identification)
+	}
+
+	// Nor are they stored
+	var stored []string
+	if err := s.DB.Table("audit_log").Pluck("diff", &stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, diff := range stored {
+		if strings.Contains(diff, "123-456-789") || strings.Contains(diff, "P1234567A") {
+			t.Errorf("stored diff %s holds a sensitive value", diff)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package auditcontroller
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"sample/audit"
+	"sample/custom"
+	"sample/response"
+	"sample/script"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxPageSize caps page_size on /api/audit
+const MaxPageSize = 100
+
+// List serves /api/audit?resource=customer&id=1&page=1&page_size=50 with
+// the changes of one resource, newest first
+func List(log *audit.Log, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		resource := c.Query("resource")
+		if resource == "" {
+			return badRequest(c, errors.New("resource is required"))
+		}
+		id, err := custom.ParseID(c.Query("id"))
+		if err != nil {
+			return badRequest(c, errors.New("id must be a positive integer"))
+		}
+		page, err := strconv.Atoi(c.Query("page", "1"))
+		if err != nil || page < 1 {
+			return badRequest(c, errors.New("page must be a positive integer"))
+		}
+		size, err := strconv.Atoi(c.Query("page_size", "50"))
+		if err != nil || size < 1 || size > MaxPageSize {
+			return badRequest(c, errors.New("page_size must be between 1 and "+strconv.Itoa(MaxPageSize)))
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		entries, err := log.List(ctx, resource, uint(id), size, (page-1)*size)
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not retrieve audit log")
+		}
+
+		if len(entries) == 0 {
+			return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+				RetCode: string(response.NotFound),
+				Message: "No resource found",
+				Data:    entries,
+			})
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "success",
+			Data:    entries,
+		})
+	}
+}
+
+func badRequest(c fiber.Ctx, err error) error {
+	return response.Send(c, fiber.StatusBadRequest, response.ErrorModel{
+		RetCode: string(response.BadRequest),
+		Message: "Invalid query",
+		Data:    err.Error(),
+	})
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StampFields are the JSON names of the Stamps fields, filterable and
+// sortable on every resource
+var StampFields = []string{"created_at", "updated_at", "created_by", "updated_by"}
+
+// Stamps are the creation and last update time and actor of a row. Models
+// embed it, InstrumentDB fills it in and keeps clients from writing it.
+type Stamps struct {
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `gorm:"index" json:"updated_at"`
+	CreatedBy string    `gorm:"size:100" json:"created_by"`
+	UpdatedBy string    `gorm:"size:100" json:"updated_by"`
+}
+
+// InstrumentDB registers the callbacks that stamp the models embedding
+// Stamps with the actor of the statement context, see WithActor. Nested
+// associations are stamped too, as GORM saves them with the same context.
+// The Memory repositories do not stamp. Instrumenting db again, e.g. a
+// second server on the same DB, is a no-op.
+func InstrumentDB(db *gorm.DB) error {
+	if db.Callback().Create().Get("audit:stamp_create") != nil {
+		return nil
+	}
+	if err := db.Callback().Create().Before("gorm:create").Register("audit:stamp_create", stampCreate); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("audit:stamp_update", stampUpdate)
+}
+
+func stamped(db *gorm.DB) bool {
+	return db.Error == nil && db.Statement.Schema != nil && db.Statement.Schema.LookUpField("CreatedBy") != nil
+}
+
+func stampCreate(db *gorm.DB) {
+	if !stamped(db) {
+		return
+	}
+	actor, now := Actor(db.Statement.Context), db.NowFunc()
+
+	// Whatever the client sent for these is overwritten
+	db.Statement.SetColumn("CreatedAt", now, true)
+	db.Statement.SetColumn("UpdatedAt", now, true)
+	db.Statement.SetColumn("CreatedBy", actor, true)
+	db.Statement.SetColumn("UpdatedBy", actor, true)
+}
+
+func stampUpdate(db *gorm.DB) {
+	if !stamped(db) {
+		return
+	}
+	stmt := db.Statement
+
+	// The creation stamps never change, GORM sets UpdatedAt itself
+	stmt.Omits = append(stmt.Omits, "CreatedAt", "CreatedBy")
+	stmt.SetColumn("UpdatedBy", Actor(stmt.Context), true)
+	if len(stmt.Selects) > 0 {
+		stmt.Selects = append(stmt.Selects, "UpdatedBy")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"sample/audit"
+	"sample/backfill"
+	"sample/config"
+	"sample/server"
+)
+
+// runBackfill normalises the phone numbers and emails of the existing
+// contacts, see backfill.Contacts:
+//
+//	sample backfill [-dry-run] [-batch-size 500]
+//
+// The exit code is 0 when every row was normalised, 2 when some were left
+// as they were and 1 when the backfill could not run.
+func runBackfill(ctx context.Context, cfg config.Config, log *slog.Logger, args []string) int {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "report what would change without writing")
+	batchSize := flags.Int("batch-size", backfill.DefaultBatchSize, "rows read at a time")
+	actor := flags.String("actor", "backfill", "who the updated rows are stamped with")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if flags.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: sample backfill [flags]")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	srv, err := server.New(ctx, server.WithConfig(cfg), server.WithLogger(log), server.WithoutJobs())
+	if err != nil {
+		log.Error("backfill failed", "error", err)
+		return 1
+	}
+	defer srv.Shutdown(context.Background())
+
+	report, err := backfill.Contacts(audit.WithActor(ctx, *actor), srv.DB, *batchSize, *dryRun)
+	if report != nil {
+		summary, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(summary))
+	}
+	if err != nil {
+		log.Error("backfill failed", "error", err)
+		return 1
+	}
+	if len(report.Problems) > 0 {
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,169 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sample/audit"
+	"sample/utils"
+
+	"gorm.io/gorm"
+)
+
+// DefaultBatchSize is the number of rows read at a time
+const DefaultBatchSize = 500
+
+// Problem is a value the backfill could not normalise, left as it was
+type Problem struct {
+	Table   string `json:"table"`
+	ID      uint   `json:"id"`
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// Report is what a backfill changed, or would change on a dry run
+type Report struct {
+	DryRun   bool      `json:"dry_run"`
+	Scanned  int       `json:"scanned"`
+	Updated  int       `json:"updated"`
+	Problems []Problem `json:"problems"`
+}
+
+// contactTable is a table of contacts: its phone number and type columns and
+// its email column
+type contactTable struct {
+	name   string
+	phones [][2]string
+	email  string
+}
+
+var contactTables = []contactTable{
+	{
+		name: "contacts",
+		phones: [][2]string{
+			{"owner_phone_number", "owner_phone_type"},
+			{"owner_other_phone_number", "owner_other_phone_type"},
+		},
+		email: "email",
+	},
+	{
+		name:   "contact_merchants",
+		phones: [][2]string{{"merchant_phone_number", "merchant_phone_type"}},
+		email:  "merchant_email",
+	},
+}
+
+// Contacts rewrites the phone numbers of the customer and merchant contacts
+// in E.164 with their types and their emails in lowercase, as the models do
+// on every write since. Each row is updated on its own, the invalid numbers
+// and the emails another row already has are reported and left as they
+// were.
+func Contacts(ctx context.Context, db *gorm.DB, batchSize int, dryRun bool) (*Report, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	report := &Report{DryRun: dryRun, Problems: []Problem{}}
+	db = db.WithContext(ctx)
+	for _, table := range contactTables {
+		if err := table.backfill(ctx, db, batchSize, report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (t contactTable) backfill(ctx context.Context, db *gorm.DB, batchSize int, report *Report) error {
+	columns := []string{"id", t.email}
+	for _, phone := range t.phones {
+		columns = append(columns, phone[0], phone[1])
+	}
+
+	var last uint
+	for {
+		var rows []map[string]any
+		err := db.Table(t.name).Select(columns).Where("id > ?", last).Order("id").Limit(batchSize).Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		for _, row := range rows {
+			last = id(row["id"])
+			report.Scanned++
+			changes := t.changes(row, report)
+			if len(changes) == 0 {
+				continue
+			}
+			if report.DryRun {
+				report.Updated++
+				continue
+			}
+			changes["updated_at"] = db.NowFunc()
+			changes["updated_by"] = audit.Actor(ctx)
+			err := db.Table(t.name).Where("id = ?", last).Updates(changes).Error
+			switch {
+			case utils.IsUniqueConstraintError(err):
+				report.Problems = append(report.Problems, Problem{
+					Table: t.name, ID: last, Field: t.email, Value: text(row[t.email]),
+					Message: "another row has this email",
+				})
+			case err != nil:
+				return fmt.Errorf("%s %d: %w", t.name, last, err)
+			default:
+				report.Updated++
+			}
+		}
+	}
+}
+
+// changes are the columns of row to update, invalid numbers are reported
+func (t contactTable) changes(row map[string]any, report *Report) map[string]any {
+	changes := map[string]any{}
+	set := func(column, value string) {
+		if text(row[column]) != value {
+			changes[column] = value
+		}
+	}
+
+	set(t.email, utils.NormalizeEmail(text(row[t.email])))
+	for _, phone := range t.phones {
+		number := strings.TrimSpace(text(row[phone[0]]))
+		if number == "" {
+			set(phone[1], "")
+			continue
+		}
+		normalized, ok := utils.NormalizePhone(number)
+		if !ok {
+			report.Problems = append(report.Problems, Problem{
+				Table: t.name, ID: id(row["id"]), Field: phone[0], Value: number,
+				Message: "is not a phone number",
+			})
+			continue
+		}
+		set(phone[0], normalized)
+		set(phone[1], string(utils.PhoneTypeOf(normalized)))
+	}
+	return changes
+}
+
+// text and id read the columns of a row whatever the type the driver gives
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+func id(v any) uint {
+	var n uint
+	fmt.Sscan(text(v), &n)
+	return n
+}
@@ -0,0 +1,200 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"reflect"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The media types the API reads and writes. The JSON document is the
+// reference, XML and MessagePack are converted from and to it, so every
+// format has the same field names.
+const (
+	JSON    = "application/json"
+	XML     = "application/xml"
+	MsgPack = "application/msgpack"
+)
+
+// Offers are the media types of responses, the first is the default
+var Offers = []string{JSON, XML, MsgPack}
+
+// Accepted are Offers and their aliases, for negotiating the Accept header
+var Accepted = []string{JSON, XML, MsgPack, "text/xml", "application/x-msgpack", "application/vnd.msgpack"}
+
+// ErrUnsupportedMediaType is returned for a request body the API cannot read
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// aliases are the other names clients use for the media types
+var aliases = map[string]string{
+	JSON:                      JSON,
+	XML:                       XML,
+	"text/xml":                XML,
+	MsgPack:                   MsgPack,
+	"application/x-msgpack":   MsgPack,
+	"application/vnd.msgpack": MsgPack,
+}
+
+// MediaType returns which of JSON, XML and MsgPack a Content-Type header is.
+// An empty header is taken as JSON.
+func MediaType(contentType string) (string, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return JSON, nil
+	}
+	name, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedMediaType, contentType)
+	}
+	if media, ok := aliases[name]; ok {
+		return media, nil
+	}
+	return "", fmt.Errorf("%w: %s, use %s", ErrUnsupportedMediaType, name, strings.Join(Offers, ", "))
+}
+
+// Marshal encodes v in the media type, or its alias, from its JSON document
+func Marshal(media string, v any) ([]byte, error) {
+	if canonical, ok := aliases[media]; ok {
+		media = canonical
+	}
+	doc, err := json.Marshal(v)
+	if err != nil || media == JSON {
+		return doc, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	tree, err := readValue(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch media {
+	case XML:
+		buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+		writeXML(&buf, "response", tree)
+	case MsgPack:
+		if err := writeMsgPack(msgpack.NewEncoder(&buf), tree); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("cannot encode %s", media)
+	}
+	return buf.Bytes(), nil
+}
+
+// ToJSON converts a request body of the media type to JSON. XML carries no
+// types, its elements are read as the fields of t, e.g. a number for an int.
+func ToJSON(media string, body []byte, t reflect.Type) ([]byte, error) {
+	switch media {
+	case JSON:
+		return body, nil
+	case XML:
+		return xmlToJSON(body, t)
+	case MsgPack:
+		v, err := msgpack.NewDecoder(bytes.NewReader(body)).DecodeInterface()
+		if err != nil {
+			return nil, fmt.Errorf("invalid MessagePack: %w", err)
+		}
+		doc, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MessagePack: %w", err)
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, media)
+}
+
+// object is a JSON object that keeps the order of its keys
+type object struct {
+	keys   []string
+	values []any
+}
+
+// readValue reads a JSON value into objects, []any, json.Number, string,
+// bool or nil
+func readValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		obj := &object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values = append(obj.values, value)
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			value, err := readValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+func writeMsgPack(enc *msgpack.Encoder, v any) error {
+	switch v := v.(type) {
+	case *object:
+		if err := enc.EncodeMapLen(len(v.keys)); err != nil {
+			return err
+		}
+		for i, key := range v.keys {
+			if err := enc.EncodeString(key); err != nil {
+				return err
+			}
+			if err := writeMsgPack(enc, v.values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		if err := enc.EncodeArrayLen(len(v)); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := writeMsgPack(enc, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return enc.EncodeInt(n)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		return enc.EncodeFloat64(f)
+	case string:
+		return enc.EncodeString(v)
+	case bool:
+		return enc.EncodeBool(v)
+	case nil:
+		return enc.EncodeNil()
+	}
+	return fmt.Errorf("cannot encode %T", v)
+}
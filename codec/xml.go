@@ -0,0 +1,238 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// writeXML writes v as the element name. Objects are elements of their
+// keys, lists elements of item and null an element with nil="true". Keys
+// that are not XML names are written as <entry key="...">.
+func writeXML(buf *bytes.Buffer, name string, v any) {
+	end := name
+	if validName(name) {
+		buf.WriteString("<" + name)
+	} else {
+		buf.WriteString(`<entry key="`)
+		xml.EscapeText(buf, []byte(name))
+		buf.WriteString(`"`)
+		end = "entry"
+	}
+
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString(` nil="true"/>`)
+		return
+	case *object:
+		buf.WriteString(">")
+		for i, key := range v.keys {
+			writeXML(buf, key, v.values[i])
+		}
+	case []any:
+		buf.WriteString(">")
+		for _, item := range v {
+			writeXML(buf, "item", item)
+		}
+	default:
+		buf.WriteString(">")
+		xml.EscapeText(buf, []byte(fmt.Sprint(v)))
+	}
+	buf.WriteString("</" + end + ">")
+}
+
+func validName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// xmlNode is an element of a request body
+type xmlNode struct {
+	name     string
+	null     bool
+	text     strings.Builder
+	children []*xmlNode
+}
+
+func parseXML(body []byte) (*xmlNode, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: tok.Name.Local}
+			for _, attr := range tok.Attr {
+				switch {
+				case attr.Name.Local == "key" && n.name == "entry":
+					n.name = attr.Value
+				case attr.Name.Local == "nil" && attr.Value == "true":
+					n.null = true
+				}
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(tok)
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("no root element")
+	}
+	return root, nil
+}
+
+func xmlToJSON(body []byte, t reflect.Type) ([]byte, error) {
+	root, err := parseXML(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XML: %w", err)
+	}
+	v, err := fromXML(root, t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+var jsonUnmarshaler = reflect.TypeFor[json.Unmarshaler]()
+
+// fromXML reads an element as a value of type t for json.Marshal. Elements
+// that are not fields of t are kept, untyped, for the caller to reject.
+func fromXML(n *xmlNode, t reflect.Type) (any, error) {
+	if n.null {
+		return nil, nil
+	}
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	text := strings.TrimSpace(n.text.String())
+	if t == nil || t.Kind() == reflect.Interface {
+		if len(n.children) == 0 {
+			return text, nil
+		}
+		obj := map[string]any{}
+		for _, child := range n.children {
+			obj[child.name], _ = fromXML(child, nil)
+		}
+		return obj, nil
+	}
+	// Types reading themselves from JSON, e.g. time.Time, take the text
+	if reflect.PointerTo(t).Implements(jsonUnmarshaler) {
+		return text, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		fields := jsonFields(t)
+		obj := map[string]any{}
+		for _, child := range n.children {
+			v, err := fromXML(child, fields[child.name])
+			if err != nil {
+				return nil, err
+			}
+			obj[child.name] = v
+		}
+		return obj, nil
+	case reflect.Map:
+		obj := map[string]any{}
+		for _, child := range n.children {
+			v, err := fromXML(child, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			obj[child.name] = v
+		}
+		return obj, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return text, nil
+		}
+		list := []any{}
+		for _, child := range n.children {
+			v, err := fromXML(child, t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case reflect.Bool:
+		if text == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a boolean", n.name, text)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if text == "" {
+			return nil, nil
+		}
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return nil, fmt.Errorf("%s: %q is not a number", n.name, text)
+		}
+		return json.Number(text), nil
+	}
+	return text, nil
+}
+
+// jsonFields are the types of the fields of a struct by JSON name, including
+// the fields of embedded structs as encoding/json does
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+		case f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct:
+			for name, ft := range jsonFields(f.Type) {
+				fields[name] = ft
+			}
+		case name == "":
+			fields[f.Name] = f.Type
+		default:
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
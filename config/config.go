@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the runtime settings, read from the environment
+type Config struct {
+	Port     string // PORT
+	LogLevel string // LOG_LEVEL: debug, info, warn or error
+
+	ProxyHeader    string   // PROXY_HEADER: header the trusted proxies put the client IP in, e.g. X-Real-IP, empty for the peer address
+	TrustedProxies []string // TRUSTED_PROXIES: comma-separated IPs or CIDRs of the proxies PROXY_HEADER is read from, none when empty
+
+	ShutdownTimeout time.Duration // SHUTDOWN_TIMEOUT: how long in-flight requests get to finish on SIGTERM
+	DrainDelay      time.Duration // SHUTDOWN_DRAIN_DELAY: how long /ready fails before the listener closes on SIGTERM
+	BodyLimit       int           // BODY_LIMIT: largest request body in bytes, imported spreadsheets included
+
+	DBDriver   string // DB_DRIVER: postgres, or sqlite with DB_NAME the path of the database file
+	DBHost     string // DB_HOST
+	DBPort     int    // DB_PORT
+	DBUser     string // DB_USER
+	DBPassword string // DB_PASSWORD
+	DBName     string // DB_NAME
+	DBSSLMode  string // DB_SSLMODE
+
+	DBMaxOpenConns    int           // DB_MAX_OPEN_CONNS: 0 means unlimited
+	DBMaxIdleConns    int           // DB_MAX_IDLE_CONNS
+	DBConnMaxLifetime time.Duration // DB_CONN_MAX_LIFETIME: 0 means connections are reused forever
+	DBConnMaxIdleTime time.Duration // DB_CONN_MAX_IDLE_TIME
+	DBConnectRetries  int           // DB_CONNECT_RETRIES: attempts before giving up on the first connection
+	DBConnectBackoff  time.Duration // DB_CONNECT_BACKOFF: wait before the first retry, doubled on each attempt
+
+	StatementTimeout   time.Duration // DB_STATEMENT_TIMEOUT: default bound for the queries of one request
+	SlowQueryThreshold time.Duration // DB_SLOW_QUERY: queries slower than this are logged as warnings
+	QueryCountWarn     int           // DB_QUERY_COUNT_WARN: requests running more queries are flagged in the access log
+	AutoMigrate        bool          // DB_AUTO_MIGRATE: apply pending migrations on start
+
+	MetricsRefresh   time.Duration // METRICS_REFRESH: how often the business gauges are recounted, the default when not positive
+	IDExpiryInterval time.Duration // ID_EXPIRY_INTERVAL: how often identifications past their expiry date are marked expired, 0 to never
+
+	ServiceName     string // SERVICE_NAME: reported on traces
+	TracingExporter string // TRACING_EXPORTER: otlp, stdout or none
+
+	ActorHeader  string   // ACTOR_HEADER: header the authenticating proxy puts the user in, empty to ignore
+	AuditReaders []string // AUDIT_READERS: comma-separated actors allowed to read /api/audit, nobody when empty
+
+	GeoDataset string // GEO_DATASET: complete PSGC CSV file addresses are checked against, empty for the bundled excerpt, which keeps the names it does not list
+
+	DocumentDir        string        // DOCUMENT_DIR: directory the identification scans are stored in
+	DocumentMaxSize    int           // DOCUMENT_MAX_SIZE: largest scan in bytes, at most BODY_LIMIT
+	DocumentURLTTL     time.Duration // DOCUMENT_URL_TTL: how long a signed download URL works
+	DocumentSigningKey string        // DOCUMENT_SIGNING_KEY: secret of the download URLs, the same on every replica. Empty for a random one, whose URLs break on restart and on the other replicas
+}
+
+// Load reads the configuration from the environment, falling back to defaults
+func Load() Config {
+	return Config{
+		Port:     getEnv("PORT", "3000"),
+		LogLevel: getEnv("LOG_LEVEL", "info"),
+
+		ProxyHeader:    getEnv("PROXY_HEADER", ""),
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+
+		ShutdownTimeout: getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second),
+		DrainDelay:      getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		BodyLimit:       getEnvInt("BODY_LIMIT", 16<<20),
+
+		DBDriver:   getEnv("DB_DRIVER", "postgres"),
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnvInt("DB_PORT", 5432),
+		DBUser:     getEnv("DB_USER", "postgres"),
+		DBPassword: getEnv("DB_PASSWORD", "postgres"),
+		DBName:     getEnv("DB_NAME", "Sample"),
+		DBSSLMode:  getEnv("DB_SSLMODE", "disable"),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", 10),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+		DBConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+		DBConnectRetries:  getEnvInt("DB_CONNECT_RETRIES", 5),
+		DBConnectBackoff:  getEnvDuration("DB_CONNECT_BACKOFF", time.Second),
+
+		StatementTimeout:   getEnvDuration("DB_STATEMENT_TIMEOUT", 10*time.Second),
+		SlowQueryThreshold: getEnvDuration("DB_SLOW_QUERY", 200*time.Millisecond),
+		QueryCountWarn:     getEnvInt("DB_QUERY_COUNT_WARN", 25),
+		AutoMigrate:        getEnvBool("DB_AUTO_MIGRATE", true),
+
+		MetricsRefresh:   getEnvPositiveDuration("METRICS_REFRESH", time.Minute),
+		IDExpiryInterval: getEnvDuration("ID_EXPIRY_INTERVAL", time.Hour),
+
+		ServiceName:     getEnv("SERVICE_NAME", "sample"),
+		TracingExporter: getEnv("TRACING_EXPORTER", "none"),
+
+		ActorHeader:  getEnv("ACTOR_HEADER", ""),
+		AuditReaders: getEnvList("AUDIT_READERS"),
+
+		GeoDataset: getEnv("GEO_DATASET", ""),
+
+		DocumentDir:        getEnv("DOCUMENT_DIR", "documents"),
+		DocumentMaxSize:    getEnvInt("DOCUMENT_MAX_SIZE", 10<<20),
+		DocumentURLTTL:     getEnvDuration("DOCUMENT_URL_TTL", 15*time.Minute),
+		DocumentSigningKey: getEnv("DOCUMENT_SIGNING_KEY", ""),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvList reads a comma-separated list, leaving out the blank items
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getEnvPositiveDuration is getEnvDuration for the intervals of tickers,
+// which cannot be zero or negative
+func getEnvPositiveDuration(key string, fallback time.Duration) time.Duration {
+	if value := getEnvDuration(key, fallback); value > 0 {
+		return value
+	}
+	return fallback
+}
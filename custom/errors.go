@@ -0,0 +1,42 @@
+package custom
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors shared by the repositories and services, mapped to HTTP codes by the script package
+var (
+	ErrNotFound  = errors.New("resource not found")
+	ErrDuplicate = errors.New("duplicate resource")
+)
+
+// ValidationError reports a field that breaks a business rule
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// NewValidationError creates a ValidationError for field
+func NewValidationError(field, message string) *ValidationError {
+	return &ValidationError{Field: field, Message: message}
+}
+
+// ConflictError aborts a write that the current state of the data does not
+// allow, e.g. deleting a merchant whose products are not delivered yet
+type ConflictError struct {
+	Message string `json:"message"`
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+// NewConflictError creates a ConflictError
+func NewConflictError(message string) *ConflictError {
+	return &ConflictError{Message: message}
+}
@@ -1,6 +1,10 @@
 package custom
 
 import (
+	"strconv"
+
+	"sample/response"
+
 	"github.com/gofiber/fiber/v3"
 )
 
@@ -18,7 +22,11 @@ func NewHttpError(message string, code int) *HttpError {
 	}
 }
 
-// SendErrorResponse sends a JSON error response.
+// SendErrorResponse sends an error response in the format the client
+// accepts, see response.Send.
 func SendErrorResponse(c fiber.Ctx, err *HttpError) error {
-	return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
+	return response.Send(c, err.Code, response.ErrorModel{
+		RetCode: strconv.Itoa(err.Code),
+		Message: err.Message,
+	})
 }
@@ -1,44 +1,37 @@
 package customercontroller
 
 import (
+	"sample/audit"
 	customermodel "sample/customer/model"
-	// "sample/response"
+	customerservice "sample/customer/service"
+	merchantcontroller "sample/merchant/controller"
+	merchantservice "sample/merchant/service"
 	"sample/script"
-
-	"github.com/gofiber/fiber/v3"
-	"gorm.io/gorm"
 )
 
-func Createcustomer(db *gorm.DB) fiber.Handler {
-	return func(c fiber.Ctx) error {
-		// Main model
-		var customer customermodel.Customer
-		var Addresses customermodel.Address
-		var Identifications customermodel.Identification
-		var Contacts customermodel.Contact
-
-		// Use the generic function to create the person and related resources
-		return script.CreateResource(db, &customer,
-			&Addresses,
-			&Identifications,
-			&Contacts)(c)
+// Resource defines the /api/customer endpoints, with the merchants of a
+// customer nested under /:id/merchant. GET /:id of a merged customer
+// redirects to the one it was merged into.
+func Resource(customers *customerservice.Service, merchants *merchantservice.MerchantService, products *merchantservice.ProductService) script.Resource[customermodel.Customer] {
+	return script.Resource[customermodel.Customer]{
+		Name:       "customer",
+		Importable: true,
+		Service:    customers,
+		Moved:      customers.Redirect,
+		Includes: map[string]string{
+			"address":                   "Addresses",
+			"identification":            "Identifications",
+			"contact":                   "Contacts",
+			"merchant":                  "Merchant",
+			"merchant.product":          "Merchant.Product",
+			"merchant.contact_merchant": "Merchant.ContactMerchant",
+			"merchant.address_merchant": "Merchant.AddressMerchant",
+		},
+		DefaultIncludes: []string{"address", "identification", "contact", "merchant", "merchant.product", "merchant.contact_merchant", "merchant.address_merchant"},
+		Filters:         append([]string{"id", "title", "full_name", "last_name", "owner_gender", "date_of_birth", "place_of_birth", "job", "taxpayer_identification_number"}, audit.StampFields...),
+		Sort:            append([]string{"id", "full_name", "last_name", "date_of_birth"}, audit.StampFields...),
+		Children: []script.Child{
+			script.Nested("merchant", "customer_id", merchantcontroller.MerchantResource(merchants, products)),
+		},
 	}
 }
-
-func GetAllcustomers(db *gorm.DB) fiber.Handler {
-	return script.GetAllResources[customermodel.Customer](db, []string{"Addresses", "Identifications", "Contacts", "Merchant", "Merchant.Product", "Merchant.ContactMerchant", "Merchant.AddressMerchant"} )
-}
-
-
-func GetcustomerByID(db *gorm.DB) fiber.Handler {
-	return script.GetResourceByID[customermodel.Customer](db, []string{"Addresses", "Identifications", "Contacts", "Merchant", "Merchant.Product", "Merchant.ContactMerchant", "Merchant.AddressMerchant"})
-}
-
-func Updatecustomer(db *gorm.DB) fiber.Handler {
-	var customer customermodel.Customer
-	return script.UpdateResource[customermodel.Customer](db, &customer)
-}
-
-func Deletecustomer(db *gorm.DB) fiber.Handler {
-	return script.DeleteResource[customermodel.Customer](db)
-}
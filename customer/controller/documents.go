@@ -0,0 +1,251 @@
+package customercontroller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"mime"
+	"strconv"
+	"time"
+
+	"sample/audit"
+	"sample/custom"
+	customermodel "sample/customer/model"
+	customerservice "sample/customer/service"
+	"sample/repository"
+	"sample/response"
+	"sample/script"
+	"sample/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Downloads signs the URLs the documents are downloaded from, valid for TTL
+type Downloads struct {
+	Signer *storage.Signer
+	TTL    time.Duration
+}
+
+// DocumentView is a document with its signed download URL
+type DocumentView struct {
+	customermodel.Document
+	URL          string    `json:"url"`
+	URLExpiresAt time.Time `json:"url_expires_at"`
+}
+
+// ContentPath is the path of the content of the document id, see DocumentContent
+func ContentPath(id uint) string {
+	return "/api/documents/" + strconv.FormatUint(uint64(id), 10) + "/content"
+}
+
+func (d Downloads) view(document customermodel.Document) DocumentView {
+	url, expires := d.Signer.Sign(ContentPath(document.ID), d.TTL)
+	return DocumentView{Document: document, URL: url, URLExpiresAt: expires}
+}
+
+// documentParams parses the :id and :identification_id of the document routes
+func documentParams(c fiber.Ctx) (customerID, identificationID uint, err error) {
+	id, err := custom.ParseID(c.Params("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	identification, err := custom.ParseID(c.Params("identification_id"))
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint(id), uint(identification), nil
+}
+
+// UploadDocument serves POST /api/customer/:id/identifications/:identification_id/documents,
+// a multipart/form-data body with the scan in its file field. A file the
+// identification has already is not stored again, the existing document is
+// returned.
+func UploadDocument(documents *customerservice.Documents, downloads Downloads, tx repository.Transactor, log *audit.Log, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		customerID, identificationID, err := documentParams(c)
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		header, err := c.FormFile("file")
+		if err != nil {
+			return badRequest(c, "Invalid request body", errors.New("file is required, as multipart/form-data"))
+		}
+		file, err := header.Open()
+		if err != nil {
+			return badRequest(c, "Invalid request body", err)
+		}
+		defer file.Close()
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		var document *customermodel.Document
+		var created bool
+		err = tx.Transaction(ctx, func(ctx context.Context) error {
+			document, created, err = documents.Upload(ctx, customerID, identificationID, header.Filename, header.Size, file)
+			if err != nil || !created || log == nil {
+				return err
+			}
+			return log.Record(ctx, "document", document.ID, audit.ActionCreate, nil, document)
+		})
+		switch {
+		case errors.Is(err, customerservice.ErrDocumentType):
+			return response.Send(c, fiber.StatusUnsupportedMediaType, response.ErrorModel{
+				RetCode: string(response.UnsupportedMediaType),
+				Message: "Unsupported Media Type",
+				Data:    err.Error(),
+			})
+		case errors.Is(err, customerservice.ErrDocumentSize):
+			return response.Send(c, fiber.StatusRequestEntityTooLarge, response.ErrorModel{
+				RetCode: string(response.PayloadTooLarge),
+				Message: "Payload Too Large",
+				Data:    err.Error(),
+			})
+		case err != nil:
+			return script.ErrorResponse(c, ctx, logger, err, "Could not upload the document")
+		}
+
+		message := "Success Insert"
+		if !created {
+			message = "Document already uploaded"
+		}
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: message,
+			Data:    downloads.view(*document),
+		})
+	}
+}
+
+// ListDocuments serves GET /api/customer/:id/identifications/:identification_id/documents
+func ListDocuments(documents *customerservice.Documents, downloads Downloads, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		customerID, identificationID, err := documentParams(c)
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		list, err := documents.List(ctx, customerID, identificationID)
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not list the documents")
+		}
+		views := make([]DocumentView, len(list))
+		for i, document := range list {
+			views[i] = downloads.view(document)
+		}
+		if len(views) == 0 {
+			return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+				RetCode: string(response.NotFound),
+				Message: "No resource found",
+				Data:    views,
+			})
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "success",
+			Data:    views,
+		})
+	}
+}
+
+// GetDocument serves GET /api/customer/:id/identifications/:identification_id/documents/:document_id
+// with a fresh download URL
+func GetDocument(documents *customerservice.Documents, downloads Downloads, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		customerID, identificationID, err := documentParams(c)
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		id, err := custom.ParseID(c.Params("document_id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		document, err := documents.Get(ctx, customerID, identificationID, uint(id))
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not get the document")
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "Success",
+			Data:    downloads.view(*document),
+		})
+	}
+}
+
+// DeleteDocument serves DELETE /api/customer/:id/identifications/:identification_id/documents/:document_id.
+// The content is removed from the storage once the deletion is committed,
+// unless another document has it.
+func DeleteDocument(documents *customerservice.Documents, tx repository.Transactor, log *audit.Log, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		customerID, identificationID, err := documentParams(c)
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		id, err := custom.ParseID(c.Params("document_id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		var deleted *customermodel.Document
+		err = tx.Transaction(ctx, func(ctx context.Context) error {
+			if deleted, err = documents.Delete(ctx, customerID, identificationID, uint(id)); err != nil || log == nil {
+				return err
+			}
+			return log.Record(ctx, "document", deleted.ID, audit.ActionDelete, deleted, nil)
+		})
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not delete the document")
+		}
+		if err := documents.Prune(ctx, deleted); err != nil {
+			// The row is gone, the content is only left behind
+			logger.WarnContext(c.UserContext(), "could not remove the content of a document", "document_id", deleted.ID, "error", err)
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "Deleted Successfully",
+			Data:    deleted.ID,
+		})
+	}
+}
+
+// DocumentContent serves GET /api/documents/:id/content?expires=...&signature=...,
+// the URL of a DocumentView. The signature stands in for any other
+// authentication until the URL expires.
+func DocumentContent(documents *customerservice.Documents, downloads Downloads, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		if err := downloads.Signer.Verify(ContentPath(uint(id)), c.Query("expires"), c.Query("signature")); err != nil {
+			return response.Send(c, fiber.StatusForbidden, response.ErrorModel{
+				RetCode: string(response.Forbidden),
+				Message: "Forbidden",
+				Data:    err.Error(),
+			})
+		}
+
+		document, content, err := documents.Open(c.UserContext(), uint(id))
+		if err != nil {
+			return script.ErrorResponse(c, c.UserContext(), logger, err, "Could not open the document")
+		}
+
+		c.Set(fiber.HeaderContentType, document.ContentType)
+		c.Set(fiber.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{"filename": document.FileName}))
+		c.Set(fiber.HeaderCacheControl, "private, no-store")
+		return c.SendStream(content, int(document.Size))
+	}
+}
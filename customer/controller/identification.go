@@ -0,0 +1,127 @@
+package customercontroller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"sample/audit"
+	"sample/custom"
+	customermodel "sample/customer/model"
+	customerservice "sample/customer/service"
+	"sample/repository"
+	"sample/response"
+	"sample/script"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Caps of days and page_size on /api/customer/expiring-ids
+const (
+	MaxExpiringDays     = 366
+	MaxExpiringPageSize = 100
+)
+
+// ExpiringPage is the data of /api/customer/expiring-ids
+type ExpiringPage struct {
+	Total     int64                    `json:"total"`
+	Customers []customermodel.Customer `json:"customers"`
+}
+
+// Expiring serves /api/customer/expiring-ids?days=30&page=1&page_size=50
+// with the customers whose identifications expire in the next days, soonest
+// first, with those identifications only
+func Expiring(customers *customerservice.Service, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		days, err := strconv.Atoi(c.Query("days", "30"))
+		if err != nil || days < 0 || days > MaxExpiringDays {
+			return badRequest(c, "Invalid query", errors.New("days must be between 0 and "+strconv.Itoa(MaxExpiringDays)))
+		}
+		page, err := strconv.Atoi(c.Query("page", "1"))
+		if err != nil || page < 1 {
+			return badRequest(c, "Invalid query", errors.New("page must be a positive integer"))
+		}
+		size, err := strconv.Atoi(c.Query("page_size", "50"))
+		if err != nil || size < 1 || size > MaxExpiringPageSize {
+			return badRequest(c, "Invalid query", errors.New("page_size must be between 1 and "+strconv.Itoa(MaxExpiringPageSize)))
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		list, total, err := customers.Expiring(ctx, days, size, (page-1)*size)
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not list expiring identifications")
+		}
+
+		data := ExpiringPage{Total: total, Customers: list}
+		if len(list) == 0 {
+			data.Customers = []customermodel.Customer{}
+			return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+				RetCode: string(response.NotFound),
+				Message: "No resource found",
+				Data:    data,
+			})
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "success",
+			Data:    data,
+		})
+	}
+}
+
+// ReviewRequest is the body of POST /api/customer/:id/identifications/:identification_id/review
+type ReviewRequest struct {
+	Status string `json:"status"` // verified or rejected
+}
+
+// Review serves POST /api/customer/:id/identifications/:identification_id/review,
+// which verifies or rejects an identification, see
+// customerservice.Service.Review. The review is audited on the
+// identification.
+func Review(customers *customerservice.Service, tx repository.Transactor, log *audit.Log, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		customerID, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		id, err := custom.ParseID(c.Params("identification_id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		var body ReviewRequest
+		if _, err := script.BindBody(c, &body); err != nil {
+			return script.BodyErrorResponse(c, err)
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		var reviewed *customermodel.Identification
+		err = tx.Transaction(ctx, func(ctx context.Context) error {
+			before, err := customers.Identification(ctx, uint(customerID), uint(id))
+			if err != nil {
+				return err
+			}
+			if reviewed, err = customers.Review(ctx, uint(customerID), uint(id), body.Status); err != nil {
+				return err
+			}
+			if log == nil {
+				return nil
+			}
+			return log.Record(ctx, "identification", uint(id), audit.ActionReview, before, reviewed)
+		})
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not review the identification")
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "Review success",
+			Data:    reviewed,
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package customercontroller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"sample/audit"
+	"sample/custom"
+	customermodel "sample/customer/model"
+	customerservice "sample/customer/service"
+	"sample/repository"
+	"sample/response"
+	"sample/script"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxDuplicatesPageSize caps page_size on /api/customer/duplicates
+const MaxDuplicatesPageSize = 100
+
+// DefaultMinScore is the lowest score of a duplicate candidate by default,
+// a date of birth alone does not reach it
+const DefaultMinScore = 0.5
+
+// DuplicatesPage is the data of /api/customer/duplicates
+type DuplicatesPage struct {
+	Total      int                         `json:"total"`
+	Candidates []customerservice.Candidate `json:"candidates"`
+}
+
+// Duplicates serves /api/customer/duplicates?min_score=0.5&page=1&page_size=50
+// with the pairs of customers that may be the same person, best first
+func Duplicates(customers *customerservice.Service, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		minScore, err := strconv.ParseFloat(c.Query("min_score", strconv.FormatFloat(DefaultMinScore, 'f', -1, 64)), 64)
+		if err != nil || minScore < 0 || minScore > 1 {
+			return badRequest(c, "Invalid query", errors.New("min_score must be between 0 and 1"))
+		}
+		page, err := strconv.Atoi(c.Query("page", "1"))
+		if err != nil || page < 1 {
+			return badRequest(c, "Invalid query", errors.New("page must be a positive integer"))
+		}
+		size, err := strconv.Atoi(c.Query("page_size", "50"))
+		if err != nil || size < 1 || size > MaxDuplicatesPageSize {
+			return badRequest(c, "Invalid query", errors.New("page_size must be between 1 and "+strconv.Itoa(MaxDuplicatesPageSize)))
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		candidates, err := customers.Duplicates(ctx, minScore)
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not find duplicates")
+		}
+
+		data := DuplicatesPage{Total: len(candidates), Candidates: []customerservice.Candidate{}}
+		if start := (page - 1) * size; start < len(candidates) {
+			data.Candidates = candidates[start:min(start+size, len(candidates))]
+		}
+		if len(data.Candidates) == 0 {
+			return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+				RetCode: string(response.NotFound),
+				Message: "No resource found",
+				Data:    data,
+			})
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "success",
+			Data:    data,
+		})
+	}
+}
+
+// MergeRequest is the body of POST /api/customer/:id/merge
+type MergeRequest struct {
+	DuplicateID uint `json:"duplicate_id"`
+}
+
+// mergePreloads are the relations a merge moves, recorded in the audit log
+var mergePreloads = []string{"Addresses", "Contacts", "Identifications", "Merchant"}
+
+// Merge serves POST /api/customer/:id/merge, which merges the customer
+// duplicate_id of the body into :id, see customerservice.Service.Merge. Both
+// are audited as merged: the survivor with what it gained and the duplicate
+// with what it was.
+func Merge(customers *customerservice.Service, tx repository.Transactor, log *audit.Log, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		var body MergeRequest
+		if _, err := script.BindBody(c, &body); err != nil {
+			return script.BodyErrorResponse(c, err)
+		}
+		if body.DuplicateID == 0 {
+			return script.ErrorResponse(c, c.UserContext(), logger, custom.NewValidationError("duplicate_id", "is required"), "Could not merge customers")
+		}
+		survivorID, duplicateID := uint(id), body.DuplicateID
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		var merged *customermodel.Customer
+		err = tx.Transaction(ctx, func(ctx context.Context) error {
+			before, err := customers.Get(ctx, survivorID, mergePreloads)
+			if err != nil {
+				return err
+			}
+			duplicate, err := customers.Get(ctx, duplicateID, mergePreloads)
+			if err != nil {
+				return err
+			}
+			if err := customers.Merge(ctx, survivorID, duplicateID); err != nil {
+				return err
+			}
+			if merged, err = customers.Get(ctx, survivorID, mergePreloads); err != nil {
+				return err
+			}
+			if log == nil {
+				return nil
+			}
+			if err := log.Record(ctx, "customer", survivorID, audit.ActionMerge, before, merged); err != nil {
+				return err
+			}
+			return log.Record(ctx, "customer", duplicateID, audit.ActionMerge, duplicate, nil)
+		})
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not merge customers")
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "Merge success",
+			Data:    merged,
+		})
+	}
+}
+
+func badRequest(c fiber.Ctx, message string, err error) error {
+	return response.Send(c, fiber.StatusBadRequest, response.ErrorModel{
+		RetCode: string(response.BadRequest),
+		Message: message,
+		Data:    err.Error(),
+	})
+}
@@ -1,7 +1,12 @@
 package customermodel
 
 import (
+	"context"
+	"sample/audit"
+	"sample/custom"
+	"sample/geo"
 	merchantmodel "sample/merchant/model"
+	"sample/utils"
 	"time"
 )
 
@@ -10,7 +15,7 @@ type Customer struct {
 	ID                           uint                     `gorm:"primaryKey;autoIncrement" json:"id"`
 	Title                        string                   `gorm:"size:10" json:"title"`
 	FullName                     string                   `gorm:"size:100;not null" json:"full_name"`
-	LastName                     string                   `gorm:"size:100;not null" json:"last_name"`
+	LastName                     string                   `gorm:"size:100;not null;index" json:"last_name"`
 	OwnerGender                  string                   `gorm:"size:10" json:"owner_gender"`
 	DateOfBirth                  time.Time                `gorm:"not null" json:"date_of_birth"`
 	PlaceOfBirth                 string                   `gorm:"size:100" json:"place_of_birth"`
@@ -20,6 +25,8 @@ type Customer struct {
 	Identifications              []Identification         `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"identification"`
 	Contacts                     []Contact                `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"contact"`
 	Merchant                     []merchantmodel.Merchant `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;" json:"merchant"`
+
+	audit.Stamps
 }
 
 
@@ -33,6 +40,35 @@ type Address struct {
 	Municipality string `gorm:"size:50" json:"municipality"`
 	Barangays    string `gorm:"size:50" json:"barangays"`
 	PostalCode   string `gorm:"size:10" json:"postal_code"`
+
+	audit.Stamps
+}
+
+// Creating checks the place against the PSGC and stores its codes, see
+// repository.CreatingHook
+func (a *Address) Creating(ctx context.Context) error {
+	return a.normalize()
+}
+
+// Updating checks the place being changed, see repository.UpdatingHook
+func (a *Address) Updating(ctx context.Context) error {
+	return a.normalize()
+}
+
+func (a *Address) normalize() error {
+	place := geo.Place{
+		Region:       a.Region,
+		Province:     a.Province,
+		Municipality: a.Municipality,
+		Barangay:     a.Barangays,
+		PostalCode:   a.PostalCode,
+	}
+	if err := geo.Default().Normalize(&place); err != nil {
+		return err
+	}
+	a.Region, a.Province, a.Municipality = place.Region, place.Province, place.Municipality
+	a.Barangays, a.PostalCode = place.Barangay, place.PostalCode
+	return nil
 }
 
 
@@ -42,7 +78,14 @@ type Identification struct {
 	CustomerID     int       `gorm:"index;not null" json:"customer_id"`
 	IDType       string    `gorm:"size:50" json:"id_type"`
 	IDNumber     string    `gorm:"size:50;unique" json:"id_number"`
-	IDExpiryDate time.Time `json:"id_expiry_date"`
+	IDExpiryDate time.Time `gorm:"index" json:"id_expiry_date"`
+
+	// Set by the review and the expiry job, see IDStatuses
+	VerificationStatus string     `gorm:"size:10;not null;default:pending;index" json:"verification_status"`
+	ReviewedBy         string     `gorm:"size:100" json:"reviewed_by"`
+	ReviewedAt         *time.Time `json:"reviewed_at"`
+
+	audit.Stamps
 }
 
 
@@ -50,8 +93,57 @@ type Identification struct {
 type Contact struct {
 	ID                    uint   `gorm:"primaryKey;autoIncrement" json:"contact_id"`
 	CustomerID              int    `gorm:"index;not null" json:"customer_id"`
-	OwnerPhoneNumber      string `gorm:"size:15" json:"owner_phone_number"`
-	OwnerOtherPhoneNumber string `gorm:"size:15" json:"owner_other_phone_number"`
+	OwnerPhoneNumber      string `gorm:"size:16;index" json:"owner_phone_number"`
+	OwnerPhoneType        string `gorm:"size:10" json:"owner_phone_type"`
+	OwnerOtherPhoneNumber string `gorm:"size:16;index" json:"owner_other_phone_number"`
+	OwnerOtherPhoneType   string `gorm:"size:10" json:"owner_other_phone_type"`
 	Email                 string `gorm:"size:100;unique" json:"email"`
+
+	audit.Stamps
 }
 
+
+// Creating normalises the phone numbers and the email, see repository.CreatingHook
+func (c *Contact) Creating(ctx context.Context) error {
+	return c.normalize()
+}
+
+// Updating normalises the phone numbers and the email being changed, see repository.UpdatingHook
+func (c *Contact) Updating(ctx context.Context) error {
+	return c.normalize()
+}
+
+// DerivedFields writes the types with the numbers, see repository.DerivedFields
+func (c *Contact) DerivedFields() map[string][]string {
+	return map[string][]string{
+		"OwnerPhoneNumber":      {"OwnerPhoneType"},
+		"OwnerOtherPhoneNumber": {"OwnerOtherPhoneType"},
+	}
+}
+
+// normalize writes the phone numbers in E.164 with their types and the email
+// in lowercase. The types follow the numbers, whatever the client sent.
+func (c *Contact) normalize() error {
+	phones := []struct {
+		field string
+		value *string
+		typ   *string
+	}{
+		{"owner_phone_number", &c.OwnerPhoneNumber, &c.OwnerPhoneType},
+		{"owner_other_phone_number", &c.OwnerOtherPhoneNumber, &c.OwnerOtherPhoneType},
+	}
+	for _, phone := range phones {
+		*phone.typ = ""
+		if *phone.value == "" {
+			continue
+		}
+		normalized, ok := utils.NormalizePhone(*phone.value)
+		if !ok {
+			return custom.NewValidationError(phone.field, "is not a phone number")
+		}
+		*phone.value = normalized
+		*phone.typ = string(utils.PhoneTypeOf(normalized))
+	}
+	c.Email = utils.NormalizeEmail(c.Email)
+	return nil
+}
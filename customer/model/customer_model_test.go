@@ -0,0 +1,47 @@
+package customermodel_test
+
+import (
+	"context"
+	"testing"
+
+	customermodel "sample/customer/model"
+	"sample/repository"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestContactPatchRetypesTheNumber(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/contacts.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&customermodel.Contact{}); err != nil {
+		t.Fatal(err)
+	}
+
+	repos := map[string]repository.Repository[customermodel.Contact]{
+		"gorm":   repository.NewGorm[customermodel.Contact](db),
+		"memory": repository.NewMemory[customermodel.Contact](),
+	}
+	for name, repo := range repos {
+		ctx := context.Background()
+		contact := &customermodel.Contact{CustomerID: 1, OwnerPhoneNumber: "0917 123 4567", OwnerOtherPhoneNumber: "0918 765 4321"}
+		if err := repo.Create(ctx, contact); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		changes := &customermodel.Contact{OwnerPhoneNumber: "(02) 8123-4567"}
+		updated, err := repo.Update(ctx, contact.ID, changes, []string{"OwnerPhoneNumber"})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if updated.OwnerPhoneNumber != "+63281234567" || updated.OwnerPhoneType != "landline" {
+			t.Errorf("%s: patched number = %s (%s), want +63281234567 (landline)", name, updated.OwnerPhoneNumber, updated.OwnerPhoneType)
+		}
+		// The other number is not in the patch, nor its type
+		if updated.OwnerOtherPhoneNumber != "+639187654321" || updated.OwnerOtherPhoneType != "mobile" {
+			t.Errorf("%s: other number = %s (%s), want +639187654321 (mobile) untouched", name, updated.OwnerOtherPhoneNumber, updated.OwnerOtherPhoneType)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package customermodel
+
+import "sample/audit"
+
+// Document is a scan of an identification, its content is in the storage
+// under StorageKey. The same file is stored once per identification, see
+// Checksum.
+type Document struct {
+	ID               uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	IdentificationID uint   `gorm:"not null;uniqueIndex:idx_documents_checksum,priority:1" json:"identification_id"`
+	FileName         string `gorm:"size:255" json:"file_name"`
+	ContentType      string `gorm:"size:100;not null" json:"content_type"`
+	Size             int64  `gorm:"not null" json:"size"`
+	Checksum         string `gorm:"size:64;not null;index;uniqueIndex:idx_documents_checksum,priority:2" json:"checksum"` // SHA-256, hex
+	StorageKey       string `gorm:"size:255;not null" json:"-"`
+
+	// The documents go with their identification
+	Identification *Identification `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	audit.Stamps
+}
@@ -0,0 +1,88 @@
+package customermodel
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sample/custom"
+)
+
+// Verification statuses of an identification. New ones are pending until
+// reviewed, the expiry job marks those past their expiry date expired.
+const (
+	IDPending  = "pending"
+	IDVerified = "verified"
+	IDRejected = "rejected"
+	IDExpired  = "expired"
+)
+
+// IDStatuses lists every verification status
+var IDStatuses = []string{IDPending, IDVerified, IDRejected, IDExpired}
+
+// IDTypeRule is what an identification of a type must satisfy
+type IDTypeRule struct {
+	Number         *regexp.Regexp // the format of IDNumber, uppercase
+	Example        string         // a number of the format, for the errors
+	ExpiryRequired bool           // whether IDExpiryDate must be given
+}
+
+// IDTypeRules are the accepted values of IDType, the Philippine IDs
+var IDTypeRules = map[string]IDTypeRule{
+	"passport":        {regexp.MustCompile(`^[A-Z]{1,2}[0-9]{6,7}[A-Z]?$`), "P1234567A", true},
+	"drivers_license": {regexp.MustCompile(`^[A-Z][0-9]{2}-?[0-9]{2}-?[0-9]{6}$`), "N01-23-456789", true},
+	"umid":            {regexp.MustCompile(`^[0-9]{4}-?[0-9]{7}-?[0-9]$`), "0111-1234567-8", false},
+	"sss":             {regexp.MustCompile(`^[0-9]{2}-?[0-9]{7}-?[0-9]$`), "34-1234567-8", false},
+	"philsys":         {regexp.MustCompile(`^[0-9]{4}-?[0-9]{4}-?[0-9]{4}-?[0-9]{4}$`), "1234-5678-9012-3456", false},
+	"prc":             {regexp.MustCompile(`^[0-9]{7}$`), "0123456", true},
+	"postal_id":       {regexp.MustCompile(`^[A-Z0-9]{12}$`), "PRN100123456", true},
+	"tin":             {regexp.MustCompile(`^[0-9]{3}-?[0-9]{3}-?[0-9]{3}(-?[0-9]{3,5})?$`), "123-456-789-000", false},
+}
+
+// IDTypes lists the keys of IDTypeRules, sorted
+func IDTypes() []string {
+	types := make([]string, 0, len(IDTypeRules))
+	for t := range IDTypeRules {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Creating checks the identification against the rule of its type and
+// makes it pending, whatever the client sent, see repository.CreatingHook
+func (i *Identification) Creating(ctx context.Context) error {
+	return i.register()
+}
+
+// Updating does the same for the identifications added by an update. The
+// ones stored already are changed by the review and the expiry job only,
+// see repository.UpdatingHook.
+func (i *Identification) Updating(ctx context.Context) error {
+	if i.ID != 0 {
+		return nil
+	}
+	return i.register()
+}
+
+func (i *Identification) register() error {
+	i.IDType = strings.ReplaceAll(strings.ReplaceAll(strings.ToLower(strings.TrimSpace(i.IDType)), " ", "_"), "-", "_")
+	i.IDNumber = strings.ToUpper(strings.TrimSpace(i.IDNumber))
+
+	rule, ok := IDTypeRules[i.IDType]
+	if !ok {
+		return custom.NewValidationError("id_type", "must be one of "+strings.Join(IDTypes(), ", "))
+	}
+	if !rule.Number.MatchString(i.IDNumber) {
+		return custom.NewValidationError("id_number", "is not a "+i.IDType+" number, e.g. "+rule.Example)
+	}
+	if rule.ExpiryRequired && i.IDExpiryDate.IsZero() {
+		return custom.NewValidationError("id_expiry_date", "is required for "+i.IDType)
+	}
+
+	i.VerificationStatus = IDPending
+	i.ReviewedBy = ""
+	i.ReviewedAt = nil
+	return nil
+}
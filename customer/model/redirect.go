@@ -0,0 +1,16 @@
+package customermodel
+
+import "sample/audit"
+
+// Redirect points the ID of a customer merged into another to the survivor,
+// see customerservice.Service.Merge
+type Redirect struct {
+	FromID uint `gorm:"primaryKey;autoIncrement:false" json:"from_id"`
+	ToID   uint `gorm:"index;not null" json:"to_id"`
+
+	audit.Stamps
+}
+
+func (Redirect) TableName() string {
+	return "customer_redirects"
+}
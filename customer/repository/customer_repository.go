@@ -0,0 +1,527 @@
+package customerrepository
+
+import (
+	"context"
+	"sample/custom"
+	customermodel "sample/customer/model"
+	merchantmodel "sample/merchant/model"
+	merchantrepository "sample/merchant/repository"
+	"sample/repository"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CustomerRepository stores customers and their addresses, identifications and contacts
+type CustomerRepository interface {
+	repository.Repository[customermodel.Customer]
+	// ExistsByTIN reports whether another customer than exceptID holds tin
+	ExistsByTIN(ctx context.Context, tin string, exceptID uint) (bool, error)
+	// Candidates returns the customers after customer, by ID, that may be
+	// the same person: those sharing its TIN, an email or phone number of
+	// its contacts, or the first letters of its last name. They come with
+	// their contacts, at most limit of them.
+	Candidates(ctx context.Context, customer *customermodel.Customer, limit int) ([]customermodel.Customer, error)
+	// Merge moves the addresses, identifications, contacts and merchants of
+	// duplicateID to survivorID, deletes duplicateID and redirects it, and
+	// the IDs redirected to it, to survivorID. The survivor takes the TIN of
+	// the duplicate if it has none, a different one is a
+	// custom.ConflictError.
+	Merge(ctx context.Context, survivorID, duplicateID uint) error
+	// Redirect returns the customer a merged ID was merged into, or custom.ErrNotFound
+	Redirect(ctx context.Context, id uint) (uint, error)
+	// Identification returns the identification id of customerID, or custom.ErrNotFound
+	Identification(ctx context.Context, customerID, id uint) (*customermodel.Identification, error)
+	// Review sets the verification status of the identification id, and who
+	// set it when
+	Review(ctx context.Context, id uint, status, reviewer string, at time.Time) error
+	// Expiring returns a page of the customers with an identification
+	// expiring from from to to, soonest first, with those identifications
+	// only, and how many customers there are
+	Expiring(ctx context.Context, from, to time.Time, limit, offset int) ([]customermodel.Customer, int64, error)
+	// ExpireIdentifications marks the pending and verified identifications
+	// that expired before now expired, and returns how many
+	ExpireIdentifications(ctx context.Context, now time.Time) (int64, error)
+}
+
+type gormCustomerRepository struct {
+	*repository.Gorm[customermodel.Customer]
+}
+
+// NewGorm creates the GORM CustomerRepository
+func NewGorm(db *gorm.DB) CustomerRepository {
+	return &gormCustomerRepository{repository.NewGorm[customermodel.Customer](db)}
+}
+
+func (r *gormCustomerRepository) ExistsByTIN(ctx context.Context, tin string, exceptID uint) (bool, error) {
+	var count int64
+	err := r.Conn(ctx).Model(&customermodel.Customer{}).
+		Where("taxpayer_identification_number = ? AND id <> ?", tin, exceptID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Candidates only queries indexed columns, the last name by a prefix
+// pattern
+func (r *gormCustomerRepository) Candidates(ctx context.Context, customer *customermodel.Customer, limit int) ([]customermodel.Customer, error) {
+	db := r.Conn(ctx)
+	var conditions []string
+	var args []any
+	if tin := customer.TaxpayerIdentificationNumber; tin != "" {
+		conditions = append(conditions, "taxpayer_identification_number = ?")
+		args = append(args, tin)
+	}
+	if prefix := namePrefix(customer.LastName); prefix != "" {
+		// GLOB is the prefix match SQLite serves from an index, LIKE the one
+		// Postgres does
+		if db.Dialector.Name() == "sqlite" {
+			conditions = append(conditions, "last_name GLOB ?")
+			args = append(args, prefix+"*")
+		} else {
+			conditions = append(conditions, "last_name LIKE ?")
+			args = append(args, prefix+"%")
+		}
+	}
+	if emails, phones := contactKeys(customer); len(emails) > 0 || len(phones) > 0 {
+		contacts := db.Model(&customermodel.Contact{}).Select("customer_id").
+			Where("email IN ? OR owner_phone_number IN ? OR owner_other_phone_number IN ?", emails, phones, phones)
+		conditions = append(conditions, "id IN (?)")
+		args = append(args, contacts)
+	}
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+
+	var customers []customermodel.Customer
+	err := db.Where("id > ?", customer.ID).Where("("+strings.Join(conditions, " OR ")+")", args...).
+		Preload("Contacts").Order("id").Limit(limit).Find(&customers).Error
+	return customers, err
+}
+
+func (r *gormCustomerRepository) Merge(ctx context.Context, survivorID, duplicateID uint) error {
+	return r.Tx().Transaction(ctx, func(ctx context.Context) error {
+		db := r.Conn(ctx)
+
+		// Lock both rows, in ID order so that concurrent merges cannot deadlock
+		var customers []customermodel.Customer
+		err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id IN ?", []uint{survivorID, duplicateID}).Order("id").Find(&customers).Error
+		if err != nil {
+			return err
+		}
+		if len(customers) != 2 {
+			return custom.ErrNotFound
+		}
+		survivor, duplicate := customers[0], customers[1]
+		if survivor.ID != survivorID {
+			survivor, duplicate = duplicate, survivor
+		}
+		tin, err := mergedTIN(&survivor, &duplicate)
+		if err != nil {
+			return err
+		}
+
+		for _, child := range []any{&customermodel.Address{}, &customermodel.Identification{}, &customermodel.Contact{}, &merchantmodel.Merchant{}} {
+			if err := db.Model(child).Where("customer_id = ?", duplicateID).Update("customer_id", survivorID).Error; err != nil {
+				return repository.Error(err)
+			}
+		}
+		if err := db.Delete(&customermodel.Customer{}, duplicateID).Error; err != nil {
+			return repository.Error(err)
+		}
+		// Once the duplicate is gone, the TIN is unique
+		if tin != survivor.TaxpayerIdentificationNumber {
+			err := db.Model(&customermodel.Customer{}).Where("id = ?", survivorID).Update("taxpayer_identification_number", tin).Error
+			if err != nil {
+				return repository.Error(err)
+			}
+		}
+
+		// Earlier merges into the duplicate now lead to the survivor in one hop
+		err = db.Model(&customermodel.Redirect{}).Where("to_id = ?", duplicateID).Update("to_id", survivorID).Error
+		if err != nil {
+			return err
+		}
+		return repository.Error(db.Create(&customermodel.Redirect{FromID: duplicateID, ToID: survivorID}).Error)
+	})
+}
+
+func (r *gormCustomerRepository) Redirect(ctx context.Context, id uint) (uint, error) {
+	var redirect customermodel.Redirect
+	if err := r.Conn(ctx).First(&redirect, "from_id = ?", id).Error; err != nil {
+		return 0, repository.Error(err)
+	}
+	return redirect.ToID, nil
+}
+
+func (r *gormCustomerRepository) Identification(ctx context.Context, customerID, id uint) (*customermodel.Identification, error) {
+	var identification customermodel.Identification
+	if err := r.Conn(ctx).First(&identification, "id = ? AND customer_id = ?", id, customerID).Error; err != nil {
+		return nil, repository.Error(err)
+	}
+	return &identification, nil
+}
+
+func (r *gormCustomerRepository) Review(ctx context.Context, id uint, status, reviewer string, at time.Time) error {
+	result := r.Conn(ctx).Model(&customermodel.Identification{}).Where("id = ?", id).Updates(map[string]any{
+		"verification_status": status,
+		"reviewed_by":         reviewer,
+		"reviewed_at":         at,
+	})
+	if result.Error != nil {
+		return repository.Error(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return custom.ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormCustomerRepository) Expiring(ctx context.Context, from, to time.Time, limit, offset int) ([]customermodel.Customer, int64, error) {
+	db := r.Conn(ctx)
+	expiring := db.Model(&customermodel.Identification{}).Select("customer_id").
+		Where("id_expiry_date BETWEEN ? AND ?", from, to)
+
+	var total int64
+	if err := db.Model(&customermodel.Customer{}).Where("id IN (?)", expiring).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var customers []customermodel.Customer
+	err := db.Where("id IN (?)", expiring).
+		Preload("Identifications", "id_expiry_date BETWEEN ? AND ?", from, to).
+		Order(clause.Expr{SQL: "(SELECT MIN(id_expiry_date) FROM identifications WHERE customer_id = customers.id AND id_expiry_date BETWEEN ? AND ?), id", Vars: []any{from, to}}).
+		Limit(limit).Offset(offset).Find(&customers).Error
+	return customers, total, err
+}
+
+func (r *gormCustomerRepository) ExpireIdentifications(ctx context.Context, now time.Time) (int64, error) {
+	// Identifications without an expiry date have the zero time
+	result := r.Conn(ctx).Model(&customermodel.Identification{}).
+		Where("verification_status IN ? AND id_expiry_date > ? AND id_expiry_date < ?", []string{customermodel.IDPending, customermodel.IDVerified}, time.Time{}, now).
+		Update("verification_status", customermodel.IDExpired)
+	return result.RowsAffected, result.Error
+}
+
+type memoryCustomerRepository struct {
+	*repository.Memory[customermodel.Customer]
+	merchants merchantrepository.MerchantRepository
+
+	mu        sync.Mutex
+	redirects map[uint]uint
+	// lastIdentification numbers the identifications, Memory only numbers
+	// the customers
+	lastIdentification uint
+}
+
+// NewMemory creates an in-memory CustomerRepository for tests. The merchants
+// of the customers it creates are created in merchants too, with their
+// products, like GORM does.
+func NewMemory(merchants merchantrepository.MerchantRepository) CustomerRepository {
+	return &memoryCustomerRepository{
+		Memory: repository.NewMemory(func(c *customermodel.Customer) string {
+			return c.TaxpayerIdentificationNumber
+		}),
+		merchants: merchants,
+		redirects: map[uint]uint{},
+	}
+}
+
+// Create numbers the identifications of the customer too, Review and the
+// expiry find them by ID, and creates its merchants in the merchant
+// repository
+func (r *memoryCustomerRepository) Create(ctx context.Context, customer *customermodel.Customer) error {
+	if err := r.Memory.Create(ctx, customer); err != nil {
+		return err
+	}
+	if err := r.numberIdentifications(ctx, customer.ID); err != nil {
+		return err
+	}
+	if len(customer.Merchant) == 0 {
+		return nil
+	}
+	for i := range customer.Merchant {
+		customer.Merchant[i].CustomerID = int(customer.ID)
+		if err := r.merchants.Create(ctx, &customer.Merchant[i]); err != nil {
+			return err
+		}
+	}
+	_, err := r.Memory.Update(ctx, customer.ID, &customermodel.Customer{Merchant: customer.Merchant}, []string{"Merchant"})
+	return err
+}
+
+// Update numbers the identifications it adds, see Create
+func (r *memoryCustomerRepository) Update(ctx context.Context, id uint, changes *customermodel.Customer, fields []string) (*customermodel.Customer, error) {
+	if _, err := r.Memory.Update(ctx, id, changes, fields); err != nil {
+		return nil, err
+	}
+	if err := r.numberIdentifications(ctx, id); err != nil {
+		return nil, err
+	}
+	return r.Get(ctx, id, nil)
+}
+
+func (r *memoryCustomerRepository) numberIdentifications(ctx context.Context, id uint) error {
+	customer, err := r.Get(ctx, id, nil)
+	if err != nil {
+		return err
+	}
+	numbered := false
+	r.mu.Lock()
+	for j := range customer.Identifications {
+		if i := &customer.Identifications[j]; i.ID == 0 {
+			r.lastIdentification++
+			i.ID, i.CustomerID = r.lastIdentification, int(id)
+			numbered = true
+		}
+	}
+	r.mu.Unlock()
+	if !numbered {
+		return nil
+	}
+	_, err = r.Memory.Update(ctx, id, customer, []string{"Identifications"})
+	return err
+}
+
+func (r *memoryCustomerRepository) ExistsByTIN(ctx context.Context, tin string, exceptID uint) (bool, error) {
+	matches := r.Where(func(c *customermodel.Customer) bool {
+		return c.TaxpayerIdentificationNumber == tin && c.ID != exceptID
+	})
+	return len(matches) > 0, nil
+}
+
+func (r *memoryCustomerRepository) Candidates(ctx context.Context, customer *customermodel.Customer, limit int) ([]customermodel.Customer, error) {
+	prefix := namePrefix(customer.LastName)
+	emails, phones := contactKeys(customer)
+	matches := r.Where(func(c *customermodel.Customer) bool {
+		if c.ID <= customer.ID {
+			return false
+		}
+		if tin := customer.TaxpayerIdentificationNumber; tin != "" && c.TaxpayerIdentificationNumber == tin {
+			return true
+		}
+		if prefix != "" && strings.HasPrefix(c.LastName, prefix) {
+			return true
+		}
+		for _, contact := range c.Contacts {
+			if slices.Contains(emails, contact.Email) || slices.Contains(phones, contact.OwnerPhoneNumber) || slices.Contains(phones, contact.OwnerOtherPhoneNumber) {
+				return true
+			}
+		}
+		return false
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// Merge moves the nested items stored with the duplicate, and its merchants
+// in the merchant repository too, like the GORM Merge does
+func (r *memoryCustomerRepository) Merge(ctx context.Context, survivorID, duplicateID uint) error {
+	survivor, err := r.Get(ctx, survivorID, nil)
+	if err != nil {
+		return err
+	}
+	duplicate, err := r.Get(ctx, duplicateID, nil)
+	if err != nil {
+		return err
+	}
+	if survivor.TaxpayerIdentificationNumber, err = mergedTIN(survivor, duplicate); err != nil {
+		return err
+	}
+
+	owner := int(survivorID)
+	for _, a := range duplicate.Addresses {
+		a.CustomerID = owner
+		survivor.Addresses = append(survivor.Addresses, a)
+	}
+	for _, i := range duplicate.Identifications {
+		i.CustomerID = owner
+		survivor.Identifications = append(survivor.Identifications, i)
+	}
+	for _, c := range duplicate.Contacts {
+		c.CustomerID = owner
+		survivor.Contacts = append(survivor.Contacts, c)
+	}
+	for _, m := range duplicate.Merchant {
+		m.CustomerID = owner
+		survivor.Merchant = append(survivor.Merchant, m)
+		if _, err := r.merchants.Update(ctx, m.ID, &merchantmodel.Merchant{CustomerID: owner}, []string{"CustomerID"}); err != nil {
+			return err
+		}
+	}
+	// Once the duplicate is gone, the TIN is unique
+	if err := r.Delete(ctx, duplicateID); err != nil {
+		return err
+	}
+	if _, err := r.Update(ctx, survivorID, survivor, []string{"Addresses", "Identifications", "Contacts", "Merchant", "TaxpayerIdentificationNumber"}); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for from, to := range r.redirects {
+		if to == duplicateID {
+			r.redirects[from] = survivorID
+		}
+	}
+	r.redirects[duplicateID] = survivorID
+	return nil
+}
+
+func (r *memoryCustomerRepository) Redirect(ctx context.Context, id uint) (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	to, ok := r.redirects[id]
+	if !ok {
+		return 0, custom.ErrNotFound
+	}
+	return to, nil
+}
+
+func (r *memoryCustomerRepository) Identification(ctx context.Context, customerID, id uint) (*customermodel.Identification, error) {
+	customer, err := r.Get(ctx, customerID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range customer.Identifications {
+		if i.ID == id {
+			return &i, nil
+		}
+	}
+	return nil, custom.ErrNotFound
+}
+
+func (r *memoryCustomerRepository) Review(ctx context.Context, id uint, status, reviewer string, at time.Time) error {
+	return r.updateIdentifications(ctx, func(i *customermodel.Identification) bool {
+		if i.ID != id {
+			return false
+		}
+		i.VerificationStatus, i.ReviewedBy, i.ReviewedAt = status, reviewer, &at
+		return true
+	}, true)
+}
+
+func (r *memoryCustomerRepository) Expiring(ctx context.Context, from, to time.Time, limit, offset int) ([]customermodel.Customer, int64, error) {
+	expiring := func(i customermodel.Identification) bool {
+		return !i.IDExpiryDate.Before(from) && !i.IDExpiryDate.After(to)
+	}
+	var customers []customermodel.Customer
+	soonest := map[uint]time.Time{}
+	for _, c := range r.Where(func(*customermodel.Customer) bool { return true }) {
+		var kept []customermodel.Identification
+		for _, i := range c.Identifications {
+			if expiring(i) {
+				kept = append(kept, i)
+				if s, ok := soonest[c.ID]; !ok || i.IDExpiryDate.Before(s) {
+					soonest[c.ID] = i.IDExpiryDate
+				}
+			}
+		}
+		if len(kept) > 0 {
+			c.Identifications = kept
+			customers = append(customers, c)
+		}
+	}
+	sort.SliceStable(customers, func(a, b int) bool {
+		return soonest[customers[a].ID].Before(soonest[customers[b].ID])
+	})
+
+	total := int64(len(customers))
+	if offset >= len(customers) {
+		return []customermodel.Customer{}, total, nil
+	}
+	return customers[offset:min(offset+limit, len(customers))], total, nil
+}
+
+func (r *memoryCustomerRepository) ExpireIdentifications(ctx context.Context, now time.Time) (int64, error) {
+	var expired int64
+	err := r.updateIdentifications(ctx, func(i *customermodel.Identification) bool {
+		if i.IDExpiryDate.IsZero() || !i.IDExpiryDate.Before(now) ||
+			i.VerificationStatus != customermodel.IDPending && i.VerificationStatus != customermodel.IDVerified {
+			return false
+		}
+		i.VerificationStatus = customermodel.IDExpired
+		expired++
+		return true
+	}, false)
+	return expired, err
+}
+
+// updateIdentifications stores the customers of the identifications change
+// changed, custom.ErrNotFound when it changed none and one was expected
+func (r *memoryCustomerRepository) updateIdentifications(ctx context.Context, change func(i *customermodel.Identification) bool, expectOne bool) error {
+	changed := false
+	for _, c := range r.Where(func(*customermodel.Customer) bool { return true }) {
+		touched := false
+		for j := range c.Identifications {
+			if change(&c.Identifications[j]) {
+				touched = true
+			}
+		}
+		if !touched {
+			continue
+		}
+		changed = true
+		if _, err := r.Update(ctx, c.ID, &c, []string{"Identifications"}); err != nil {
+			return err
+		}
+	}
+	if expectOne && !changed {
+		return custom.ErrNotFound
+	}
+	return nil
+}
+
+// namePrefixLength is how many letters of the last names Candidates compares
+const namePrefixLength = 3
+
+// namePrefix is the first letters of a last name, as written, or "" if the
+// name is shorter or they are not all letters
+func namePrefix(lastName string) string {
+	runes := []rune(strings.TrimSpace(lastName))
+	if len(runes) < namePrefixLength {
+		return ""
+	}
+	for _, r := range runes[:namePrefixLength] {
+		if !unicode.IsLetter(r) {
+			return ""
+		}
+	}
+	return string(runes[:namePrefixLength])
+}
+
+// contactKeys are the emails and phone numbers of the contacts of customer,
+// as stored: lowercase and E.164
+func contactKeys(customer *customermodel.Customer) (emails, phones []string) {
+	for _, contact := range customer.Contacts {
+		if contact.Email != "" {
+			emails = append(emails, contact.Email)
+		}
+		for _, phone := range []string{contact.OwnerPhoneNumber, contact.OwnerOtherPhoneNumber} {
+			if phone != "" {
+				phones = append(phones, phone)
+			}
+		}
+	}
+	return emails, phones
+}
+
+// mergedTIN is the TIN of survivor once duplicate is merged into it: its
+// own, or the duplicate's if it has none. Two different ones are a conflict,
+// one of them would be lost.
+func mergedTIN(survivor, duplicate *customermodel.Customer) (string, error) {
+	switch a, b := survivor.TaxpayerIdentificationNumber, duplicate.TaxpayerIdentificationNumber; {
+	case b == "" || a == b:
+		return a, nil
+	case a == "":
+		return b, nil
+	}
+	return "", custom.NewConflictError("the customers have different taxpayer identification numbers, correct one of them before merging")
+}
@@ -0,0 +1,106 @@
+package customerrepository
+
+import (
+	"context"
+	"sample/custom"
+	customermodel "sample/customer/model"
+	"sample/repository"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// DocumentRepository stores the documents of the identifications
+type DocumentRepository interface {
+	Create(ctx context.Context, document *customermodel.Document) error
+	// Get returns the document id, or custom.ErrNotFound
+	Get(ctx context.Context, id uint) (*customermodel.Document, error)
+	// List returns the documents of an identification, oldest first
+	List(ctx context.Context, identificationID uint) ([]customermodel.Document, error)
+	// FindByChecksum returns the document of an identification with the
+	// checksum, or custom.ErrNotFound
+	FindByChecksum(ctx context.Context, identificationID uint, checksum string) (*customermodel.Document, error)
+	// CountByChecksum returns how many documents of any identification have
+	// the checksum, which share their content
+	CountByChecksum(ctx context.Context, checksum string) (int64, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+type gormDocumentRepository struct {
+	*repository.Gorm[customermodel.Document]
+}
+
+// NewGormDocuments creates the GORM DocumentRepository
+func NewGormDocuments(db *gorm.DB) DocumentRepository {
+	return &gormDocumentRepository{repository.NewGorm[customermodel.Document](db)}
+}
+
+func (r *gormDocumentRepository) Get(ctx context.Context, id uint) (*customermodel.Document, error) {
+	return r.Gorm.Get(ctx, id, nil)
+}
+
+func (r *gormDocumentRepository) List(ctx context.Context, identificationID uint) ([]customermodel.Document, error) {
+	documents := []customermodel.Document{}
+	err := r.Conn(ctx).Where("identification_id = ?", identificationID).Order("id").Find(&documents).Error
+	return documents, err
+}
+
+func (r *gormDocumentRepository) FindByChecksum(ctx context.Context, identificationID uint, checksum string) (*customermodel.Document, error) {
+	var document customermodel.Document
+	err := r.Conn(ctx).First(&document, "identification_id = ? AND checksum = ?", identificationID, checksum).Error
+	if err != nil {
+		return nil, repository.Error(err)
+	}
+	return &document, nil
+}
+
+func (r *gormDocumentRepository) CountByChecksum(ctx context.Context, checksum string) (int64, error) {
+	var count int64
+	err := r.Conn(ctx).Model(&customermodel.Document{}).Where("checksum = ?", checksum).Count(&count).Error
+	return count, err
+}
+
+type memoryDocumentRepository struct {
+	*repository.Memory[customermodel.Document]
+
+	mu sync.Mutex // serialises the checksum check and the create
+}
+
+// NewMemoryDocuments creates an in-memory DocumentRepository for tests
+func NewMemoryDocuments() DocumentRepository {
+	return &memoryDocumentRepository{Memory: repository.NewMemory[customermodel.Document]()}
+}
+
+func (r *memoryDocumentRepository) Create(ctx context.Context, document *customermodel.Document) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.FindByChecksum(ctx, document.IdentificationID, document.Checksum); err == nil {
+		return custom.ErrDuplicate
+	}
+	return r.Memory.Create(ctx, document)
+}
+
+func (r *memoryDocumentRepository) Get(ctx context.Context, id uint) (*customermodel.Document, error) {
+	return r.Memory.Get(ctx, id, nil)
+}
+
+func (r *memoryDocumentRepository) List(ctx context.Context, identificationID uint) ([]customermodel.Document, error) {
+	return r.Where(func(d *customermodel.Document) bool {
+		return d.IdentificationID == identificationID
+	}), nil
+}
+
+func (r *memoryDocumentRepository) FindByChecksum(ctx context.Context, identificationID uint, checksum string) (*customermodel.Document, error) {
+	matches := r.Where(func(d *customermodel.Document) bool {
+		return d.IdentificationID == identificationID && d.Checksum == checksum
+	})
+	if len(matches) == 0 {
+		return nil, custom.ErrNotFound
+	}
+	return &matches[0], nil
+}
+
+func (r *memoryDocumentRepository) CountByChecksum(ctx context.Context, checksum string) (int64, error) {
+	matches := r.Where(func(d *customermodel.Document) bool { return d.Checksum == checksum })
+	return int64(len(matches)), nil
+}
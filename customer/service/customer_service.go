@@ -0,0 +1,145 @@
+package customerservice
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"sample/custom"
+	customermodel "sample/customer/model"
+	customerrepository "sample/customer/repository"
+	merchantrepository "sample/merchant/repository"
+	merchantservice "sample/merchant/service"
+	"sample/repository"
+)
+
+// Service holds the customer business rules on top of a CustomerRepository
+type Service struct {
+	repo      customerrepository.CustomerRepository
+	merchants merchantrepository.MerchantRepository
+	now       func() time.Time
+}
+
+// New creates the customer Service, merchants is used to check the merchants
+// a delete cascades to and now is the clock used for date rules
+func New(repo customerrepository.CustomerRepository, merchants merchantrepository.MerchantRepository, now func() time.Time) *Service {
+	return &Service{repo: repo, merchants: merchants, now: now}
+}
+
+func (s *Service) List(ctx context.Context, opts repository.ListOptions) ([]customermodel.Customer, error) {
+	return s.repo.List(ctx, opts)
+}
+
+func (s *Service) Each(ctx context.Context, opts repository.ListOptions, size int, fn func(batch []customermodel.Customer) error) error {
+	return s.repo.Each(ctx, opts, size, fn)
+}
+
+func (s *Service) Get(ctx context.Context, id uint, preloads []string) (*customermodel.Customer, error) {
+	return s.repo.Get(ctx, id, preloads)
+}
+
+// Create validates the customer and stores it with its nested addresses, identifications and contacts
+func (s *Service) Create(ctx context.Context, customer *customermodel.Customer) error {
+	normalize(customer)
+
+	if customer.FullName == "" {
+		return custom.NewValidationError("full_name", "is required")
+	}
+	if customer.LastName == "" {
+		return custom.NewValidationError("last_name", "is required")
+	}
+	if customer.DateOfBirth.IsZero() {
+		return custom.NewValidationError("date_of_birth", "is required")
+	}
+	if err := s.validate(ctx, 0, customer); err != nil {
+		return err
+	}
+
+	return s.repo.Create(ctx, customer)
+}
+
+// Update applies changes to the customer with id, see repository.Repository
+func (s *Service) Update(ctx context.Context, id uint, changes *customermodel.Customer, fields []string) (*customermodel.Customer, error) {
+	normalize(changes)
+
+	// A patch may clear fields, but not the required ones
+	if repository.Selected(fields, "FullName") && changes.FullName == "" {
+		return nil, custom.NewValidationError("full_name", "is required")
+	}
+	if repository.Selected(fields, "LastName") && changes.LastName == "" {
+		return nil, custom.NewValidationError("last_name", "is required")
+	}
+	if repository.Selected(fields, "DateOfBirth") && changes.DateOfBirth.IsZero() {
+		return nil, custom.NewValidationError("date_of_birth", "is required")
+	}
+	if err := s.validate(ctx, id, changes); err != nil {
+		return nil, err
+	}
+	for i := range changes.Merchant {
+		if err := merchantservice.NoNestedProducts(fmt.Sprintf("merchant[%d].product", i), &changes.Merchant[i]); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.Update(ctx, id, changes, fields)
+}
+
+// Delete deletes the customer with its merchants and their products, unless
+// some are still to be delivered, see merchantservice.KeepUndelivered. A
+// merge moves the merchants instead, see Merge.
+func (s *Service) Delete(ctx context.Context, id uint) error {
+	merchants, err := s.merchants.List(ctx, repository.ListOptions{Filters: []repository.Filter{
+		{Field: "CustomerID", Op: repository.OpEq, Value: int(id)},
+	}})
+	if err != nil {
+		return err
+	}
+	ids := make([]uint, len(merchants))
+	for i, m := range merchants {
+		ids[i] = m.ID
+	}
+	if err := merchantservice.KeepUndelivered(ctx, s.merchants, ids, s.now()); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// Merge moves the addresses, identifications, contacts and merchants of the
+// customer duplicateID to survivorID and deletes duplicateID, which then
+// redirects to survivorID
+func (s *Service) Merge(ctx context.Context, survivorID, duplicateID uint) error {
+	if survivorID == duplicateID {
+		return custom.NewValidationError("duplicate_id", "cannot be the surviving customer")
+	}
+	return s.repo.Merge(ctx, survivorID, duplicateID)
+}
+
+// Redirect returns the customer a merged ID was merged into, or custom.ErrNotFound
+func (s *Service) Redirect(ctx context.Context, id uint) (uint, error) {
+	return s.repo.Redirect(ctx, id)
+}
+
+// validate checks the rules shared by create and update on the fields that are set
+func (s *Service) validate(ctx context.Context, id uint, customer *customermodel.Customer) error {
+	if !customer.DateOfBirth.IsZero() && customer.DateOfBirth.After(s.now()) {
+		return custom.NewValidationError("date_of_birth", "cannot be in the future")
+	}
+
+	if tin := customer.TaxpayerIdentificationNumber; tin != "" {
+		exists, err := s.repo.ExistsByTIN(ctx, tin, id)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: taxpayer_identification_number is already registered", custom.ErrDuplicate)
+		}
+	}
+	return nil
+}
+
+func normalize(customer *customermodel.Customer) {
+	customer.Title = strings.TrimSpace(customer.Title)
+	customer.FullName = strings.TrimSpace(customer.FullName)
+	customer.LastName = strings.TrimSpace(customer.LastName)
+	customer.TaxpayerIdentificationNumber = strings.TrimSpace(customer.TaxpayerIdentificationNumber)
+}
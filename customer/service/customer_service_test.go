@@ -0,0 +1,122 @@
+package customerservice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sample/custom"
+	customermodel "sample/customer/model"
+	merchantmodel "sample/merchant/model"
+	"sample/servicetest"
+)
+
+func TestCreate(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	f.CreateCustomer(t, &customermodel.Customer{FullName: "Juan", LastName: "Dela Cruz", DateOfBirth: f.Now.AddDate(-30, 0, 0), TaxpayerIdentificationNumber: "123-456"})
+
+	tests := []struct {
+		name   string
+		change func(c *customermodel.Customer)
+		field  string
+	}{
+		{"blank full name", func(c *customermodel.Customer) { c.FullName = " " }, "full_name"},
+		{"no last name", func(c *customermodel.Customer) { c.LastName = "" }, "last_name"},
+		{"no date of birth", func(c *customermodel.Customer) { c.DateOfBirth = time.Time{} }, "date_of_birth"},
+		{"born tomorrow", func(c *customermodel.Customer) { c.DateOfBirth = f.Now.AddDate(0, 0, 1) }, "date_of_birth"},
+	}
+	for _, tt := range tests {
+		customer := f.Customer("Maria")
+		tt.change(customer)
+		if err := f.Customers.Create(ctx, customer); servicetest.ValidationField(err) != tt.field {
+			t.Errorf("%s: err = %v, want a validation error of %s", tt.name, err, tt.field)
+		}
+	}
+
+	duplicate := f.Customer("Maria")
+	duplicate.TaxpayerIdentificationNumber = " 123-456 "
+	if err := f.Customers.Create(ctx, duplicate); !errors.Is(err, custom.ErrDuplicate) {
+		t.Errorf("create with a registered TIN: err = %v, want ErrDuplicate", err)
+	}
+}
+
+func TestUpdateKeepsRequiredFields(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	customer := f.CreateCustomer(t, f.Customer("Juan"))
+
+	if _, err := f.Customers.Update(ctx, customer.ID, &customermodel.Customer{FullName: ""}, []string{"FullName"}); servicetest.ValidationField(err) != "full_name" {
+		t.Errorf("clearing the full name: err = %v, want a validation error of full_name", err)
+	}
+
+	// A patch leaves the fields it does not select
+	updated, err := f.Customers.Update(ctx, customer.ID, &customermodel.Customer{Title: " Mr "}, []string{"Title"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Title != "Mr" || updated.FullName != "Juan" {
+		t.Errorf("customer = %+v, want the title trimmed and the name kept", updated)
+	}
+}
+
+func TestUpdateRejectsNestedProducts(t *testing.T) {
+	f := servicetest.New()
+	customer := f.CreateCustomer(t, f.Customer("Juan"))
+
+	changes := &customermodel.Customer{Merchant: []merchantmodel.Merchant{
+		{Name: "Sari"},
+		{Name: "Bakery", Product: []merchantmodel.Product{{Name: "Bread", Quantity: -1}}},
+	}}
+	if _, err := f.Customers.Update(context.Background(), customer.ID, changes, nil); servicetest.ValidationField(err) != "merchant[1].product" {
+		t.Errorf("err = %v, want a validation error of merchant[1].product", err)
+	}
+}
+
+func TestCreateNormalizesContacts(t *testing.T) {
+	f := servicetest.New()
+	customer := f.Customer("Juan")
+	customer.Contacts = []customermodel.Contact{{OwnerPhoneNumber: "0917 123 4567", OwnerOtherPhoneNumber: "(02) 8123-4567", Email: " Juan@Example.COM "}}
+	f.CreateCustomer(t, customer)
+
+	stored, err := f.Customers.Get(context.Background(), customer.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contact := stored.Contacts[0]
+	if contact.OwnerPhoneNumber != "+639171234567" || contact.OwnerPhoneType != "mobile" ||
+		contact.OwnerOtherPhoneNumber != "+63281234567" || contact.OwnerOtherPhoneType != "landline" ||
+		contact.Email != "juan@example.com" {
+		t.Errorf("contact = %+v, want E.164 numbers with their types and a lowercase email", contact)
+	}
+
+	invalid := f.Customer("Maria")
+	invalid.Contacts = []customermodel.Contact{{OwnerPhoneNumber: "call me"}}
+	if err := f.Customers.Create(context.Background(), invalid); servicetest.ValidationField(err) != "owner_phone_number" {
+		t.Errorf("create with a bad phone: err = %v, want a validation error of owner_phone_number", err)
+	}
+}
+
+func TestDeleteKeepsUndeliveredProducts(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	customer := f.Customer("Juan")
+	customer.Merchant = []merchantmodel.Merchant{{Name: "Sari", Product: []merchantmodel.Product{
+		{Name: "Rice", Quantity: 5, DeliverDate: f.Now.Add(time.Hour)},
+	}}}
+	f.CreateCustomer(t, customer)
+
+	var conflict *custom.ConflictError
+	if err := f.Customers.Delete(ctx, customer.ID); !errors.As(err, &conflict) {
+		t.Fatalf("delete with a product to deliver: err = %v, want a conflict", err)
+	}
+
+	f.Now = f.Now.Add(2 * time.Hour)
+	if err := f.Customers.Delete(ctx, customer.ID); err != nil {
+		t.Fatalf("delete once delivered: %v", err)
+	}
+	if _, err := f.Customers.Get(ctx, customer.ID, nil); !errors.Is(err, custom.ErrNotFound) {
+		t.Errorf("get after delete: err = %v, want ErrNotFound", err)
+	}
+}
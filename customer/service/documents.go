@@ -0,0 +1,186 @@
+package customerservice
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sample/custom"
+	customermodel "sample/customer/model"
+	customerrepository "sample/customer/repository"
+	"sample/storage"
+)
+
+// DocumentTypes are the content types of the documents, as sniffed from
+// their first bytes whatever the client declared
+var DocumentTypes = []string{"application/pdf", "image/jpeg", "image/png", "image/webp"}
+
+// Errors of Documents.Upload
+var (
+	ErrDocumentType = errors.New("documents must be one of " + strings.Join(DocumentTypes, ", "))
+	ErrDocumentSize = errors.New("the document is too large")
+)
+
+// Documents holds the scans of the identifications: their rows in the
+// repository and their content in the storage, by checksum
+type Documents struct {
+	repo      customerrepository.DocumentRepository
+	customers *Service
+	storage   storage.Storage
+	maxSize   int64
+}
+
+// NewDocuments creates the Documents service, maxSize is the largest
+// document in bytes
+func NewDocuments(repo customerrepository.DocumentRepository, customers *Service, store storage.Storage, maxSize int64) *Documents {
+	return &Documents{repo: repo, customers: customers, storage: store, maxSize: maxSize}
+}
+
+// MaxSize is the largest document in bytes
+func (d *Documents) MaxSize() int64 {
+	return d.maxSize
+}
+
+// Upload stores a document of the identification of customerID. created is
+// false when the identification has a document with the same content
+// already, which is returned instead.
+func (d *Documents) Upload(ctx context.Context, customerID, identificationID uint, fileName string, size int64, content io.ReadSeeker) (document *customermodel.Document, created bool, err error) {
+	if _, err := d.customers.Identification(ctx, customerID, identificationID); err != nil {
+		return nil, false, err
+	}
+	if size > d.maxSize {
+		return nil, false, fmt.Errorf("%w, the limit is %d bytes", ErrDocumentSize, d.maxSize)
+	}
+	if size == 0 {
+		return nil, false, custom.NewValidationError("file", "is empty")
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false, err
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	if !slices.Contains(DocumentTypes, contentType) {
+		return nil, false, fmt.Errorf("%w, not %s", ErrDocumentType, contentType)
+	}
+
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return nil, false, err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	existing, err := d.repo.FindByChecksum(ctx, identificationID, checksum)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, custom.ErrNotFound) {
+		return nil, false, err
+	}
+
+	// The content is stored by checksum, once for every document sharing it
+	key := "documents/" + checksum[:2] + "/" + checksum
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, false, err
+	}
+	if err := d.storage.Put(ctx, key, content); err != nil {
+		return nil, false, err
+	}
+
+	document = &customermodel.Document{
+		IdentificationID: identificationID,
+		FileName:         baseName(fileName),
+		ContentType:      contentType,
+		Size:             size,
+		Checksum:         checksum,
+		StorageKey:       key,
+	}
+	if err := d.repo.Create(ctx, document); err != nil {
+		if errors.Is(err, custom.ErrDuplicate) {
+			// Uploaded concurrently
+			if existing, ferr := d.repo.FindByChecksum(ctx, identificationID, checksum); ferr == nil {
+				return existing, false, nil
+			}
+		}
+		return nil, false, errors.Join(err, d.Prune(ctx, document))
+	}
+	return document, true, nil
+}
+
+// List returns the documents of the identification of customerID
+func (d *Documents) List(ctx context.Context, customerID, identificationID uint) ([]customermodel.Document, error) {
+	if _, err := d.customers.Identification(ctx, customerID, identificationID); err != nil {
+		return nil, err
+	}
+	return d.repo.List(ctx, identificationID)
+}
+
+// Get returns the document id of the identification of customerID
+func (d *Documents) Get(ctx context.Context, customerID, identificationID, id uint) (*customermodel.Document, error) {
+	if _, err := d.customers.Identification(ctx, customerID, identificationID); err != nil {
+		return nil, err
+	}
+	document, err := d.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if document.IdentificationID != identificationID {
+		return nil, custom.ErrNotFound
+	}
+	return document, nil
+}
+
+// Delete removes the row of the document id of the identification of
+// customerID. Its content stays in the storage until Prune.
+func (d *Documents) Delete(ctx context.Context, customerID, identificationID, id uint) (*customermodel.Document, error) {
+	document, err := d.Get(ctx, customerID, identificationID, id)
+	if err != nil {
+		return nil, err
+	}
+	return document, d.repo.Delete(ctx, id)
+}
+
+// Prune removes the content of a deleted document from the storage unless
+// another document has it too
+func (d *Documents) Prune(ctx context.Context, document *customermodel.Document) error {
+	count, err := d.repo.CountByChecksum(ctx, document.Checksum)
+	if err != nil || count > 0 {
+		return err
+	}
+	return d.storage.Delete(ctx, document.StorageKey)
+}
+
+// Open returns the document id and its content, for the signed downloads
+// which carry no customer
+func (d *Documents) Open(ctx context.Context, id uint) (*customermodel.Document, io.ReadCloser, error) {
+	document, err := d.repo.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := d.storage.Open(ctx, document.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return document, content, nil
+}
+
+// baseName is the name of an uploaded file without the directories some
+// browsers send
+func baseName(fileName string) string {
+	name := filepath.Base(strings.ReplaceAll(strings.TrimSpace(fileName), "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
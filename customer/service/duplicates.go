@@ -0,0 +1,213 @@
+package customerservice
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	customermodel "sample/customer/model"
+	"sample/repository"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Candidate is a pair of customers that may be the same person. DuplicateID
+// is the newer of the two, the one to merge into CustomerID.
+type Candidate struct {
+	CustomerID  uint     `json:"customer_id"`
+	DuplicateID uint     `json:"duplicate_id"`
+	Score       float64  `json:"score"`
+	Reasons     []string `json:"reasons"`
+}
+
+// Weights of the matching attributes of a pair. They combine like the
+// chances of independent signs, 1 - (1-a)(1-b)..., so that the score stays
+// below 1 and every further match raises it.
+const (
+	weightTIN         = 0.9
+	weightEmail       = 0.7
+	weightPhone       = 0.6
+	weightName        = 0.6 // times the similarity of the names
+	weightDateOfBirth = 0.3
+
+	// minNameSimilarity is the similarity of two names counted as a match
+	minNameSimilarity = 0.5
+	// maxCandidates is how many candidates of a customer are scored, a
+	// common last name would make too many pairs
+	maxCandidates = 200
+)
+
+// person is what the duplicate scoring compares of a customer
+type person struct {
+	id          uint
+	tin         string
+	dateOfBirth string
+	name        string
+	lastName    string
+	emails      []string
+	phones      []string
+}
+
+// Duplicates returns the pairs of customers scoring at least minScore, best
+// first. Each customer is only compared with the candidates the repository
+// finds by its TIN, emails, phone numbers and last name.
+func (s *Service) Duplicates(ctx context.Context, minScore float64) ([]Candidate, error) {
+	candidates := []Candidate{}
+	err := s.repo.Each(ctx, repository.ListOptions{Preloads: []string{"Contacts"}}, 500, func(batch []customermodel.Customer) error {
+		for i := range batch {
+			matches, err := s.repo.Candidates(ctx, &batch[i], maxCandidates)
+			if err != nil {
+				return err
+			}
+			p := newPerson(batch[i])
+			for _, match := range matches {
+				if c := score(p, newPerson(match)); c.Score >= minScore {
+					candidates = append(candidates, c)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		if candidates[i].CustomerID != candidates[j].CustomerID {
+			return candidates[i].CustomerID < candidates[j].CustomerID
+		}
+		return candidates[i].DuplicateID < candidates[j].DuplicateID
+	})
+	return candidates, nil
+}
+
+func newPerson(c customermodel.Customer) person {
+	p := person{
+		id:       c.ID,
+		tin:      strings.ToUpper(strings.Join(strings.FieldsFunc(c.TaxpayerIdentificationNumber, notAlphanumeric), "")),
+		name:     normalizeName(c.FullName + " " + c.LastName),
+		lastName: normalizeName(c.LastName),
+	}
+	if !c.DateOfBirth.IsZero() {
+		p.dateOfBirth = c.DateOfBirth.Format("2006-01-02")
+	}
+	for _, contact := range c.Contacts {
+		if email := strings.ToLower(strings.TrimSpace(contact.Email)); email != "" {
+			p.emails = append(p.emails, email)
+		}
+		for _, phone := range []string{contact.OwnerPhoneNumber, contact.OwnerOtherPhoneNumber} {
+			if phone = phoneKey(phone); phone != "" {
+				p.phones = append(p.phones, phone)
+			}
+		}
+	}
+	return p
+}
+
+func score(a, b person) Candidate {
+	if a.id > b.id {
+		a, b = b, a
+	}
+	c := Candidate{CustomerID: a.id, DuplicateID: b.id, Reasons: []string{}}
+	apart := 1.0
+	match := func(reason string, weight float64) {
+		c.Reasons = append(c.Reasons, reason)
+		apart *= 1 - weight
+	}
+
+	if a.tin != "" && a.tin == b.tin {
+		match("taxpayer_identification_number", weightTIN)
+	}
+	if shared(a.emails, b.emails) {
+		match("email", weightEmail)
+	}
+	if shared(a.phones, b.phones) {
+		match("phone", weightPhone)
+	}
+	if similarity := trigramSimilarity(a.name, b.name); similarity >= minNameSimilarity {
+		match("name", weightName*similarity)
+	}
+	if a.dateOfBirth != "" && a.dateOfBirth == b.dateOfBirth {
+		match("date_of_birth", weightDateOfBirth)
+	}
+	c.Score = float64(int((1-apart)*1000+0.5)) / 1000
+	return c
+}
+
+func shared(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// normalizeName lowercases a name, drops its accents, e.g. Peña is pena,
+// and its punctuation
+func normalizeName(name string) string {
+	name, _, err := transform.String(stripMarks, name)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), notAlphanumeric), " ")
+}
+
+func notAlphanumeric(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// phoneKey is the last 10 digits of a phone number, so that 09171234567 and
+// +63 917 123 4567 compare equal. Numbers of less than 7 digits are ignored.
+func phoneKey(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+	switch {
+	case len(digits) < 7:
+		return ""
+	case len(digits) > 10:
+		return digits[len(digits)-10:]
+	}
+	return digits
+}
+
+// trigramSimilarity is the share of the trigrams of the words of a and b
+// they have in common, like pg_trgm
+func trigramSimilarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range ta {
+		if tb[t] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.Fields(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,131 @@
+package customerservice_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"sample/custom"
+	customermodel "sample/customer/model"
+	customerservice "sample/customer/service"
+	merchantmodel "sample/merchant/model"
+	"sample/servertest"
+	"sample/servicetest"
+)
+
+// backends are the customer services on the memory repositories and on
+// SQLite, the candidates and merges are queries of each
+func backends(t *testing.T) map[string]*customerservice.Service {
+	return map[string]*customerservice.Service{
+		"memory": servicetest.New().Customers,
+		"sqlite": servertest.New(t).Customers,
+	}
+}
+
+func person(fullName, lastName, tin string, contacts ...customermodel.Contact) *customermodel.Customer {
+	return &customermodel.Customer{
+		FullName: fullName, LastName: lastName, DateOfBirth: time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC),
+		TaxpayerIdentificationNumber: tin, Contacts: contacts,
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	for name, customers := range backends(t) {
+		ctx := context.Background()
+		people := []*customermodel.Customer{
+			person("Juan", "Dela Cruz", "111-111-111", customermodel.Contact{OwnerPhoneNumber: "0917 123 4567", Email: "juan@example.com"}),
+			person("Juan", "Dela Cruz", "222-222-222", customermodel.Contact{OwnerOtherPhoneNumber: "+63 917 123 4567", Email: "juan.dc@example.com"}),
+			person("Maria", "Santos", "123-456-789"),
+			// Born the same day, nothing else in common
+			person("Pedro", "Reyes", "333-333-333"),
+		}
+		for _, p := range people {
+			if err := customers.Create(ctx, p); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		got, err := customers.Duplicates(ctx, 0.5)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := []customerservice.Candidate{{
+			CustomerID: people[0].ID, DuplicateID: people[1].ID, Score: 0.888,
+			Reasons: []string{"phone", "name", "date_of_birth"},
+		}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: Duplicates = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	survivor := f.CreateCustomer(t, f.Customer("Juan"))
+	duplicate := f.Customer("Juan")
+	duplicate.Merchant = []merchantmodel.Merchant{{Name: "Sari", Product: []merchantmodel.Product{
+		{Name: "Rice", Quantity: 5, DeliverDate: f.Now.Add(time.Hour)},
+	}}}
+	f.CreateCustomer(t, duplicate)
+
+	if err := f.Customers.Merge(ctx, survivor.ID, survivor.ID); servicetest.ValidationField(err) != "duplicate_id" {
+		t.Errorf("merge into itself: err = %v, want a validation error of duplicate_id", err)
+	}
+	if err := f.Customers.Merge(ctx, survivor.ID, duplicate.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if to, err := f.Customers.Redirect(ctx, duplicate.ID); err != nil || to != survivor.ID {
+		t.Errorf("Redirect(%d) = %d, %v, want %d", duplicate.ID, to, err, survivor.ID)
+	}
+	merged, err := f.Customers.Get(ctx, survivor.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Merchant) != 1 || merged.Merchant[0].CustomerID != int(survivor.ID) {
+		t.Errorf("merchants of the survivor = %+v, want the merchant of the duplicate", merged.Merchant)
+	}
+
+	// The merchant moved with its products, they still keep the survivor
+	var conflict *custom.ConflictError
+	if err := f.Customers.Delete(ctx, survivor.ID); !errors.As(err, &conflict) {
+		t.Errorf("delete of the survivor: err = %v, want a conflict", err)
+	}
+}
+
+func TestMergeKeepsTheTIN(t *testing.T) {
+	for name, customers := range backends(t) {
+		ctx := context.Background()
+		survivor := person("Juan", "Dela Cruz", "")
+		duplicate := person("Juan", "Dela Cruz", "123-456-789")
+		other := person("Juan", "Dela Cruz", "987-654-321")
+		for _, c := range []*customermodel.Customer{survivor, duplicate, other} {
+			if err := customers.Create(ctx, c); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		if err := customers.Merge(ctx, survivor.ID, duplicate.ID); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		merged, err := customers.Get(ctx, survivor.ID, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if merged.TaxpayerIdentificationNumber != "123-456-789" {
+			t.Errorf("%s: TIN of the survivor = %q, want the one of the duplicate", name, merged.TaxpayerIdentificationNumber)
+		}
+
+		// Two TINs, one would be lost
+		var conflict *custom.ConflictError
+		if err := customers.Merge(ctx, survivor.ID, other.ID); !errors.As(err, &conflict) {
+			t.Errorf("%s: merge of another TIN: err = %v, want a conflict", name, err)
+		}
+		if _, err := customers.Get(ctx, other.ID, nil); err != nil {
+			t.Errorf("%s: the customer of the conflicting merge: %v, want it kept", name, err)
+		}
+	}
+}
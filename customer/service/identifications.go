@@ -0,0 +1,85 @@
+package customerservice
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"sample/audit"
+	"sample/custom"
+	customermodel "sample/customer/model"
+)
+
+// Identification returns the identification id of the customer customerID
+func (s *Service) Identification(ctx context.Context, customerID, id uint) (*customermodel.Identification, error) {
+	return s.repo.Identification(ctx, customerID, id)
+}
+
+// Review sets the verification status of the identification id of the
+// customer customerID to verified or rejected, with the actor of ctx as the
+// reviewer. An identification past its expiry date cannot be verified.
+func (s *Service) Review(ctx context.Context, customerID, id uint, status string) (*customermodel.Identification, error) {
+	if status != customermodel.IDVerified && status != customermodel.IDRejected {
+		return nil, custom.NewValidationError("status", "must be "+customermodel.IDVerified+" or "+customermodel.IDRejected)
+	}
+	identification, err := s.repo.Identification(ctx, customerID, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if status == customermodel.IDVerified && !identification.IDExpiryDate.IsZero() && identification.IDExpiryDate.Before(now) {
+		return nil, custom.NewValidationError("status", "the identification expired on "+identification.IDExpiryDate.Format(time.DateOnly))
+	}
+	if err := s.repo.Review(ctx, id, status, audit.Actor(ctx), now); err != nil {
+		return nil, err
+	}
+	return s.repo.Identification(ctx, customerID, id)
+}
+
+// Expiring returns a page of the customers with an identification expiring
+// in the next days, soonest first, with those identifications only, and how
+// many customers there are
+func (s *Service) Expiring(ctx context.Context, days, limit, offset int) ([]customermodel.Customer, int64, error) {
+	now := s.now()
+	return s.repo.Expiring(ctx, now, now.AddDate(0, 0, days), limit, offset)
+}
+
+// ExpireIdentifications marks the pending and verified identifications past
+// their expiry date expired, and returns how many
+func (s *Service) ExpireIdentifications(ctx context.Context) (int64, error) {
+	return s.repo.ExpireIdentifications(ctx, s.now())
+}
+
+// WatchExpiry runs ExpireIdentifications every interval until ctx is done,
+// the first time right away. A zero interval disables it.
+func (s *Service) WatchExpiry(ctx context.Context, log *slog.Logger, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ctx = audit.WithActor(ctx, "id-expiry")
+	expire := func() {
+		expired, err := s.ExpireIdentifications(ctx)
+		if err != nil {
+			log.WarnContext(ctx, "could not expire identifications", "error", err)
+			return
+		}
+		if expired > 0 {
+			log.InfoContext(ctx, "expired identifications", "count", expired)
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		expire()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				expire()
+			}
+		}
+	}()
+}
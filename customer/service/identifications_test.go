@@ -0,0 +1,66 @@
+package customerservice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"sample/audit"
+	"sample/custom"
+	customermodel "sample/customer/model"
+	"sample/servicetest"
+)
+
+func TestReview(t *testing.T) {
+	f := servicetest.New()
+	ctx := audit.WithActor(context.Background(), "reviewer")
+	customer := f.Customer("Juan")
+	customer.Identifications = []customermodel.Identification{
+		{IDType: "passport", IDNumber: "P1234567A", IDExpiryDate: f.Now.AddDate(1, 0, 0)},
+		{IDType: "drivers_license", IDNumber: "N01-23-456789", IDExpiryDate: f.Now.AddDate(0, 0, -1)},
+	}
+	f.CreateCustomer(t, customer)
+	valid, expired := customer.Identifications[0], customer.Identifications[1]
+	if valid.VerificationStatus != customermodel.IDPending {
+		t.Fatalf("status of a new identification = %q, want pending", valid.VerificationStatus)
+	}
+
+	if _, err := f.Customers.Review(ctx, customer.ID, valid.ID, customermodel.IDExpired); servicetest.ValidationField(err) != "status" {
+		t.Errorf("review to expired: err = %v, want a validation error of status", err)
+	}
+	if _, err := f.Customers.Review(ctx, customer.ID, expired.ID, customermodel.IDVerified); servicetest.ValidationField(err) != "status" {
+		t.Errorf("verification of an expired identification: err = %v, want a validation error of status", err)
+	}
+	if _, err := f.Customers.Review(ctx, customer.ID+1, valid.ID, customermodel.IDVerified); !errors.Is(err, custom.ErrNotFound) {
+		t.Errorf("review under another customer: err = %v, want ErrNotFound", err)
+	}
+
+	reviewed, err := f.Customers.Review(ctx, customer.ID, valid.ID, customermodel.IDVerified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reviewed.VerificationStatus != customermodel.IDVerified || reviewed.ReviewedBy != "reviewer" || reviewed.ReviewedAt == nil || !reviewed.ReviewedAt.Equal(f.Now) {
+		t.Errorf("reviewed = %+v, want verified by reviewer now", reviewed)
+	}
+
+	// Only the identification still to expire is listed
+	expiring, total, err := f.Customers.Expiring(ctx, 400, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || len(expiring) != 1 || len(expiring[0].Identifications) != 1 || expiring[0].Identifications[0].ID != valid.ID {
+		t.Errorf("Expiring = %+v (%d), want the customer with the passport only", expiring, total)
+	}
+
+	// The expired one goes, the verified one expires in a year
+	if n, err := f.Customers.ExpireIdentifications(ctx); err != nil || n != 1 {
+		t.Errorf("ExpireIdentifications = %d, %v, want 1", n, err)
+	}
+	f.Now = f.Now.AddDate(1, 0, 1)
+	if n, err := f.Customers.ExpireIdentifications(ctx); err != nil || n != 1 {
+		t.Errorf("ExpireIdentifications a year later = %d, %v, want 1", n, err)
+	}
+	if i, _ := f.Customers.Identification(ctx, customer.ID, valid.ID); i.VerificationStatus != customermodel.IDExpired {
+		t.Errorf("status a year later = %q, want expired", i.VerificationStatus)
+	}
+}
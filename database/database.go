@@ -2,30 +2,97 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"sample/config"
+	"time"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// InitDB initializes the database connection
-func InitDB() *gorm.DB {
-	// Define database connection parameters
-	const (
-		host     = "localhost" // Change to your local IP address
-		port     = 5432
-		user     = "postgres"
-		password = "postgres"
-		dbname   = "Sample"
-	)
-
-	// Initialize database connection
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// InitDB opens the database connection, retrying with exponential backoff
+// while the database is not reachable yet, and applies the pool settings
+func InitDB(ctx context.Context, cfg config.Config, log *slog.Logger) (*gorm.DB, error) {
+	dialector, err := newDialector(cfg)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return db
+	var db *gorm.DB
+	backoff := cfg.DBConnectBackoff
+	for attempt := 1; ; attempt++ {
+		db, err = open(ctx, dialector, cfg, log)
+		if err == nil {
+			break
+		}
+		if attempt >= cfg.DBConnectRetries {
+			return nil, fmt.Errorf("connect to %s@%s after %d attempts: %w", cfg.DBName, cfg.DBHost, attempt, err)
+		}
+
+		log.WarnContext(ctx, "database not reachable, retrying", "host", cfg.DBHost, "dbname", cfg.DBName, "attempt", attempt, "backoff", backoff.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+
+	log.InfoContext(ctx, "connected to database", "driver", cfg.DBDriver, "host", cfg.DBHost, "dbname", cfg.DBName)
+	return db, nil
+}
+
+// newDialector returns the driver of cfg.DBDriver
+func newDialector(cfg config.Config) (gorm.Dialector, error) {
+	switch cfg.DBDriver {
+	case "postgres":
+		return postgres.Open(fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName, cfg.DBSSLMode)), nil
+	case "sqlite":
+		// The cascades need the foreign keys, and the writers of the pool wait
+		// for each other instead of failing as busy
+		return sqlite.Open(cfg.DBName + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"), nil
+	default:
+		return nil, fmt.Errorf("DB_DRIVER %q: must be postgres or sqlite", cfg.DBDriver)
+	}
+}
+
+func open(ctx context.Context, dialector gorm.Dialector, cfg config.Config, log *slog.Logger) (*gorm.DB, error) {
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger: NewGormLogger(log, cfg.SlowQueryThreshold),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Close closes the connection pool behind db, waiting for queries in progress
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
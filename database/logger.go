@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// SensitiveColumns are the columns whose values never reach the SQL log nor
+// the audit log
+var SensitiveColumns = []string{"taxpayer_identification_number", "id_number"}
+
+// Redacted replaces the values of the SensitiveColumns
+const Redacted = "[REDACTED]"
+
+// GormLogger routes GORM traces to slog, tagged with the request ID of the context
+type GormLogger struct {
+	log           *slog.Logger
+	level         gormlogger.LogLevel
+	slowThreshold time.Duration
+	sensitive     map[string]bool
+}
+
+// NewGormLogger creates a GORM logger. Queries slower than slowThreshold are logged
+// as warnings, every other query at debug level.
+func NewGormLogger(log *slog.Logger, slowThreshold time.Duration) *GormLogger {
+	sensitive := make(map[string]bool, len(SensitiveColumns))
+	for _, column := range SensitiveColumns {
+		sensitive[column] = true
+	}
+	return &GormLogger{
+		log:           log,
+		level:         gormlogger.Info,
+		slowThreshold: slowThreshold,
+		sensitive:     sensitive,
+	}
+}
+
+// LogMode returns a copy of the logger with the given GORM level
+func (l *GormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	copied := *l
+	copied.level = level
+	return &copied
+}
+
+func (l *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		l.log.InfoContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		l.log.WarnContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		l.log.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+	}
+}
+
+// Trace is called by GORM after every statement
+func (l *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	CountQuery(ctx)
+
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level := slog.LevelDebug
+	msg := "query"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= gormlogger.Error:
+		level, msg = slog.LevelError, "query failed"
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= gormlogger.Warn:
+		level, msg = slog.LevelWarn, "slow query"
+	}
+	if !l.log.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("elapsed_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	l.log.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// ParamsFilter replaces the values bound to sensitive columns before GORM
+// renders the statement for Trace
+func (l *GormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	columns := placeholderColumns(sql, len(params))
+	filtered := make([]interface{}, len(params))
+	for i, param := range params {
+		if i < len(columns) && l.sensitive[columns[i]] {
+			filtered[i] = Redacted
+			continue
+		}
+		filtered[i] = param
+	}
+	return sql, filtered
+}
+
+var (
+	placeholderPattern = regexp.MustCompile(`\$\d+|\?`)
+	insertPattern      = regexp.MustCompile(`(?is)^\s*INSERT\s+INTO\s+\S+\s*\(([^)]*)\)\s*VALUES`)
+	comparedPattern    = regexp.MustCompile(`(?is)"?(\w+)"?\s*(?:=|<>|!=|<=|>=|<|>|\s(?:I?LIKE|IN\s*\([^)]*?))\s*$`)
+)
+
+// placeholderColumns works out which column each bound parameter belongs to.
+// Unknown positions are left empty.
+func placeholderColumns(sql string, count int) []string {
+	columns := make([]string, count)
+
+	var insertColumns []string
+	valuesStart := 0
+	if match := insertPattern.FindStringSubmatchIndex(sql); match != nil {
+		valuesStart = match[1]
+		for _, column := range strings.Split(sql[match[2]:match[3]], ",") {
+			insertColumns = append(insertColumns, strings.Trim(strings.TrimSpace(column), `"`+"`"))
+		}
+	}
+
+	sequential := 0
+	for _, loc := range placeholderPattern.FindAllStringIndex(sql, -1) {
+		index := sequential
+		if token := sql[loc[0]:loc[1]]; token != "?" {
+			n, err := strconv.Atoi(token[1:])
+			if err != nil {
+				continue
+			}
+			index = n - 1
+		}
+		sequential++
+		if index < 0 || index >= count {
+			continue
+		}
+
+		if insertColumns != nil && loc[0] >= valuesStart {
+			columns[index] = insertColumns[index%len(insertColumns)]
+			continue
+		}
+		if match := comparedPattern.FindStringSubmatch(sql[:loc[0]]); match != nil {
+			columns[index] = strings.ToLower(match[1])
+		}
+	}
+	return columns
+}
+
+type queryCounterKey struct{}
+
+// WithQueryCounter attaches a query counter to the context, see QueryCount
+func WithQueryCounter(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryCounterKey{}, new(atomic.Int64))
+}
+
+// CountQuery increments the query counter of the context, if any
+func CountQuery(ctx context.Context) {
+	if counter, ok := ctx.Value(queryCounterKey{}).(*atomic.Int64); ok {
+		counter.Add(1)
+	}
+}
+
+// QueryCount returns the number of queries run with the context so far
+func QueryCount(ctx context.Context) int64 {
+	if counter, ok := ctx.Value(queryCounterKey{}).(*atomic.Int64); ok {
+		return counter.Load()
+	}
+	return 0
+}
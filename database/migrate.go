@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned schema change, applied at most once
+type Migration struct {
+	ID      string
+	Migrate func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied Migration
+type SchemaMigration struct {
+	ID        string    `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// Migrate applies the migrations that have not run yet, in order, each in its own transaction
+func Migrate(ctx context.Context, db *gorm.DB, log *slog.Logger, migrations []Migration) error {
+	db = db.WithContext(ctx)
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if applied[migration.ID] {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Migrate(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{ID: migration.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", migration.ID, err)
+		}
+		log.InfoContext(ctx, "applied migration", "id", migration.ID)
+	}
+	return nil
+}
+
+// PendingMigrations returns the IDs of the migrations that have not been applied
+func PendingMigrations(ctx context.Context, db *gorm.DB, migrations []Migration) ([]string, error) {
+	applied, err := appliedMigrations(db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, migration := range migrations {
+		if !applied[migration.ID] {
+			pending = append(pending, migration.ID)
+		}
+	}
+	return pending, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[string]bool, error) {
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+
+	applied := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		applied[row.ID] = true
+	}
+	return applied, nil
+}
@@ -0,0 +1,47 @@
+package geocontroller
+
+import (
+	"sample/geo"
+	"sample/response"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Regions serves /api/geo/regions with the regions, by name
+func Regions() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return send(c, geo.Default().Regions())
+	}
+}
+
+// Children serves the areas of level under the area :code one level above,
+// e.g. /api/geo/regions/:code/provinces. A code of another level is not
+// found.
+func Children(level geo.Level) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		areas, ok := geo.Default().Children(c.Params("code"), level)
+		if !ok {
+			return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+				RetCode: string(response.NotFound),
+				Message: "Resource not found",
+				Data:    "no area with code " + c.Params("code"),
+			})
+		}
+		return send(c, areas)
+	}
+}
+
+func send(c fiber.Ctx, areas []geo.Area) error {
+	if len(areas) == 0 {
+		return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+			RetCode: string(response.NotFound),
+			Message: "No resource found",
+			Data:    []geo.Area{},
+		})
+	}
+	return response.Send(c, fiber.StatusOK, response.ErrorModel{
+		RetCode: string(response.SuccessOK),
+		Message: "success",
+		Data:    areas,
+	})
+}
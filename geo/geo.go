@@ -0,0 +1,356 @@
+package geo
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Level is a tier of the address hierarchy
+type Level string
+
+const (
+	Region       Level = "region"
+	Province     Level = "province"     // a province or an NCR district
+	Municipality Level = "municipality" // a city or a municipality
+	Barangay     Level = "barangay"
+)
+
+// Levels are the tiers from the top
+var Levels = []Level{Region, Province, Municipality, Barangay}
+
+// levelOf maps the PSGC geographic levels to the tiers
+var levelOf = map[string]Level{
+	"Reg":    Region,
+	"Prov":   Province,
+	"Dist":   Province,
+	"City":   Municipality,
+	"Mun":    Municipality,
+	"SubMun": Municipality,
+	"Bgy":    Barangay,
+}
+
+// Area is a region, province, municipality or barangay
+type Area struct {
+	Code        string   `json:"code"`
+	Name        string   `json:"name"`
+	Level       Level    `json:"level"`
+	Kind        string   `json:"kind"` // the PSGC geographic level, e.g. City or Mun
+	ParentCode  string   `json:"parent_code,omitempty"`
+	PostalCodes []string `json:"postal_codes,omitempty"`
+
+	aliases []string
+	postal  []postalRange
+}
+
+type postalRange struct{ from, to int }
+
+// Directory is the loaded PSGC data
+type Directory struct {
+	areas    map[string]*Area
+	children map[string][]*Area
+	names    map[Level]map[string][]*Area
+	// known tells, by area code, the levels of its descendants in the data
+	known map[string]map[Level]bool
+	// excerpt is set on the bundled data, which does not list every area
+	excerpt bool
+}
+
+//go:embed psgc.csv
+var bundled []byte
+
+var (
+	mu        sync.Mutex
+	current   *Directory
+	loadFirst sync.Once
+)
+
+// Default returns the directory the addresses are checked against: the one
+// given to SetDefault, or the bundled excerpt of the PSGC
+func Default() *Directory {
+	loadFirst.Do(func() {
+		d, err := Load(bytes.NewReader(bundled))
+		if err != nil {
+			panic(fmt.Sprintf("geo: bundled psgc.csv: %v", err))
+		}
+		d.excerpt = true
+		mu.Lock()
+		if current == nil {
+			current = d
+		}
+		mu.Unlock()
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
+
+// SetDefault replaces the directory returned by Default, e.g. with the full
+// PSGC publication
+func SetDefault(d *Directory) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = d
+}
+
+// Load reads a CSV file with the columns code, name, kind, postal_codes and
+// aliases, see psgc.csv. Lines starting with # are comments. The file is
+// taken as the complete PSGC, see Normalize.
+func Load(r io.Reader) (*Directory, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 5
+	if _, err := cr.Read(); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	d := &Directory{
+		areas:    map[string]*Area{},
+		children: map[string][]*Area{},
+		names:    map[Level]map[string][]*Area{},
+		known:    map[string]map[Level]bool{},
+	}
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		area, err := parseArea(record)
+		if err != nil {
+			line, _ := cr.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if _, ok := d.areas[area.Code]; ok {
+			return nil, fmt.Errorf("code %s is listed twice", area.Code)
+		}
+		d.areas[area.Code] = area
+	}
+
+	for _, area := range d.areas {
+		if area.Level == Region {
+			continue
+		}
+		parent, ok := d.areas[area.ParentCode]
+		if !ok {
+			return nil, fmt.Errorf("%s %s has no parent %s", area.Code, area.Name, area.ParentCode)
+		}
+		d.children[parent.Code] = append(d.children[parent.Code], area)
+	}
+	for _, area := range d.areas {
+		for _, key := range keys(area) {
+			if d.names[area.Level] == nil {
+				d.names[area.Level] = map[string][]*Area{}
+			}
+			d.names[area.Level][key] = append(d.names[area.Level][key], area)
+		}
+		for ancestor := d.parent(area); ancestor != nil; ancestor = d.parent(ancestor) {
+			if d.known[ancestor.Code] == nil {
+				d.known[ancestor.Code] = map[Level]bool{}
+			}
+			d.known[ancestor.Code][area.Level] = true
+		}
+	}
+	for _, list := range d.children {
+		sortAreas(list)
+	}
+	return d, nil
+}
+
+// LoadFile loads the CSV file at path, see Load
+func LoadFile(path string) (*Directory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+func parseArea(record []string) (*Area, error) {
+	code, name, kind := strings.TrimSpace(record[0]), strings.TrimSpace(record[1]), strings.TrimSpace(record[2])
+	digits := strings.Trim(code, "0123456789") == ""
+	if digits && len(code) == 10 {
+		return nil, fmt.Errorf("code %q is a 10-digit PSGC code, the codes are the 9-digit ones of psgc.csv", code)
+	}
+	if !digits || len(code) != 9 {
+		return nil, fmt.Errorf("code %q is not 9 digits", code)
+	}
+	level, ok := levelOf[kind]
+	if !ok {
+		return nil, fmt.Errorf("kind %q is not a PSGC geographic level", kind)
+	}
+	area := &Area{Code: code, Name: name, Level: level, Kind: kind}
+	switch level {
+	case Province:
+		area.ParentCode = code[:2] + "0000000"
+	case Municipality:
+		area.ParentCode = code[:4] + "00000"
+	case Barangay:
+		area.ParentCode = code[:6] + "000"
+	}
+
+	for _, postal := range split(record[3]) {
+		from, to, _ := strings.Cut(postal, "-")
+		if to == "" {
+			to = from
+		}
+		r := postalRange{}
+		var err1, err2 error
+		r.from, err1 = strconv.Atoi(from)
+		r.to, err2 = strconv.Atoi(to)
+		if err1 != nil || err2 != nil || r.from > r.to {
+			return nil, fmt.Errorf("postal code %q is not a code or a range", postal)
+		}
+		area.postal = append(area.postal, r)
+		area.PostalCodes = append(area.PostalCodes, postal)
+	}
+	area.aliases = split(record[4])
+	return area, nil
+}
+
+func split(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Get returns the area with code
+func (d *Directory) Get(code string) (Area, bool) {
+	area, ok := d.areas[code]
+	if !ok {
+		return Area{}, false
+	}
+	return *area, true
+}
+
+// Regions lists the regions by name
+func (d *Directory) Regions() []Area {
+	var list []*Area
+	for _, area := range d.areas {
+		if area.Level == Region {
+			list = append(list, area)
+		}
+	}
+	sortAreas(list)
+	return values(list)
+}
+
+// Children lists the areas of level directly under code, by name. ok is
+// false when code is not an area one level above.
+func (d *Directory) Children(code string, level Level) (areas []Area, ok bool) {
+	parent, found := d.areas[code]
+	if !found || parent.Level != above(level) {
+		return nil, false
+	}
+	return values(d.children[code]), true
+}
+
+// postal returns the area whose postal codes apply to area: itself, or the
+// municipality of a barangay without its own. Nil when none has any.
+func (d *Directory) postal(area *Area) *Area {
+	for ; area != nil; area = d.parent(area) {
+		if len(area.postal) > 0 {
+			return area
+		}
+	}
+	return nil
+}
+
+func (d *Directory) parent(area *Area) *Area {
+	if area.ParentCode == "" {
+		return nil
+	}
+	return d.areas[area.ParentCode]
+}
+
+// above is the level over level, empty for a region
+func above(level Level) Level {
+	for i, l := range Levels {
+		if l == level && i > 0 {
+			return Levels[i-1]
+		}
+	}
+	return ""
+}
+
+func sortAreas(list []*Area) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Code < list[j].Code
+	})
+}
+
+func values(list []*Area) []Area {
+	areas := make([]Area, len(list))
+	for i, area := range list {
+		areas[i] = *area
+	}
+	return areas
+}
+
+// keys are the forms of the names of area free text is matched on
+func keys(area *Area) []string {
+	seen := map[string]bool{}
+	var list []string
+	for _, name := range append([]string{area.Name, area.Code}, area.aliases...) {
+		for _, key := range []string{nameKey(name), shortKey(name)} {
+			if key != "" && !seen[key] {
+				seen[key] = true
+				list = append(list, key)
+			}
+		}
+	}
+	return list
+}
+
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// nameKey lowercases a name and drops its accents and punctuation, so that
+// "Las Pinas" matches "Las Piñas"
+func nameKey(name string) string {
+	name, _, err := transform.String(stripMarks, name)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// shortKey is the nameKey without "city" and "of", so that "Makati City"
+// matches "City of Makati"
+func shortKey(name string) string {
+	var words []string
+	for _, word := range strings.Fields(nameKey(name)) {
+		if word != "city" && word != "of" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,158 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sample/custom"
+)
+
+// Place is the location of an address, as stored by the address models
+type Place struct {
+	Region       string
+	Province     string
+	Municipality string
+	Barangay     string
+	PostalCode   string
+}
+
+// fields are the JSON names of the address fields of the levels
+var fields = map[Level]string{
+	Region:       "region",
+	Province:     "province",
+	Municipality: "municipality",
+	Barangay:     "barangays",
+}
+
+// Normalize checks a place against the hierarchy and replaces its parts by
+// their PSGC codes. A part may be a code, a name or an alias, in any case
+// and with or without accents; the parts above the deepest one given are
+// filled in. A part below what the data lists, e.g. the barangays of a city
+// whose barangays are not loaded, is kept as written. So is a part the
+// bundled excerpt does not list, e.g. the City of Manila, with the parts
+// below it: only a complete PSGC loaded through Load rejects the names it
+// does not know. The postal code must be one of the municipality.
+func (d *Directory) Normalize(p *Place) error {
+	parts := []struct {
+		level Level
+		value *string
+	}{
+		{Region, &p.Region},
+		{Province, &p.Province},
+		{Municipality, &p.Municipality},
+		{Barangay, &p.Barangay},
+	}
+
+	var deepest *Area
+	for _, part := range parts {
+		*part.value = strings.TrimSpace(*part.value)
+		if *part.value == "" {
+			continue
+		}
+		if deepest != nil && !d.known[deepest.Code][part.level] {
+			// The data does not go this deep, nor further: a name known
+			// elsewhere, e.g. Poblacion, may be one of the missing areas
+			break
+		}
+		area, err := d.resolve(part.level, *part.value, deepest)
+		var unlisted unlistedError
+		if d.excerpt && errors.As(err, &unlisted) {
+			break
+		}
+		if err != nil {
+			return custom.NewValidationError(fields[part.level], err.Error())
+		}
+		deepest = area
+	}
+
+	for area := deepest; area != nil; area = d.parent(area) {
+		for _, part := range parts {
+			if part.level == area.Level {
+				*part.value = area.Code
+			}
+		}
+	}
+
+	p.PostalCode = strings.TrimSpace(p.PostalCode)
+	if p.PostalCode == "" {
+		return nil
+	}
+	code, err := strconv.Atoi(p.PostalCode)
+	if err != nil || len(p.PostalCode) != 4 {
+		return custom.NewValidationError("postal_code", "must be 4 digits")
+	}
+	if deepest == nil || deepest.Level != Municipality && deepest.Level != Barangay {
+		return nil
+	}
+	if owner := d.postal(deepest); owner != nil && !inRanges(owner.postal, code) {
+		return custom.NewValidationError("postal_code", "is not a postal code of "+owner.Name)
+	}
+	return nil
+}
+
+// resolve finds the area of level written value under parent, anywhere
+// when parent is nil
+func (d *Directory) resolve(level Level, value string, parent *Area) (*Area, error) {
+	if area, ok := d.areas[value]; ok && area.Level == level && d.within(area, parent) {
+		return area, nil
+	}
+
+	var found []*Area
+	seen := map[string]bool{}
+	candidates := append(d.names[level][nameKey(value)], d.names[level][shortKey(value)]...)
+	for _, area := range candidates {
+		if !seen[area.Code] {
+			seen[area.Code] = true
+			found = append(found, area)
+		}
+	}
+	if len(found) == 0 {
+		return nil, unlistedError(fmt.Sprintf("is not a known %s", level))
+	}
+
+	var inside []*Area
+	for _, area := range found {
+		if d.within(area, parent) {
+			inside = append(inside, area)
+		}
+	}
+	switch len(inside) {
+	case 0:
+		return nil, unlistedError("is not in " + parent.Name)
+	case 1:
+		return inside[0], nil
+	}
+	return nil, fmt.Errorf("matches %d %s areas, give the %s or the PSGC code", len(inside), level, above(level))
+}
+
+// unlistedError is a part of a place that is not in the data where the
+// place puts it
+type unlistedError string
+
+func (e unlistedError) Error() string {
+	return string(e)
+}
+
+// within reports whether area is under parent, always true without parent
+func (d *Directory) within(area, parent *Area) bool {
+	if parent == nil {
+		return true
+	}
+	for a := d.parent(area); a != nil; a = d.parent(a) {
+		if a == parent {
+			return true
+		}
+	}
+	return false
+}
+
+func inRanges(ranges []postalRange, code int) bool {
+	for _, r := range ranges {
+		if code >= r.from && code <= r.to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+package geo
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"sample/custom"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		place Place
+		want  Place
+	}{
+		{"names", Place{Region: "metro manila", Municipality: "Makati City"},
+			Place{Region: "130000000", Province: "137600000", Municipality: "137602000"}},
+		{"accents and aliases", Place{Municipality: "Las Pinas", PostalCode: "1740"},
+			Place{Region: "130000000", Province: "137600000", Municipality: "137601000", PostalCode: "1740"}},
+		{"codes", Place{Municipality: "137606000", Barangay: "sto. rosario-kanluran"},
+			Place{Region: "130000000", Province: "137600000", Municipality: "137606000", Barangay: "137606008"}},
+		{"barangays not loaded", Place{Municipality: "Quezon City", Barangay: "Bagong Silangan"},
+			Place{Region: "130000000", Province: "137400000", Municipality: "137404000", Barangay: "Bagong Silangan"}},
+
+		// The excerpt does not list these, they are kept as written
+		{"unlisted city", Place{Region: "NCR", Municipality: "City of Manila"},
+			Place{Region: "130000000", Municipality: "City of Manila"}},
+		{"unlisted province", Place{Region: "Region III", Province: "Tarlac"},
+			Place{Region: "030000000", Province: "Tarlac"}},
+		{"unlisted province and below", Place{Region: "Region VII", Province: "Negros Oriental", Municipality: "Dumaguete"},
+			Place{Region: "070000000", Province: "Negros Oriental", Municipality: "Dumaguete"}},
+		{"unlisted city of a listed province", Place{Province: "Cebu", Municipality: "Lapu-Lapu City"},
+			Place{Region: "070000000", Province: "072200000", Municipality: "Lapu-Lapu City"}},
+		{"known elsewhere", Place{Province: "Cebu", Municipality: "San Juan"},
+			Place{Region: "070000000", Province: "072200000", Municipality: "San Juan"}},
+	}
+	for _, tt := range tests {
+		place := tt.place
+		if err := Default().Normalize(&place); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if place != tt.want {
+			t.Errorf("%s: %+v, want %+v", tt.name, place, tt.want)
+		}
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		place Place
+		field string
+	}{
+		{"postal code of another city", Place{Municipality: "Makati", PostalCode: "1100"}, "postal_code"},
+		{"postal code not 4 digits", Place{Municipality: "Makati", PostalCode: "12345"}, "postal_code"},
+	}
+	for _, tt := range tests {
+		place := tt.place
+		err := Default().Normalize(&place)
+		var validationErr *custom.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+			t.Errorf("%s: err = %v, want a validation error of %s", tt.name, err, tt.field)
+		}
+	}
+}
+
+func TestNormalizeCompleteDataRejectsUnknownNames(t *testing.T) {
+	complete, err := Load(bytes.NewReader(bundled))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		place Place
+		field string
+	}{
+		{Place{Region: "NCR", Municipality: "City of Manila"}, "municipality"},
+		{Place{Region: "Region III", Province: "Tarlac"}, "province"},
+		{Place{Province: "Cebu", Municipality: "San Juan"}, "municipality"},
+		{Place{Region: "Region VII", Municipality: "Makati"}, "municipality"},
+	}
+	for _, tt := range tests {
+		place := tt.place
+		err := complete.Normalize(&place)
+		var validationErr *custom.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+			t.Errorf("%+v: err = %v, want a validation error of %s", tt.place, err, tt.field)
+		}
+	}
+}
+
+func TestLoadRejectsTenDigitCodes(t *testing.T) {
+	file := "code,name,kind,postal_codes,aliases\n1700000000,MIMAROPA Region,Reg,,\n"
+	if _, err := Load(strings.NewReader(file)); err == nil || !strings.Contains(err.Error(), "10-digit") {
+		t.Errorf("Load of a 10-digit code: err = %v, want it named", err)
+	}
+}
@@ -0,0 +1,128 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"sample/database"
+	"sample/response"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Check reports whether one dependency is usable
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one Check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Health runs the readiness checks and tracks whether the app is draining
+type Health struct {
+	checks   map[string]Check
+	order    []string
+	timeout  time.Duration
+	draining atomic.Bool
+}
+
+// New creates a Health whose checks each get timeout to complete
+func New(timeout time.Duration) *Health {
+	return &Health{checks: make(map[string]Check), timeout: timeout}
+}
+
+// Add registers a readiness check under name
+func (h *Health) Add(name string, check Check) {
+	if _, ok := h.checks[name]; !ok {
+		h.order = append(h.order, name)
+	}
+	h.checks[name] = check
+}
+
+// Drain makes readiness fail so the orchestrator stops sending traffic
+func (h *Health) Drain() {
+	h.draining.Store(true)
+}
+
+// Liveness only tells that the process is serving requests
+func (h *Health) Liveness() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "alive",
+			Data:    fiber.Map{"status": "ok"},
+		})
+	}
+}
+
+// Readiness runs every check and answers 503 if any fails or the app is draining
+func (h *Health) Readiness() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		results := make(map[string]CheckResult, len(h.checks)+1)
+		ready := true
+
+		if h.draining.Load() {
+			ready = false
+			results["draining"] = CheckResult{Status: "fail", Error: "shutting down"}
+		}
+
+		for _, name := range h.order {
+			ctx, cancel := context.WithTimeout(c.UserContext(), h.timeout)
+			start := time.Now()
+			err := h.checks[name](ctx)
+			cancel()
+
+			result := CheckResult{Status: "ok", LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				ready = false
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+			results[name] = result
+		}
+
+		if !ready {
+			return response.Send(c, fiber.StatusServiceUnavailable, response.ErrorModel{
+				RetCode: string(response.ServiceUnavailable),
+				Message: "not ready",
+				Data:    fiber.Map{"status": "fail", "checks": results},
+			})
+		}
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "ready",
+			Data:    fiber.Map{"status": "ok", "checks": results},
+		})
+	}
+}
+
+// DatabaseCheck pings the connection pool behind db
+func DatabaseCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// MigrationsCheck fails while any of migrations is not applied
+func MigrationsCheck(db *gorm.DB, migrations []database.Migration) Check {
+	return func(ctx context.Context) error {
+		pending, err := database.PendingMigrations(ctx, db, migrations)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,94 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"sample/database"
+	"sample/health"
+
+	"github.com/glebarez/sqlite"
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// probe answers GET /readyz and returns the status and the state of each check
+func probe(t *testing.T, app *fiber.App) (int, map[string]string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", "/readyz", nil), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data struct {
+			Status string                        `json:"status"`
+			Checks map[string]health.CheckResult `json:"checks"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]string{}
+	for name, result := range body.Data.Checks {
+		checks[name] = result.Status
+	}
+	return resp.StatusCode, checks
+}
+
+func TestReadiness(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(t.TempDir()+"/health.db"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations := []database.Migration{{ID: "0001_things", Migrate: func(tx *gorm.DB) error {
+		return tx.Exec("CREATE TABLE things (id integer PRIMARY KEY)").Error
+	}}}
+
+	h := health.New(time.Second)
+	h.Add("database", health.DatabaseCheck(db))
+	h.Add("migrations", health.MigrationsCheck(db, migrations))
+	app := fiber.New()
+	app.Get("/readyz", h.Readiness())
+
+	tests := []struct {
+		name   string
+		before func()
+		status int
+		checks map[string]string
+	}{
+		{"migrations pending", func() {}, 503, map[string]string{"database": "ok", "migrations": "fail"}},
+		{"migrated", func() {
+			if err := database.Migrate(context.Background(), db, slog.New(slog.NewTextHandler(io.Discard, nil)), migrations); err != nil {
+				t.Fatal(err)
+			}
+		}, 200, map[string]string{"database": "ok", "migrations": "ok"}},
+		{"draining", h.Drain, 503, map[string]string{"draining": "fail", "database": "ok", "migrations": "ok"}},
+		{"database closed", func() {
+			sqlDB, err := db.DB()
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlDB.Close()
+		}, 503, map[string]string{"draining": "fail", "database": "fail", "migrations": "fail"}},
+	}
+	for _, tt := range tests {
+		tt.before()
+		status, checks := probe(t, app)
+		if status != tt.status || len(checks) != len(tt.checks) {
+			t.Errorf("%s: %d %v, want %d %v", tt.name, status, checks, tt.status, tt.checks)
+			continue
+		}
+		for name, want := range tt.checks {
+			if checks[name] != want {
+				t.Errorf("%s: %d %v, want %d %v", tt.name, status, checks, tt.status, tt.checks)
+				break
+			}
+		}
+	}
+}
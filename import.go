@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"sample/audit"
+	"sample/config"
+	"sample/script"
+	"sample/server"
+	"sample/spreadsheet"
+)
+
+// runImport imports a spreadsheet into customers or merchants, see
+// script.Importer:
+//
+//	sample import -resource customer [-mapping mapping.json] [-dry-run] [-report errors.csv] customers.xlsx
+//
+// The exit code is 0 when every item was imported, 2 when some failed and 1
+// when the import could not run.
+func runImport(ctx context.Context, cfg config.Config, log *slog.Logger, args []string) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	resource := flags.String("resource", "", "customer or merchant")
+	mappingFile := flags.String("mapping", "", "JSON file of the column mapping, the headers are the field paths without it")
+	format := flags.String("format", "", "csv or xlsx, from the file extension by default")
+	dryRun := flags.Bool("dry-run", false, "validate and roll back instead of importing")
+	batchSize := flags.Int("batch-size", script.DefaultImportBatchSize, "items per transaction")
+	reportFile := flags.String("report", "", "write the per-row errors to this CSV file")
+	actor := flags.String("actor", "import", "who the audit log records the import for")
+	if err := flags.Parse(args); err != nil {
+		return 1
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: sample import -resource customer|merchant [flags] FILE")
+		flags.PrintDefaults()
+		return 1
+	}
+
+	report, err := importFile(ctx, cfg, log, flags.Arg(0), *resource, *mappingFile, *format, *actor, script.ImportOptions{DryRun: *dryRun, BatchSize: *batchSize})
+	if report != nil {
+		summary, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(summary))
+		if *reportFile != "" {
+			if werr := writeReport(*reportFile, report); werr != nil {
+				err = errors.Join(err, werr)
+			}
+		}
+	}
+	if err != nil {
+		log.Error("import failed", "error", err)
+		return 1
+	}
+	if report.Failed > 0 {
+		return 2
+	}
+	return 0
+}
+
+func importFile(ctx context.Context, cfg config.Config, log *slog.Logger, path, resource, mappingFile, format, actor string, opts script.ImportOptions) (*script.ImportReport, error) {
+	var mapping script.Mapping
+	if mappingFile != "" {
+		raw, err := os.ReadFile(mappingFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, &mapping); err != nil {
+			return nil, fmt.Errorf("mapping %s: %w", mappingFile, err)
+		}
+	}
+
+	var f spreadsheet.Format
+	var err error
+	if format != "" {
+		f, err = spreadsheet.ParseFormat(format)
+	} else {
+		f, err = spreadsheet.FormatOf(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := spreadsheet.NewReader(file, f)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	srv, err := server.New(ctx, server.WithConfig(cfg), server.WithLogger(log), server.WithoutJobs())
+	if err != nil {
+		return nil, err
+	}
+	defer srv.Shutdown(context.Background())
+
+	importer, ok := srv.Importers()[resource]
+	if !ok {
+		return nil, fmt.Errorf("cannot import into %q, only customer or merchant", resource)
+	}
+	return importer.Import(audit.WithActor(ctx, actor), rows, mapping, opts)
+}
+
+func writeReport(path string, report *script.ImportReport) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteCSV(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
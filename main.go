@@ -1,33 +1,43 @@
 package main
 
 import (
-	"sample/database"
-	// merchantmodel "sample/merchant/model"
-	// personmodel "sample/person/model"
-
-	"sample/routes"
-
-	"log"
-
-	"github.com/gofiber/fiber/v3"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"sample/config"
+	"sample/logger"
+	"sample/server"
 )
 
 func main() {
-
-	// Create a new Fiber app with the custom validator
-	app := fiber.New()
-
-	// Initialize the database connection
-	db := database.InitDB()
-
-// 	if err := db.AutoMigrate(&personmodel.Customer{}, &personmodel.Address{}, &personmodel.Identification{}, &personmodel.Contact{}, &merchantmodel.Merchant{}, &merchantmodel.Product{}, &merchantmodel.ContactMerchant{}, merchantmodel.AddressMerchant{},  ); err != nil {
-// log.Println("migrate", err)
-// 	}; log.Println("success")
-	
-	// Setup routes
-	routes.SetupRoutes(app, db)
-
-	// Start the Fiber app
-	log.Fatal(app.Listen(":3000"))
+	cfg := config.Load()
+	log := logger.New(cfg.LogLevel)
+
+	// Serve until SIGINT or SIGTERM, then drain in-flight requests
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		code := runImport(ctx, cfg, log, os.Args[2:])
+		stop()
+		os.Exit(code)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		code := runBackfill(ctx, cfg, log, os.Args[2:])
+		stop()
+		os.Exit(code)
+	}
+
+	srv, err := server.New(ctx, server.WithConfig(cfg), server.WithLogger(log))
+	if err != nil {
+		log.Error("could not start server", "error", err)
+		os.Exit(1)
+	}
+
+	if err := srv.Run(ctx, ":"+cfg.Port); err != nil {
+		log.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
 }
-
@@ -1,85 +1,41 @@
 package merchantcontroller
 
 import (
+	"sample/audit"
 	merchantmodel "sample/merchant/model"
-	"sample/response"
+	merchantservice "sample/merchant/service"
 	"sample/script"
-
-	"github.com/gofiber/fiber/v3"
-	"gorm.io/gorm"
 )
 
-func GetAllProduct(db *gorm.DB) fiber.Handler {
-	return func (c fiber.Ctx) error  {
-		var product []merchantmodel.Product
-
-		if err := db.Find(&product).Error; err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(response.ErrorModel{
-				RetCode: string(response.BadRequest),
-				Message: "Invalid product data",
-				Data: err,
-			})
-		} 
-
-		if err := db.Find(&product).Error; err != nil {
-			return c.Status(fiber.StatusNotFound).JSON(response.ErrorModel{
-				RetCode: string(response.NotFound),
-				Message: "Could not retrieve data",
-				Data: err,
-			})
-		}
-
-		if len(product) == 0 {
-			return c.Status(fiber.StatusNotFound).JSON(response.ErrorModel{
-				RetCode: string(response.NotFound),
-				Message: "Could not retrieve data",
-				Data: fiber.ErrNotFound,
-			}) }
-
-		return c.Status(fiber.StatusOK).JSON(product)
+// MerchantResource defines the /api/merchant endpoints, with the products of
+// a merchant nested under /:id/product
+func MerchantResource(merchants *merchantservice.MerchantService, products *merchantservice.ProductService) script.Resource[merchantmodel.Merchant] {
+	return script.Resource[merchantmodel.Merchant]{
+		Name:       "merchant",
+		Importable: true,
+		Service:    merchants,
+		Includes: map[string]string{
+			"address_merchant": "AddressMerchant",
+			"contact_merchant": "ContactMerchant",
+			"product":          "Product",
+		},
+		DefaultIncludes: []string{"address_merchant", "contact_merchant", "product"},
+		Filters:         append([]string{"id", "customer_id", "name"}, audit.StampFields...),
+		Sort:            append([]string{"id", "customer_id", "name"}, audit.StampFields...),
+		// One line per product, for the finance dumps
+		Export: []string{"id", "customer_id", "name", "product.id", "product.name", "product.quantity", "product.date_of_delivery", "created_at", "updated_at"},
+		Children: []script.Child{
+			script.Nested("product", "merchant_id", ProductResource(products)),
+		},
 	}
 }
 
-func CreateProduct(db *gorm.DB) fiber.Handler {
-	return func(c fiber.Ctx) error {
-		var	product     merchantmodel.Product      
-
-		// Use the generic function to create the person and related resources
-		return script.CreateResource(db, &product)(c)
-	}
-}
-
-func CreateMerchant(db *gorm.DB) fiber.Handler {
-	return func(c fiber.Ctx) error {
-		// Main model
-		var merchant merchantmodel.Merchant
-		var AddressMerchant      merchantmodel.AddressMerchant      
-		var	ContactMerchant merchantmodel.ContactMerchant
-		var	Product     merchantmodel.Product      
-
-		// Use the generic function to create the person and related resources
-		return script.CreateResource(db, &merchant, 
-			&AddressMerchant, 
-			&ContactMerchant, 
-			&Product)(c)
+// ProductResource defines the /api/product endpoints
+func ProductResource(products *merchantservice.ProductService) script.Resource[merchantmodel.Product] {
+	return script.Resource[merchantmodel.Product]{
+		Name:    "product",
+		Service: products,
+		Filters: append([]string{"id", "merchant_id", "name", "quantity", "date_of_delivery"}, audit.StampFields...),
+		Sort:    append([]string{"id", "merchant_id", "name", "quantity", "date_of_delivery"}, audit.StampFields...),
 	}
 }
-
-func GetAllMerchant(db *gorm.DB) fiber.Handler {
-	return script.GetAllResources[merchantmodel.Merchant](db, []string{"AddressMerchant", "ContactMerchant", "Product"})
-}
-
-
-func GetMerchantByID(db *gorm.DB) fiber.Handler {
-	return script.GetResourceByID[merchantmodel.Merchant](db, []string{"AddressMerchant", "ContactMerchant", "Product"})
-}
-
-func UpdateMerchant(db *gorm.DB) fiber.Handler {
-	var merchant merchantmodel.Merchant
-	return script.UpdateResource[merchantmodel.Merchant](db,&merchant)
-}
-
-func DeleteMerchant(db *gorm.DB) fiber.Handler {
-	return script.DeleteResource[merchantmodel.Merchant](db)
-}
-
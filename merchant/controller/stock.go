@@ -0,0 +1,126 @@
+package merchantcontroller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+
+	"sample/audit"
+	"sample/custom"
+	merchantmodel "sample/merchant/model"
+	merchantservice "sample/merchant/service"
+	"sample/repository"
+	"sample/response"
+	"sample/script"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxStockPageSize caps page_size on GET /api/product/:id/stock
+const MaxStockPageSize = 100
+
+// StockRequest is the body of POST /api/product/:id/stock
+type StockRequest struct {
+	Kind     string `json:"kind"`     // receipt, sale, adjustment or return
+	Quantity int    `json:"quantity"` // units moved, signed for an adjustment
+	Reason   string `json:"reason"`
+}
+
+// StockPage is the data of GET /api/product/:id/stock
+type StockPage struct {
+	Total     int64                         `json:"total"`
+	Movements []merchantmodel.StockMovement `json:"movements"`
+}
+
+// Move serves POST /api/product/:id/stock, which records a stock movement of
+// the product, see merchantservice.ProductService.Move. The movement is
+// audited on the product with its quantity before and after.
+func Move(products *merchantservice.ProductService, tx repository.Transactor, log *audit.Log, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		var body StockRequest
+		if _, err := script.BindBody(c, &body); err != nil {
+			return script.BodyErrorResponse(c, err)
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		var movement *merchantmodel.StockMovement
+		err = tx.Transaction(ctx, func(ctx context.Context) error {
+			if movement, err = products.Move(ctx, uint(id), body.Kind, body.Quantity, body.Reason); err != nil {
+				return err
+			}
+			if log == nil {
+				return nil
+			}
+			before := merchantmodel.Product{Quantity: movement.Balance - movement.Quantity}
+			after := merchantmodel.Product{Quantity: movement.Balance}
+			return log.Record(ctx, "product", uint(id), audit.ActionMove, before, after)
+		})
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not move the stock")
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "Success Insert",
+			Data:    movement,
+		})
+	}
+}
+
+// Movements serves GET /api/product/:id/stock?page=1&page_size=50 with the
+// stock history of the product, latest first
+func Movements(products *merchantservice.ProductService, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id, err := custom.ParseID(c.Params("id"))
+		if err != nil {
+			return badRequest(c, "Invalid ID", err)
+		}
+		page, err := strconv.Atoi(c.Query("page", "1"))
+		if err != nil || page < 1 {
+			return badRequest(c, "Invalid query", errors.New("page must be a positive integer"))
+		}
+		size, err := strconv.Atoi(c.Query("page_size", "50"))
+		if err != nil || size < 1 || size > MaxStockPageSize {
+			return badRequest(c, "Invalid query", errors.New("page_size must be between 1 and "+strconv.Itoa(MaxStockPageSize)))
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		movements, total, err := products.Movements(ctx, uint(id), size, (page-1)*size)
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not list the stock movements")
+		}
+
+		data := StockPage{Total: total, Movements: movements}
+		if len(movements) == 0 {
+			data.Movements = []merchantmodel.StockMovement{}
+			return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+				RetCode: string(response.NotFound),
+				Message: "No resource found",
+				Data:    data,
+			})
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "success",
+			Data:    data,
+		})
+	}
+}
+
+func badRequest(c fiber.Ctx, message string, err error) error {
+	return response.Send(c, fiber.StatusBadRequest, response.ErrorModel{
+		RetCode: string(response.BadRequest),
+		Message: message,
+		Data:    err.Error(),
+	})
+}
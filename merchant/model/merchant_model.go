@@ -1,6 +1,11 @@
 package merchantmodel
 
 import (
+	"context"
+	"sample/audit"
+	"sample/custom"
+	"sample/geo"
+	"sample/utils"
 	"time"
 )
 
@@ -13,6 +18,8 @@ type Merchant struct {
 	AddressMerchant []AddressMerchant `gorm:"foreignKey:MerchantID;constraint:OnDelete:CASCADE;" json:"address_merchant"`
 
 	ContactMerchant []ContactMerchant `gorm:"foreignKey:MerchantID;constraint:OnDelete:CASCADE;" json:"contact_merchant"`
+
+	audit.Stamps
 }
 
 // Person model
@@ -22,6 +29,18 @@ type Product struct {
 	Name        string    `gorm:"size:20" json:"name"`
 	Quantity    int       `gorm:"size:100;not null" json:"quantity"`
 	DeliverDate time.Time `gorm:"not null" json:"date_of_delivery"`
+
+	audit.Stamps
+}
+
+// Creating keeps the stock from starting negative, the products created
+// nested in a merchant or a customer included, see repository.CreatingHook.
+// Then the quantity only changes through the stock ledger, see StockMovement.
+func (p *Product) Creating(ctx context.Context) error {
+	if p.Quantity < 0 {
+		return custom.NewValidationError("quantity", "cannot be negative")
+	}
+	return nil
 }
 
 // Address model
@@ -34,6 +53,35 @@ type AddressMerchant struct {
 	Municipality string `gorm:"size:50" json:"municipality"`
 	Barangays    string `gorm:"size:50" json:"barangays"`
 	PostalCode   string `gorm:"size:10" json:"postal_code"`
+
+	audit.Stamps
+}
+
+// Creating checks the place against the PSGC and stores its codes, see
+// repository.CreatingHook
+func (a *AddressMerchant) Creating(ctx context.Context) error {
+	return a.normalize()
+}
+
+// Updating checks the place being changed, see repository.UpdatingHook
+func (a *AddressMerchant) Updating(ctx context.Context) error {
+	return a.normalize()
+}
+
+func (a *AddressMerchant) normalize() error {
+	place := geo.Place{
+		Region:       a.Region,
+		Province:     a.Province,
+		Municipality: a.Municipality,
+		Barangay:     a.Barangays,
+		PostalCode:   a.PostalCode,
+	}
+	if err := geo.Default().Normalize(&place); err != nil {
+		return err
+	}
+	a.Region, a.Province, a.Municipality = place.Region, place.Province, place.Municipality
+	a.Barangays, a.PostalCode = place.Barangay, place.PostalCode
+	return nil
 }
 
 // Contact model
@@ -41,5 +89,40 @@ type ContactMerchant struct {
 	ID                  uint   `gorm:"primaryKey;autoIncrement" json:"merchant_contact_id"`
 	MerchantID          int    `gorm:"index;not null" json:"merchant_id"`
 	MerchantPhoneNumber string `gorm:"size:20" json:"merchant_phone_number"`
+	MerchantPhoneType   string `gorm:"size:10" json:"merchant_phone_type"`
 	MerchantEmail       string `gorm:"size:100;unique" json:"merchant_email"`
+
+	audit.Stamps
+}
+
+// Creating normalises the phone number and the email, see repository.CreatingHook
+func (c *ContactMerchant) Creating(ctx context.Context) error {
+	return c.normalize()
+}
+
+// Updating normalises the phone number and the email if they change, see repository.UpdatingHook
+func (c *ContactMerchant) Updating(ctx context.Context) error {
+	return c.normalize()
+}
+
+// DerivedFields writes the type with the number, see repository.DerivedFields
+func (c *ContactMerchant) DerivedFields() map[string][]string {
+	return map[string][]string{"MerchantPhoneNumber": {"MerchantPhoneType"}}
+}
+
+// normalize writes the phone number in E.164 with its type and the email in
+// lowercase
+func (c *ContactMerchant) normalize() error {
+	c.MerchantEmail = utils.NormalizeEmail(c.MerchantEmail)
+	c.MerchantPhoneType = ""
+	if c.MerchantPhoneNumber == "" {
+		return nil
+	}
+	normalized, ok := utils.NormalizePhone(c.MerchantPhoneNumber)
+	if !ok {
+		return custom.NewValidationError("merchant_phone_number", "is not a phone number")
+	}
+	c.MerchantPhoneNumber = normalized
+	c.MerchantPhoneType = string(utils.PhoneTypeOf(normalized))
+	return nil
 }
@@ -0,0 +1,37 @@
+package merchantmodel
+
+import "sample/audit"
+
+// Kinds of a StockMovement
+const (
+	StockReceipt    = "receipt"    // goods in from a supplier
+	StockSale       = "sale"       // goods out to a customer
+	StockAdjustment = "adjustment" // a count or a correction, in or out
+	StockReturn     = "return"     // goods back from a customer
+)
+
+// OpeningStockReason is the reason of the receipt recorded for the quantity a
+// product is created with
+const OpeningStockReason = "opening stock"
+
+// StockKinds lists the kinds of a StockMovement
+func StockKinds() []string {
+	return []string{StockReceipt, StockSale, StockAdjustment, StockReturn}
+}
+
+// StockMovement is one line of the stock ledger of a product. Product.Quantity
+// is the Balance of its last movement, both are written together with the
+// product row locked, see merchantrepository.ProductRepository.Move.
+type StockMovement struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	ProductID uint   `gorm:"index;not null" json:"product_id"`
+	Kind      string `gorm:"size:20;not null" json:"kind"`
+	Quantity  int    `gorm:"not null" json:"quantity"` // signed, negative for what goes out
+	Balance   int    `gorm:"not null" json:"balance"`  // the quantity of the product after the movement
+	Reason    string `gorm:"size:255" json:"reason"`
+
+	// The ledger goes with its product
+	Product *Product `gorm:"constraint:OnDelete:CASCADE" json:"-"`
+
+	audit.Stamps
+}
@@ -0,0 +1,112 @@
+package merchantrepository
+
+import (
+	"context"
+	merchantmodel "sample/merchant/model"
+	"sample/repository"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MerchantRepository stores merchants with their addresses, contacts and products
+type MerchantRepository interface {
+	repository.Repository[merchantmodel.Merchant]
+	// CountUndelivered returns how many products of the merchants are to be
+	// delivered after after
+	CountUndelivered(ctx context.Context, merchantIDs []uint, after time.Time) (int64, error)
+}
+
+// ProductRepository stores the products of merchants with their stock
+// ledger. Create records the quantity of a new product as its opening
+// receipt; Update writes the quantity as is, Move is what changes it.
+type ProductRepository interface {
+	repository.Repository[merchantmodel.Product]
+	// Move adds movement to the ledger of movement.ProductID and applies its
+	// Quantity to the product, setting its Balance, with the product row
+	// locked. It returns a custom.ConflictError when the stock would go
+	// negative, or custom.ErrNotFound.
+	Move(ctx context.Context, movement *merchantmodel.StockMovement) error
+	// Movements returns a page of the ledger of a product, latest first, and
+	// how many movements there are
+	Movements(ctx context.Context, productID uint, limit, offset int) ([]merchantmodel.StockMovement, int64, error)
+}
+
+type gormMerchantRepository struct {
+	*repository.Gorm[merchantmodel.Merchant]
+}
+
+// NewGormMerchants creates the GORM MerchantRepository
+func NewGormMerchants(db *gorm.DB) MerchantRepository {
+	return &gormMerchantRepository{repository.NewGorm[merchantmodel.Merchant](db)}
+}
+
+func (r *gormMerchantRepository) CountUndelivered(ctx context.Context, merchantIDs []uint, after time.Time) (int64, error) {
+	if len(merchantIDs) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := r.Conn(ctx).Model(&merchantmodel.Product{}).
+		Where("merchant_id IN ? AND deliver_date > ?", merchantIDs, after).
+		Count(&count).Error
+	return count, err
+}
+
+// NewMemoryMerchants creates an in-memory MerchantRepository for tests. The
+// products of the merchants it creates are created in products too, as rows
+// of their own with their opening stock, like GORM does.
+func NewMemoryMerchants(products ProductRepository) MerchantRepository {
+	return &memoryMerchantRepository{Memory: repository.NewMemory[merchantmodel.Merchant](), products: products}
+}
+
+// NewGormProducts creates the GORM ProductRepository
+func NewGormProducts(db *gorm.DB) ProductRepository {
+	return &gormProductRepository{repository.NewGorm[merchantmodel.Product](db)}
+}
+
+// NewMemoryProducts creates an in-memory ProductRepository for tests
+func NewMemoryProducts() ProductRepository {
+	return &memoryProductRepository{
+		Memory:    repository.NewMemory[merchantmodel.Product](),
+		movements: repository.NewMemory[merchantmodel.StockMovement](),
+	}
+}
+
+type memoryMerchantRepository struct {
+	*repository.Memory[merchantmodel.Merchant]
+	products ProductRepository
+}
+
+// Create stores the merchant, then creates its products in the product
+// repository, which numbers them and records their opening stock
+func (r *memoryMerchantRepository) Create(ctx context.Context, merchant *merchantmodel.Merchant) error {
+	if err := r.Memory.Create(ctx, merchant); err != nil {
+		return err
+	}
+	if len(merchant.Product) == 0 {
+		return nil
+	}
+	for i := range merchant.Product {
+		merchant.Product[i].MerchantID = int(merchant.ID)
+		if err := r.products.Create(ctx, &merchant.Product[i]); err != nil {
+			return err
+		}
+	}
+	_, err := r.Memory.Update(ctx, merchant.ID, &merchantmodel.Merchant{Product: merchant.Product}, []string{"Product"})
+	return err
+}
+
+func (r *memoryMerchantRepository) CountUndelivered(ctx context.Context, merchantIDs []uint, after time.Time) (int64, error) {
+	var count int64
+	for _, id := range merchantIDs {
+		undelivered, err := r.products.List(ctx, repository.ListOptions{Filters: []repository.Filter{
+			{Field: "MerchantID", Op: repository.OpEq, Value: int(id)},
+			{Field: "DeliverDate", Op: repository.OpGt, Value: after},
+		}})
+		if err != nil {
+			return 0, err
+		}
+		count += int64(len(undelivered))
+	}
+	return count, nil
+}
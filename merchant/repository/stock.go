@@ -0,0 +1,213 @@
+package merchantrepository
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"sample/custom"
+	merchantmodel "sample/merchant/model"
+	"sample/repository"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type gormProductRepository struct {
+	*repository.Gorm[merchantmodel.Product]
+}
+
+func (r *gormProductRepository) Move(ctx context.Context, movement *merchantmodel.StockMovement) error {
+	return r.Tx().Transaction(ctx, func(ctx context.Context) error {
+		db := r.Conn(ctx)
+
+		// Concurrent movements of the product wait for each other here
+		var product merchantmodel.Product
+		err := db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&product, movement.ProductID).Error
+		if err != nil {
+			return repository.Error(err)
+		}
+		balance, err := apply(product.Quantity, movement)
+		if err != nil {
+			return err
+		}
+
+		if err := db.Model(&product).Update("quantity", balance).Error; err != nil {
+			return repository.Error(err)
+		}
+		return repository.Error(db.Create(movement).Error)
+	})
+}
+
+func (r *gormProductRepository) Movements(ctx context.Context, productID uint, limit, offset int) ([]merchantmodel.StockMovement, int64, error) {
+	db := r.Conn(ctx).Model(&merchantmodel.StockMovement{}).Where("product_id = ?", productID)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	movements := []merchantmodel.StockMovement{}
+	err := db.Order("id DESC").Limit(limit).Offset(offset).Find(&movements).Error
+	return movements, total, err
+}
+
+type memoryProductRepository struct {
+	*repository.Memory[merchantmodel.Product]
+	movements *repository.Memory[merchantmodel.StockMovement]
+
+	mu sync.Mutex // the row lock of Move
+}
+
+// Create stores the product and its opening receipt, the products created
+// nested in a merchant come here too, see NewMemoryMerchants
+func (r *memoryProductRepository) Create(ctx context.Context, product *merchantmodel.Product) error {
+	if err := r.Memory.Create(ctx, product); err != nil {
+		return err
+	}
+	if product.Quantity == 0 {
+		return nil
+	}
+	opening := openingStock(product)
+	return r.movements.Create(ctx, &opening)
+}
+
+func (r *memoryProductRepository) Move(ctx context.Context, movement *merchantmodel.StockMovement) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	product, err := r.Get(ctx, movement.ProductID, nil)
+	if err != nil {
+		return err
+	}
+	balance, err := apply(product.Quantity, movement)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Memory.Update(ctx, product.ID, &merchantmodel.Product{Quantity: balance}, []string{"Quantity"}); err != nil {
+		return err
+	}
+	return r.movements.Create(ctx, movement)
+}
+
+func (r *memoryProductRepository) Movements(ctx context.Context, productID uint, limit, offset int) ([]merchantmodel.StockMovement, int64, error) {
+	movements := r.movements.Where(func(m *merchantmodel.StockMovement) bool {
+		return m.ProductID == productID
+	})
+	sort.Slice(movements, func(i, j int) bool { return movements[i].ID > movements[j].ID })
+
+	total := int64(len(movements))
+	if offset > len(movements) {
+		offset = len(movements)
+	}
+	movements = movements[offset:]
+	if limit < len(movements) {
+		movements = movements[:limit]
+	}
+	return movements, total, nil
+}
+
+// apply sets the Balance of movement from the quantity of the product before
+// it, which cannot go negative
+func apply(quantity int, movement *merchantmodel.StockMovement) (int, error) {
+	balance := quantity + movement.Quantity
+	if balance < 0 {
+		return 0, custom.NewConflictError(fmt.Sprintf("not enough stock: %d left, %d asked", quantity, -movement.Quantity))
+	}
+	movement.Balance = balance
+	return balance, nil
+}
+
+// openingStock is the receipt of the quantity product is created with
+func openingStock(product *merchantmodel.Product) merchantmodel.StockMovement {
+	return merchantmodel.StockMovement{
+		ProductID: product.ID,
+		Kind:      merchantmodel.StockReceipt,
+		Quantity:  product.Quantity,
+		Balance:   product.Quantity,
+		Reason:    merchantmodel.OpeningStockReason,
+	}
+}
+
+// newProductsKey holds the products a create statement inserts, between the
+// callbacks of InstrumentDB
+const newProductsKey = "stock:new_products"
+
+// InstrumentDB registers the callbacks that record the quantity of the
+// products GORM creates as their opening receipt, in the same transaction.
+// The products created nested in a merchant or a customer get theirs too, as
+// GORM saves them with their own create statement. Instrumenting db again
+// is a no-op.
+func InstrumentDB(db *gorm.DB) error {
+	if db.Callback().Create().Get("stock:opening") != nil {
+		return nil
+	}
+	if err := db.Callback().Create().Before("gorm:create").Register("stock:new_products", markNewProducts); err != nil {
+		return err
+	}
+	return db.Callback().Create().After("gorm:create").Register("stock:opening", recordOpeningStock)
+}
+
+// markNewProducts keeps the products without an ID, the others are existing
+// ones GORM upserts with their parent
+func markNewProducts(db *gorm.DB) {
+	var created []*merchantmodel.Product
+	for _, product := range statementProducts(db) {
+		if product.ID == 0 {
+			created = append(created, product)
+		}
+	}
+	if len(created) > 0 {
+		db.InstanceSet(newProductsKey, created)
+	}
+}
+
+func recordOpeningStock(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	created, ok := db.InstanceGet(newProductsKey)
+	if !ok {
+		return
+	}
+	var movements []merchantmodel.StockMovement
+	for _, product := range created.([]*merchantmodel.Product) {
+		if product.ID != 0 && product.Quantity != 0 {
+			movements = append(movements, openingStock(product))
+		}
+	}
+	if len(movements) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&movements).Error; err != nil {
+		db.AddError(err)
+	}
+}
+
+var productType = reflect.TypeFor[merchantmodel.Product]()
+
+// statementProducts returns the products a create statement writes, one or a
+// slice of them
+func statementProducts(db *gorm.DB) []*merchantmodel.Product {
+	if db.Error != nil || db.Statement.Schema == nil || db.Statement.Schema.ModelType != productType {
+		return nil
+	}
+	var products []*merchantmodel.Product
+	add := func(v reflect.Value) {
+		v = reflect.Indirect(v)
+		if v.IsValid() && v.CanAddr() && v.Type() == productType {
+			products = append(products, v.Addr().Interface().(*merchantmodel.Product))
+		}
+	}
+	value := reflect.Indirect(db.Statement.ReflectValue)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			add(value.Index(i))
+		}
+	case reflect.Struct:
+		add(value)
+	}
+	return products
+}
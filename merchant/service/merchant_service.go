@@ -0,0 +1,232 @@
+package merchantservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"sample/custom"
+	customerrepository "sample/customer/repository"
+	merchantmodel "sample/merchant/model"
+	merchantrepository "sample/merchant/repository"
+	"sample/repository"
+)
+
+// MerchantService holds the merchant business rules
+type MerchantService struct {
+	repo      merchantrepository.MerchantRepository
+	customers customerrepository.CustomerRepository
+	now       func() time.Time
+}
+
+// NewMerchantService creates the MerchantService, customers is used to check
+// the owner exists and now is the clock deliveries are compared to
+func NewMerchantService(repo merchantrepository.MerchantRepository, customers customerrepository.CustomerRepository, now func() time.Time) *MerchantService {
+	return &MerchantService{repo: repo, customers: customers, now: now}
+}
+
+func (s *MerchantService) List(ctx context.Context, opts repository.ListOptions) ([]merchantmodel.Merchant, error) {
+	return s.repo.List(ctx, opts)
+}
+
+func (s *MerchantService) Each(ctx context.Context, opts repository.ListOptions, size int, fn func(batch []merchantmodel.Merchant) error) error {
+	return s.repo.Each(ctx, opts, size, fn)
+}
+
+func (s *MerchantService) Get(ctx context.Context, id uint, preloads []string) (*merchantmodel.Merchant, error) {
+	return s.repo.Get(ctx, id, preloads)
+}
+
+// Create stores the merchant with its nested addresses, contacts and products
+func (s *MerchantService) Create(ctx context.Context, merchant *merchantmodel.Merchant) error {
+	merchant.Name = strings.TrimSpace(merchant.Name)
+	if merchant.Name == "" {
+		return custom.NewValidationError("name", "is required")
+	}
+	if err := s.checkCustomer(ctx, merchant.CustomerID); err != nil {
+		return err
+	}
+	for i := range merchant.Product {
+		if err := validateProduct(&merchant.Product[i]); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.Create(ctx, merchant)
+}
+
+// Update applies changes to the merchant with id, see repository.Repository
+func (s *MerchantService) Update(ctx context.Context, id uint, changes *merchantmodel.Merchant, fields []string) (*merchantmodel.Merchant, error) {
+	changes.Name = strings.TrimSpace(changes.Name)
+	if repository.Selected(fields, "Name") && changes.Name == "" {
+		return nil, custom.NewValidationError("name", "is required")
+	}
+	if changes.CustomerID != 0 || repository.Selected(fields, "CustomerID") {
+		if err := s.checkCustomer(ctx, changes.CustomerID); err != nil {
+			return nil, err
+		}
+	}
+	if err := NoNestedProducts("product", changes); err != nil {
+		return nil, err
+	}
+	return s.repo.Update(ctx, id, changes, fields)
+}
+
+// Delete deletes the merchant with its products, unless some are still to
+// be delivered
+func (s *MerchantService) Delete(ctx context.Context, id uint) error {
+	if err := KeepUndelivered(ctx, s.repo, []uint{id}, s.now()); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// KeepUndelivered returns a custom.ConflictError when products of the
+// merchants are still to be delivered at now, the products go with their
+// merchants. Every path deleting merchants checks it: the merchant and the
+// customer deletes, and ProductService.Delete checks a single product.
+func KeepUndelivered(ctx context.Context, merchants merchantrepository.MerchantRepository, merchantIDs []uint, now time.Time) error {
+	undelivered, err := merchants.CountUndelivered(ctx, merchantIDs, now)
+	if err != nil {
+		return err
+	}
+	if undelivered > 0 {
+		return custom.NewConflictError(fmt.Sprintf("%d undelivered products would be deleted", undelivered))
+	}
+	return nil
+}
+
+func (s *MerchantService) checkCustomer(ctx context.Context, customerID int) error {
+	if customerID <= 0 {
+		return custom.NewValidationError("customer_id", "is required")
+	}
+	if _, err := s.customers.Get(ctx, uint(customerID), nil); err != nil {
+		if errors.Is(err, custom.ErrNotFound) {
+			return custom.NewValidationError("customer_id", "does not exist")
+		}
+		return err
+	}
+	return nil
+}
+
+// NoNestedProducts rejects the products of merchant in an update, field is
+// where they are in the body. GORM would upsert them without their opening
+// stock or checks, products are written through /api/product and their
+// quantity through its stock ledger.
+func NoNestedProducts(field string, merchant *merchantmodel.Merchant) error {
+	if len(merchant.Product) > 0 {
+		return custom.NewValidationError(field, "changes through /api/product")
+	}
+	return nil
+}
+
+// ProductService holds the product business rules
+type ProductService struct {
+	repo      merchantrepository.ProductRepository
+	merchants merchantrepository.MerchantRepository
+	now       func() time.Time
+}
+
+// NewProductService creates the ProductService, merchants is used to check
+// the owner exists and now is the clock deliveries are compared to
+func NewProductService(repo merchantrepository.ProductRepository, merchants merchantrepository.MerchantRepository, now func() time.Time) *ProductService {
+	return &ProductService{repo: repo, merchants: merchants, now: now}
+}
+
+func (s *ProductService) List(ctx context.Context, opts repository.ListOptions) ([]merchantmodel.Product, error) {
+	return s.repo.List(ctx, opts)
+}
+
+func (s *ProductService) Each(ctx context.Context, opts repository.ListOptions, size int, fn func(batch []merchantmodel.Product) error) error {
+	return s.repo.Each(ctx, opts, size, fn)
+}
+
+func (s *ProductService) Get(ctx context.Context, id uint, preloads []string) (*merchantmodel.Product, error) {
+	return s.repo.Get(ctx, id, preloads)
+}
+
+func (s *ProductService) Create(ctx context.Context, product *merchantmodel.Product) error {
+	if err := validateProduct(product); err != nil {
+		return err
+	}
+	if err := s.checkMerchant(ctx, product.MerchantID); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, product)
+}
+
+// Update applies changes to the product with id, see repository.Repository
+func (s *ProductService) Update(ctx context.Context, id uint, changes *merchantmodel.Product, fields []string) (*merchantmodel.Product, error) {
+	changes.Name = strings.TrimSpace(changes.Name)
+	if repository.Selected(fields, "Name") && changes.Name == "" {
+		return nil, custom.NewValidationError("name", "is required")
+	}
+	// The quantity follows the stock ledger, see Move. A PUT may send it back
+	// as it is, it is not written so as not to undo a concurrent movement.
+	if changes.Quantity != 0 || repository.Selected(fields, "Quantity") {
+		existing, err := s.repo.Get(ctx, id, nil)
+		if err != nil {
+			return nil, err
+		}
+		if changes.Quantity != existing.Quantity {
+			return nil, custom.NewValidationError("quantity", "changes through POST /api/product/:id/stock")
+		}
+		changes.Quantity = 0
+		if fields != nil {
+			fields = slices.DeleteFunc(slices.Clone(fields), func(field string) bool { return field == "Quantity" })
+		}
+	}
+	if repository.Selected(fields, "DeliverDate") && changes.DeliverDate.IsZero() {
+		return nil, custom.NewValidationError("date_of_delivery", "is required")
+	}
+	if changes.MerchantID != 0 || repository.Selected(fields, "MerchantID") {
+		if err := s.checkMerchant(ctx, changes.MerchantID); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.Update(ctx, id, changes, fields)
+}
+
+// Delete deletes the product, unless it is still to be delivered, as
+// KeepUndelivered does for the merchants
+func (s *ProductService) Delete(ctx context.Context, id uint) error {
+	product, err := s.repo.Get(ctx, id, nil)
+	if err != nil {
+		return err
+	}
+	if product.DeliverDate.After(s.now()) {
+		return custom.NewConflictError("the product is still to be delivered")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *ProductService) checkMerchant(ctx context.Context, merchantID int) error {
+	if merchantID <= 0 {
+		return custom.NewValidationError("merchant_id", "is required")
+	}
+	if _, err := s.merchants.Get(ctx, uint(merchantID), nil); err != nil {
+		if errors.Is(err, custom.ErrNotFound) {
+			return custom.NewValidationError("merchant_id", "does not exist")
+		}
+		return err
+	}
+	return nil
+}
+
+// validateProduct checks the fields of a new product, the merchant is checked by the caller
+func validateProduct(product *merchantmodel.Product) error {
+	product.Name = strings.TrimSpace(product.Name)
+	if product.Name == "" {
+		return custom.NewValidationError("name", "is required")
+	}
+	if product.Quantity < 0 {
+		return custom.NewValidationError("quantity", "cannot be negative")
+	}
+	if product.DeliverDate.IsZero() {
+		return custom.NewValidationError("date_of_delivery", "is required")
+	}
+	return nil
+}
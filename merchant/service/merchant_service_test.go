@@ -0,0 +1,89 @@
+package merchantservice_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sample/custom"
+	merchantmodel "sample/merchant/model"
+	"sample/servicetest"
+)
+
+func TestMerchantCreate(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	customerID := int(f.CreateCustomer(t, f.Customer("Juan")).ID)
+
+	tests := []struct {
+		name     string
+		merchant merchantmodel.Merchant
+		field    string
+	}{
+		{"blank name", merchantmodel.Merchant{CustomerID: customerID, Name: "  "}, "name"},
+		{"no customer", merchantmodel.Merchant{Name: "Sari"}, "customer_id"},
+		{"unknown customer", merchantmodel.Merchant{CustomerID: 99, Name: "Sari"}, "customer_id"},
+		{"negative stock", merchantmodel.Merchant{CustomerID: customerID, Name: "Sari", Product: []merchantmodel.Product{
+			{Name: "Rice", Quantity: -1, DeliverDate: f.Now},
+		}}, "quantity"},
+	}
+	for _, tt := range tests {
+		if err := f.Merchants.Create(ctx, &tt.merchant); servicetest.ValidationField(err) != tt.field {
+			t.Errorf("%s: err = %v, want a validation error of %s", tt.name, err, tt.field)
+		}
+	}
+
+	merchant := f.CreateMerchant(t, uint(customerID), merchantmodel.Product{Name: " Rice ", Quantity: 5, DeliverDate: f.Now})
+	if merchant.Name != "Sari" || merchant.Product[0].Name != "Rice" {
+		t.Errorf("merchant = %+v, want the names trimmed", merchant)
+	}
+}
+
+func TestProductCreate(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	merchant := f.CreateMerchant(t, f.CreateCustomer(t, f.Customer("Juan")).ID)
+
+	tests := []struct {
+		name    string
+		product merchantmodel.Product
+		field   string
+	}{
+		{"no merchant", merchantmodel.Product{Name: "Rice", DeliverDate: f.Now}, "merchant_id"},
+		{"unknown merchant", merchantmodel.Product{MerchantID: 99, Name: "Rice", DeliverDate: f.Now}, "merchant_id"},
+		{"no delivery date", merchantmodel.Product{MerchantID: int(merchant.ID), Name: "Rice"}, "date_of_delivery"},
+	}
+	for _, tt := range tests {
+		if err := f.Products.Create(ctx, &tt.product); servicetest.ValidationField(err) != tt.field {
+			t.Errorf("%s: err = %v, want a validation error of %s", tt.name, err, tt.field)
+		}
+	}
+
+	if _, err := f.Products.Update(ctx, 99, &merchantmodel.Product{Name: "Rice"}, nil); !errors.Is(err, custom.ErrNotFound) {
+		t.Errorf("update of an unknown product: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMerchantDeleteKeepsUndeliveredProducts(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	customer := f.CreateCustomer(t, f.Customer("Juan"))
+	merchant := f.CreateMerchant(t, customer.ID, merchantmodel.Product{Name: "Rice", Quantity: 5, DeliverDate: f.Now.Add(time.Hour)})
+
+	var conflict *custom.ConflictError
+	if err := f.Merchants.Delete(ctx, merchant.ID); !errors.As(err, &conflict) {
+		t.Fatalf("delete with a product to deliver: err = %v, want a conflict", err)
+	}
+	if _, err := f.Merchants.Get(ctx, merchant.ID, nil); err != nil {
+		t.Fatalf("merchant deleted despite the conflict: %v", err)
+	}
+
+	f.Now = f.Now.Add(2 * time.Hour)
+	if err := f.Merchants.Delete(ctx, merchant.ID); err != nil {
+		t.Fatalf("delete once delivered: %v", err)
+	}
+	if _, err := f.Merchants.Get(ctx, merchant.ID, nil); !errors.Is(err, custom.ErrNotFound) {
+		t.Errorf("get after delete: err = %v, want ErrNotFound", err)
+	}
+}
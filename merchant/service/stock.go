@@ -0,0 +1,58 @@
+package merchantservice
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"sample/custom"
+	merchantmodel "sample/merchant/model"
+)
+
+// MaxStockReason is the length of the reason of a stock movement
+const MaxStockReason = 255
+
+// Move records a movement of the stock of the product with id and returns
+// it. quantity is the number of units received, sold or returned, or the
+// signed correction of an adjustment, which needs a reason. The stock cannot
+// go negative, see merchantrepository.ProductRepository.Move.
+func (s *ProductService) Move(ctx context.Context, id uint, kind string, quantity int, reason string) (*merchantmodel.StockMovement, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > MaxStockReason {
+		return nil, custom.NewValidationError("reason", "is too long")
+	}
+
+	movement := merchantmodel.StockMovement{ProductID: id, Kind: kind, Quantity: quantity, Reason: reason}
+	switch kind {
+	case merchantmodel.StockReceipt, merchantmodel.StockReturn, merchantmodel.StockSale:
+		if quantity <= 0 {
+			return nil, custom.NewValidationError("quantity", "must be positive")
+		}
+		if kind == merchantmodel.StockSale {
+			movement.Quantity = -quantity
+		}
+	case merchantmodel.StockAdjustment:
+		if quantity == 0 {
+			return nil, custom.NewValidationError("quantity", "cannot be zero")
+		}
+		if reason == "" {
+			return nil, custom.NewValidationError("reason", "is required for an adjustment")
+		}
+	default:
+		return nil, custom.NewValidationError("kind", "must be one of "+strings.Join(merchantmodel.StockKinds(), ", "))
+	}
+
+	if err := s.repo.Move(ctx, &movement); err != nil {
+		return nil, err
+	}
+	return &movement, nil
+}
+
+// Movements returns a page of the stock ledger of the product with id,
+// latest first, and how many movements there are, or custom.ErrNotFound
+func (s *ProductService) Movements(ctx context.Context, id uint, limit, offset int) ([]merchantmodel.StockMovement, int64, error) {
+	if _, err := s.repo.Get(ctx, id, nil); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.Movements(ctx, id, limit, offset)
+}
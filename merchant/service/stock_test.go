@@ -0,0 +1,217 @@
+package merchantservice_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sample/custom"
+	merchantmodel "sample/merchant/model"
+	merchantservice "sample/merchant/service"
+	"sample/servicetest"
+)
+
+// stocked returns a fixture with a customer and a merchant of product,
+// delivered now
+func stocked(t *testing.T, product merchantmodel.Product) (*servicetest.Fixture, *merchantmodel.Product) {
+	t.Helper()
+	f := servicetest.New()
+	product.DeliverDate = f.Now
+	merchant := f.CreateMerchant(t, f.CreateCustomer(t, f.Customer("Juan")).ID, product)
+	return f, &merchant.Product[0]
+}
+
+func TestMove(t *testing.T) {
+	f, product := stocked(t, merchantmodel.Product{Name: "Rice", Quantity: 10})
+	ctx := context.Background()
+
+	moves := []struct {
+		kind     string
+		quantity int
+		reason   string
+		signed   int
+		balance  int
+	}{
+		{merchantmodel.StockSale, 3, "", -3, 7},
+		{merchantmodel.StockAdjustment, -2, " broken bags ", -2, 5},
+		{merchantmodel.StockReturn, 1, "", 1, 6},
+		{merchantmodel.StockReceipt, 4, "", 4, 10},
+	}
+	for _, m := range moves {
+		movement, err := f.Products.Move(ctx, product.ID, m.kind, m.quantity, m.reason)
+		if err != nil {
+			t.Fatalf("%s of %d: %v", m.kind, m.quantity, err)
+		}
+		if movement.Quantity != m.signed || movement.Balance != m.balance || movement.Reason != strings.TrimSpace(m.reason) {
+			t.Errorf("%s of %d = %+v, want quantity %d and balance %d", m.kind, m.quantity, movement, m.signed, m.balance)
+		}
+	}
+
+	stored, err := f.Products.Get(ctx, product.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Quantity != 10 {
+		t.Errorf("quantity = %d, want 10", stored.Quantity)
+	}
+
+	movements, total, err := f.Products.Movements(ctx, product.ID, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 5 || len(movements) != 2 || movements[0].Kind != merchantmodel.StockReceipt || movements[1].Kind != merchantmodel.StockReturn {
+		t.Errorf("first page = %+v of %d, want the receipt then the return of 5", movements, total)
+	}
+	if movements, _, _ := f.Products.Movements(ctx, product.ID, 2, 4); len(movements) != 1 || movements[0].Reason != merchantmodel.OpeningStockReason {
+		t.Errorf("last page = %+v, want the opening stock", movements)
+	}
+}
+
+func TestMoveValidates(t *testing.T) {
+	f, product := stocked(t, merchantmodel.Product{Name: "Rice", Quantity: 2})
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		kind     string
+		quantity int
+		reason   string
+		field    string
+	}{
+		{"sale of nothing", merchantmodel.StockSale, 0, "", "quantity"},
+		{"negative receipt", merchantmodel.StockReceipt, -1, "", "quantity"},
+		{"zero adjustment", merchantmodel.StockAdjustment, 0, "count", "quantity"},
+		{"adjustment without reason", merchantmodel.StockAdjustment, 1, "  ", "reason"},
+		{"unknown kind", "theft", 1, "", "kind"},
+		{"long reason", merchantmodel.StockReceipt, 1, strings.Repeat("é", merchantservice.MaxStockReason+1), "reason"},
+	}
+	for _, tt := range tests {
+		if _, err := f.Products.Move(ctx, product.ID, tt.kind, tt.quantity, tt.reason); servicetest.ValidationField(err) != tt.field {
+			t.Errorf("%s: err = %v, want a validation error of %s", tt.name, err, tt.field)
+		}
+	}
+
+	var conflict *custom.ConflictError
+	if _, err := f.Products.Move(ctx, product.ID, merchantmodel.StockSale, 3, ""); !errors.As(err, &conflict) {
+		t.Errorf("sale past the stock: err = %v, want a conflict", err)
+	}
+	if _, err := f.Products.Move(ctx, product.ID, merchantmodel.StockAdjustment, -3, "count"); !errors.As(err, &conflict) {
+		t.Errorf("adjustment below zero: err = %v, want a conflict", err)
+	}
+	if _, err := f.Products.Move(ctx, 99, merchantmodel.StockReceipt, 1, ""); !errors.Is(err, custom.ErrNotFound) {
+		t.Errorf("move of an unknown product: err = %v, want ErrNotFound", err)
+	}
+
+	if _, total, _ := f.Products.Movements(ctx, product.ID, 10, 0); total != 1 {
+		t.Errorf("ledger has %d movements, want only the opening stock", total)
+	}
+}
+
+func TestMoveConcurrentSalesNeverOversell(t *testing.T) {
+	f, product := stocked(t, merchantmodel.Product{Name: "Rice", Quantity: 10})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	sold := 0
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := f.Products.Move(ctx, product.ID, merchantmodel.StockSale, 1, ""); err == nil {
+				mu.Lock()
+				sold++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	stored, _ := f.Products.Get(ctx, product.ID, nil)
+	if sold != 10 || stored.Quantity != 0 {
+		t.Errorf("sold %d leaving %d, want 10 sold and none left", sold, stored.Quantity)
+	}
+}
+
+func TestMerchantCreateRecordsOpeningStockOfNestedProducts(t *testing.T) {
+	f := servicetest.New()
+	merchant := f.CreateMerchant(t, f.CreateCustomer(t, f.Customer("Juan")).ID,
+		merchantmodel.Product{Name: "Rice", Quantity: 5, DeliverDate: f.Now},
+		merchantmodel.Product{Name: "Eggs", DeliverDate: f.Now},
+	)
+
+	rice, eggs := merchant.Product[0], merchant.Product[1]
+	if rice.ID == 0 || rice.MerchantID != int(merchant.ID) {
+		t.Fatalf("nested product = %+v, want it numbered under merchant %d", rice, merchant.ID)
+	}
+	movements, total, err := f.Products.Movements(context.Background(), rice.ID, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || movements[0].Kind != merchantmodel.StockReceipt || movements[0].Balance != 5 || movements[0].Reason != merchantmodel.OpeningStockReason {
+		t.Errorf("ledger of a nested product = %+v, want its opening receipt of 5", movements)
+	}
+	if _, total, _ := f.Products.Movements(context.Background(), eggs.ID, 10, 0); total != 0 {
+		t.Errorf("ledger of a product without stock has %d movements, want none", total)
+	}
+}
+
+func TestMerchantUpdateRejectsNestedProducts(t *testing.T) {
+	f := servicetest.New()
+	merchant := f.CreateMerchant(t, f.CreateCustomer(t, f.Customer("Juan")).ID)
+
+	changes := &merchantmodel.Merchant{Product: []merchantmodel.Product{{Name: "Rice", Quantity: -5, DeliverDate: f.Now}}}
+	if _, err := f.Merchants.Update(context.Background(), merchant.ID, changes, nil); servicetest.ValidationField(err) != "product" {
+		t.Errorf("err = %v, want a validation error of product", err)
+	}
+
+	updated, err := f.Merchants.Update(context.Background(), merchant.ID, &merchantmodel.Merchant{Name: " Bakery "}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Bakery" {
+		t.Errorf("name = %q, want Bakery", updated.Name)
+	}
+}
+
+func TestProductUpdateLeavesQuantityToTheLedger(t *testing.T) {
+	f, product := stocked(t, merchantmodel.Product{Name: "Rice", Quantity: 5})
+	ctx := context.Background()
+
+	if _, err := f.Products.Update(ctx, product.ID, &merchantmodel.Product{Quantity: 50}, nil); servicetest.ValidationField(err) != "quantity" {
+		t.Errorf("update of the quantity: err = %v, want a validation error of quantity", err)
+	}
+
+	// A PUT sending the quantity back as it is updates the rest
+	updated, err := f.Products.Update(ctx, product.ID, &merchantmodel.Product{Name: "Brown rice", Quantity: 5}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Brown rice" || updated.Quantity != 5 {
+		t.Errorf("product = %+v, want renamed with 5 in stock", updated)
+	}
+}
+
+func TestProductDeleteKeepsUndelivered(t *testing.T) {
+	f := servicetest.New()
+	ctx := context.Background()
+	merchant := f.CreateMerchant(t, f.CreateCustomer(t, f.Customer("Juan")).ID,
+		merchantmodel.Product{Name: "Rice", Quantity: 5, DeliverDate: f.Now.Add(time.Hour)})
+	product := merchant.Product[0]
+
+	var conflict *custom.ConflictError
+	if err := f.Products.Delete(ctx, product.ID); !errors.As(err, &conflict) {
+		t.Fatalf("delete of a product to deliver: err = %v, want a conflict", err)
+	}
+
+	f.Now = f.Now.Add(2 * time.Hour)
+	if err := f.Products.Delete(ctx, product.ID); err != nil {
+		t.Fatalf("delete once delivered: %v", err)
+	}
+	if err := f.Products.Delete(ctx, product.ID); !errors.Is(err, custom.ErrNotFound) {
+		t.Errorf("delete again: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const namespace = "sample"
+
+// Metrics owns the Prometheus registry and the collectors of the app
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+	entities     *prometheus.GaugeVec
+}
+
+// New creates the collectors and registers them with a fresh registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by method, route and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "GORM statement latency by table and operation.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"table", "operation"}),
+		entities: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "entities",
+			Help:      "Number of stored rows per business entity.",
+		}, []string{"entity"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.dbDuration,
+		m.entities,
+	)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format, mount it on /metrics
+func (m *Metrics) Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+}
+
+// Middleware counts and times every request by its route pattern
+func (m *Metrics) Middleware() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		start := time.Now()
+		self := c.Route()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if fiberErr, ok := err.(*fiber.Error); ok {
+				status = fiberErr.Code
+			}
+		}
+
+		// When no route matched, the current route is still this middleware
+		route := c.Route().Path
+		if c.Route() == self {
+			route = "unmatched"
+		}
+
+		labels := prometheus.Labels{"method": c.Method(), "route": route, "status": strconv.Itoa(status)}
+		m.httpRequests.With(labels).Inc()
+		m.httpDuration.With(labels).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
+
+// InstrumentDB records the duration of every GORM statement and exports the
+// connection pool stats of the *sql.DB behind db
+func (m *Metrics) InstrumentDB(db *gorm.DB, dbName string) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := m.registry.Register(collectors.NewDBStatsCollector(sqlDB, dbName)); err != nil {
+		return err
+	}
+
+	const startKey = "metrics:start"
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(startKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			value, ok := tx.InstanceGet(startKey)
+			if !ok {
+				return
+			}
+			table := tx.Statement.Table
+			if table == "" {
+				table = "unknown"
+			}
+			m.dbDuration.WithLabelValues(table, operation).Observe(time.Since(value.(time.Time)).Seconds())
+		}
+	}
+
+	callbacks := db.Callback()
+
+	type registration struct {
+		operation string
+		before    func(string, func(*gorm.DB)) error
+		after     func(string, func(*gorm.DB)) error
+	}
+	registrations := []registration{
+		{"create", callbacks.Create().Before("gorm:create").Register, callbacks.Create().After("gorm:create").Register},
+		{"query", callbacks.Query().Before("gorm:query").Register, callbacks.Query().After("gorm:query").Register},
+		{"update", callbacks.Update().Before("gorm:update").Register, callbacks.Update().After("gorm:update").Register},
+		{"delete", callbacks.Delete().Before("gorm:delete").Register, callbacks.Delete().After("gorm:delete").Register},
+		{"row", callbacks.Row().Before("gorm:row").Register, callbacks.Row().After("gorm:row").Register},
+		{"raw", callbacks.Raw().Before("gorm:raw").Register, callbacks.Raw().After("gorm:raw").Register},
+	}
+	if callbacks.Create().Get("metrics:before_create") != nil {
+		// Another Metrics instrumented db, e.g. a second server on the same
+		// DB: m takes its callbacks over rather than timing every statement twice
+		registrations = []registration{
+			{"create", callbacks.Create().Replace, callbacks.Create().Replace},
+			{"query", callbacks.Query().Replace, callbacks.Query().Replace},
+			{"update", callbacks.Update().Replace, callbacks.Update().Replace},
+			{"delete", callbacks.Delete().Replace, callbacks.Delete().Replace},
+			{"row", callbacks.Row().Replace, callbacks.Row().Replace},
+			{"raw", callbacks.Raw().Replace, callbacks.Raw().Replace},
+		}
+	}
+	for _, r := range registrations {
+		if err := r.before("metrics:before_"+r.operation, before); err != nil {
+			return err
+		}
+		if err := r.after("metrics:after_"+r.operation, after(r.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchCounts refreshes the entities gauge every interval until ctx is done,
+// or only once when interval is not positive. models maps the entity label
+// to a pointer of the model to count.
+func (m *Metrics) WatchCounts(ctx context.Context, db *gorm.DB, log *slog.Logger, interval time.Duration, models map[string]any) {
+	refresh := func() {
+		for entity, model := range models {
+			var count int64
+			if err := db.WithContext(ctx).Model(model).Count(&count).Error; err != nil {
+				log.WarnContext(ctx, "could not refresh entity count", "entity", entity, "error", err)
+				continue
+			}
+			m.entities.WithLabelValues(entity).Set(float64(count))
+		}
+	}
+
+	if interval <= 0 {
+		go refresh()
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		refresh()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refresh()
+			}
+		}
+	}()
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"log/slog"
+	"sample/database"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// AccessLog writes one structured log line per request, including how many
+// queries it ran. Requests running more than queryWarn queries are logged as
+// warnings so N+1 preloads stand out.
+func AccessLog(log *slog.Logger, queryWarn int) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		start := time.Now()
+		c.SetUserContext(database.WithQueryCounter(c.UserContext()))
+
+		err := c.Next()
+		if err != nil {
+			// Let the app error handler set the status before we log it
+			if handlerErr := c.App().ErrorHandler(c, err); handlerErr != nil {
+				_ = c.SendStatus(fiber.StatusInternalServerError)
+			}
+		}
+
+		status := c.Response().StatusCode()
+		queries := database.QueryCount(c.UserContext())
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		case queryWarn > 0 && queries > int64(queryWarn):
+			level = slog.LevelWarn
+		}
+
+		// Body would read a streamed body, e.g. an export, into memory: its
+		// size is not known yet, it is logged as -1
+		size := -1
+		if !c.Response().IsBodyStream() {
+			size = len(c.Response().Body())
+		}
+		log.LogAttrs(c.UserContext(), level, "access",
+			slog.String("method", c.Method()),
+			slog.String("route", c.Route().Path),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", size),
+			slog.Int64("queries", queries),
+			slog.String("ip", c.IP()),
+		)
+
+		return nil
+	}
+}
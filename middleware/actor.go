@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"slices"
+
+	"sample/audit"
+	"sample/response"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Actor puts the user changes are attributed to in the user context: the
+// "user_id" local set by an authentication middleware, else the header an
+// authenticating proxy sets, if configured. Only trust header when the
+// proxy strips it from client requests.
+func Actor(header string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		actor, _ := c.Locals("user_id").(string)
+		if actor == "" && header != "" {
+			actor = c.Get(header)
+		}
+		if actor != "" && len(actor) <= 100 {
+			c.SetUserContext(audit.WithActor(c.UserContext(), actor))
+		}
+		return c.Next()
+	}
+}
+
+// RequireActor lets through the requests of the given actors only, as put
+// in the user context by Actor, which must run first. Other actors get a
+// 403 and anonymous requests a 401. With no actors nobody is let through.
+func RequireActor(actors ...string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		actor := audit.Actor(c.UserContext())
+		if actor == audit.Anonymous {
+			return response.Send(c, fiber.StatusUnauthorized, response.ErrorModel{
+				RetCode: string(response.Unauthorized),
+				Message: "Unauthorized",
+				Data:    "authenticate to use " + c.Path(),
+			})
+		}
+		if !slices.Contains(actors, actor) {
+			return response.Send(c, fiber.StatusForbidden, response.ErrorModel{
+				RetCode: string(response.Forbidden),
+				Message: "Forbidden",
+				Data:    actor + " may not use " + c.Path(),
+			})
+		}
+		return c.Next()
+	}
+}
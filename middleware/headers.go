@@ -9,12 +9,9 @@ func HeadersMiddleware() fiber.Handler {
 
 		// Handle CORS
 		c.Set("Access-Control-Allow-Origin", "*") // Change to your allowed origins
-		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
-		// Set Content-Type header for JSON responses
-		c.Set("Content-Type", "application/json")
-
 		// Handle preflight requests
 		if c.Method() == "OPTIONS" {
 			return c.SendStatus(fiber.StatusNoContent)
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"sample/audit"
+	"sample/response"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Rate describes a token bucket: Burst tokens at most, refilled at Burst per Period.
+type Rate struct {
+	Burst  int
+	Period time.Duration
+}
+
+// PerMinute returns a Rate allowing n requests per minute.
+func PerMinute(n int) Rate {
+	return Rate{Burst: n, Period: time.Minute}
+}
+
+// PerSecond returns a Rate allowing n requests per second.
+func PerSecond(n int) Rate {
+	return Rate{Burst: n, Period: time.Second}
+}
+
+// RateLimitResult is the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until the next token, only set when not allowed
+}
+
+// RateLimitStore keeps the token buckets. MemoryStore is used by default,
+// a shared store (e.g. Redis) can be plugged in for multiple instances.
+type RateLimitStore interface {
+	Take(key string, rate Rate) (RateLimitResult, error)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	period time.Duration // of its Rate, it is full again once idle that long
+}
+
+// sweepInterval is how often MemoryStore drops the buckets full again
+const sweepInterval = time.Minute
+
+// MemoryStore is an in-process RateLimitStore
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// Now is the clock used to refill buckets, replaceable in tests
+	Now func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		Now:     time.Now,
+	}
+}
+
+// Take refills the bucket for key and tries to consume one token
+func (s *MemoryStore) Take(key string, rate Rate) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Now()
+	perToken := rate.Period / time.Duration(rate.Burst)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), last: now}
+		s.buckets[key] = b
+	} else {
+		elapsed := now.Sub(b.last)
+		b.tokens = math.Min(float64(rate.Burst), b.tokens+float64(elapsed)/float64(perToken))
+		b.last = now
+	}
+	b.period = rate.Period
+
+	result := RateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = time.Duration((float64(rate.Burst) - b.tokens) * float64(perToken))
+
+	s.sweep(now)
+	return result, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again, by
+// the period of their own rate
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.period {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// ClientKey identifies the caller by the API key an authentication
+// middleware verified, in the "api_key" local, then by the actor Actor put
+// in the user context, then by c.IP(). Headers are not trusted as such: a
+// client sending a new key with each request would get a new bucket each
+// time. So the Actor middleware must run before the limits, and behind a
+// proxy the app must read the client IP from a header the trusted proxies
+// set, see config.Config.ProxyHeader, or every client shares one bucket.
+func ClientKey(c fiber.Ctx) string {
+	if apiKey, ok := c.Locals("api_key").(string); ok && apiKey != "" {
+		return "key:" + apiKey
+	}
+	if actor := audit.Actor(c.UserContext()); actor != audit.Anonymous {
+		return "user:" + actor
+	}
+	return "ip:" + c.IP()
+}
+
+// RateLimiter hands out per-route rate limit middleware sharing one store
+type RateLimiter struct {
+	Store RateLimitStore
+	Key   func(c fiber.Ctx) string
+}
+
+// NewRateLimiter creates a RateLimiter keyed by ClientKey
+func NewRateLimiter(store RateLimitStore) *RateLimiter {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &RateLimiter{Store: store, Key: ClientKey}
+}
+
+// Limit returns a middleware enforcing rate on the route it is attached to
+func (l *RateLimiter) Limit(rate Rate) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if rate.Burst <= 0 || rate.Period <= 0 {
+			return c.Next()
+		}
+
+		route := c.Route()
+		key := route.Method + " " + route.Path + "|" + l.Key(c)
+
+		result, err := l.Store.Take(key, rate)
+		if err != nil {
+			// Fail open, a broken store should not take the API down
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(rate.Burst))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+		if !result.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return response.Send(c, fiber.StatusTooManyRequests, response.ErrorModel{
+				RetCode: string(response.TooManyRequests),
+				Message: "Too many requests",
+				Data:    fiber.ErrTooManyRequests.Message,
+			})
+		}
+
+		return c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// fakeClock is the clock of a MemoryStore under test
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.Now = clock.Now
+	return store, clock
+}
+
+func TestMemoryStoreTake(t *testing.T) {
+	store, clock := newTestStore()
+	rate := Rate{Burst: 3, Period: 3 * time.Second} // a token a second
+
+	for i := 2; i >= 0; i-- {
+		result, _ := store.Take("k", rate)
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("take %d = %+v, want allowed with %d remaining", 3-i, result, i)
+		}
+	}
+	result, _ := store.Take("k", rate)
+	if result.Allowed {
+		t.Fatal("take past the burst allowed")
+	}
+	if result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("RetryAfter, Reset = %v, %v, want 1s, 3s", result.RetryAfter, result.Reset)
+	}
+
+	clock.Advance(time.Second)
+	if result, _ := store.Take("k", rate); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("take after a refill = %+v, want allowed with 0 remaining", result)
+	}
+
+	// Another key has its own bucket
+	if result, _ := store.Take("other", rate); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("take of another key = %+v, want allowed with 2 remaining", result)
+	}
+
+	// A bucket never holds more than its burst
+	clock.Advance(time.Hour)
+	if result, _ := store.Take("k", rate); result.Remaining != 2 {
+		t.Errorf("remaining after an hour = %d, want 2", result.Remaining)
+	}
+}
+
+func TestMemoryStoreSweepsByOwnPeriod(t *testing.T) {
+	store, clock := newTestStore()
+	hourly := Rate{Burst: 2, Period: time.Hour}
+	perSecond := PerSecond(10)
+
+	store.Take("hourly", hourly)
+	store.Take("hourly", hourly)
+
+	// The sweep a short-period caller runs keeps the hourly bucket, it is not
+	// full again yet
+	clock.Advance(2 * sweepInterval)
+	store.Take("fast", perSecond)
+	if _, ok := store.buckets["hourly"]; !ok {
+		t.Fatal("hourly bucket swept before its period")
+	}
+	if result, _ := store.Take("hourly", hourly); result.Allowed {
+		t.Error("hourly bucket reset by the sweep")
+	}
+
+	// Idle past their own period, both go
+	clock.Advance(2 * time.Hour)
+	store.Take("other", perSecond)
+	for _, key := range []string{"hourly", "fast"} {
+		if _, ok := store.buckets[key]; ok {
+			t.Errorf("bucket %s not swept", key)
+		}
+	}
+}
+
+func TestLimitIgnoresUnverifiedAPIKeys(t *testing.T) {
+	store, _ := newTestStore()
+	limiter := NewRateLimiter(store)
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	}, limiter.Limit(Rate{Burst: 2, Period: time.Minute}))
+
+	statuses := make([]int, 3)
+	for i := range statuses {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		req.Header.Set("X-API-Key", "key-"+strconv.Itoa(i))
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses[i] = resp.StatusCode
+	}
+	want := []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusTooManyRequests}
+	for i := range want {
+		if statuses[i] != want[i] {
+			t.Fatalf("statuses = %v, want %v: a new X-API-Key must not get a new bucket", statuses, want)
+		}
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	for _, trusted := range []bool{true, false} {
+		config := fiber.Config{ProxyHeader: "X-Real-IP", EnableTrustedProxyCheck: true, EnableIPValidation: true}
+		if trusted {
+			config.TrustedProxies = []string{"0.0.0.0"} // the peer of app.Test
+		}
+		app := fiber.New(config)
+		app.Use(Actor("X-Actor"))
+		app.Get("/", func(c fiber.Ctx) error {
+			return c.SendString(ClientKey(c))
+		})
+		app.Get("/verified", func(c fiber.Ctx) error {
+			c.Locals("api_key", "verified")
+			return c.SendString(ClientKey(c))
+		})
+
+		tests := []struct {
+			path    string
+			headers map[string]string
+			want    string
+		}{
+			{"/", nil, "ip:0.0.0.0"},
+			{"/", map[string]string{"X-Real-IP": "203.0.113.7"}, "ip:203.0.113.7"},
+			{"/", map[string]string{"X-Real-IP": "203.0.113.7", "X-Actor": "juan"}, "user:juan"},
+			{"/verified", map[string]string{"X-Actor": "juan"}, "key:verified"},
+		}
+		for _, tt := range tests {
+			want := tt.want
+			if !trusted && want == "ip:203.0.113.7" {
+				want = "ip:0.0.0.0"
+			}
+			req := httptest.NewRequest(fiber.MethodGet, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != want {
+				t.Errorf("trusted proxy %v, %s %v: key %q, want %q", trusted, tt.path, tt.headers, body, want)
+			}
+		}
+	}
+}
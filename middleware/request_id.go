@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"sample/logger"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID is the header used to accept and return the request ID
+const HeaderRequestID = "X-Request-ID"
+
+// RequestID accepts the caller's X-Request-ID or generates one, and makes it
+// available to the logger through the user context
+func RequestID() fiber.Handler {
+	return func(c fiber.Ctx) error {
+		id := c.Get(HeaderRequestID)
+		if id == "" || len(id) > 128 {
+			id = uuid.NewString()
+		}
+
+		c.Set(HeaderRequestID, id)
+		c.Locals("request_id", id)
+		c.SetUserContext(logger.WithRequestID(c.UserContext(), id))
+
+		return c.Next()
+	}
+}
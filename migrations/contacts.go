@@ -0,0 +1,82 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// contactEmails are the email columns unique whatever their case
+var contactEmails = []struct{ table, column string }{
+	{"contacts", "email"},
+	{"contact_merchants", "merchant_email"},
+}
+
+// normalizeContacts adds the phone types, makes room for E.164 numbers and
+// lowercases the emails under a unique index of their lowercase. The phone
+// numbers themselves are rewritten by the backfill command, which parses
+// them in Go.
+func normalizeContacts(tx *gorm.DB) error {
+	columns := []struct {
+		model any
+		field string
+	}{
+		{&contactPhones{}, "OwnerPhoneType"},
+		{&contactPhones{}, "OwnerOtherPhoneType"},
+		{&contactMerchantPhones{}, "MerchantPhoneType"},
+	}
+	for _, col := range columns {
+		if tx.Migrator().HasColumn(col.model, col.field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(col.model, col.field); err != nil {
+			return err
+		}
+	}
+	// SQLite does not enforce lengths, and altering a column would recreate
+	// the table without the search triggers
+	if tx.Dialector.Name() != "sqlite" {
+		for _, field := range []string{"OwnerPhoneNumber", "OwnerOtherPhoneNumber"} {
+			if err := tx.Migrator().AlterColumn(&contactPhones{}, field); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, c := range contactEmails {
+		var clashes []struct {
+			Email string
+			IDs   string
+		}
+		err := tx.Raw(fmt.Sprintf(`SELECT lower(trim(%[2]s)) AS email, %[3]s AS ids FROM %[1]s
+WHERE %[2]s <> '' GROUP BY lower(trim(%[2]s)) HAVING COUNT(*) > 1`, c.table, c.column, idList(tx))).Scan(&clashes).Error
+		if err != nil {
+			return err
+		}
+		if len(clashes) > 0 {
+			list := make([]string, len(clashes))
+			for i, clash := range clashes {
+				list[i] = fmt.Sprintf("%s (ids %s)", clash.Email, clash.IDs)
+			}
+			return fmt.Errorf("%s.%s: rows differ only by case, merge or fix them first: %s", c.table, c.column, strings.Join(list, ", "))
+		}
+
+		err = execAll(tx, []string{
+			fmt.Sprintf("UPDATE %[1]s SET %[2]s = lower(trim(%[2]s)) WHERE %[2]s <> lower(trim(%[2]s))", c.table, c.column),
+			fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS idx_%[1]s_%[2]s_lower ON %[1]s (lower(%[2]s))", c.table, c.column),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idList is the aggregate listing the ids of a group
+func idList(tx *gorm.DB) string {
+	if tx.Dialector.Name() == "sqlite" {
+		return "group_concat(id, ', ')"
+	}
+	return "string_agg(id::text, ', ' ORDER BY id)"
+}
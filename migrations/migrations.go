@@ -0,0 +1,145 @@
+package migrations
+
+import (
+	"fmt"
+
+	"sample/database"
+
+	"gorm.io/gorm"
+)
+
+// All lists every schema migration in the order it must be applied.
+// Never edit or reorder an entry once it has shipped, append a new one instead.
+// The entries build the tables from their snapshots in schema.go, never
+// from the models.
+var All = []database.Migration{
+	{
+		ID: "0001_initial_schema",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&baseCustomer{},
+				&baseAddress{},
+				&baseIdentification{},
+				&baseContact{},
+				&baseMerchant{},
+				&baseProduct{},
+				&baseContactMerchant{},
+				&baseAddressMerchant{},
+			)
+		},
+	},
+	{
+		ID: "0002_audit_log",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&auditEntry{})
+		},
+	},
+	{
+		// audit.Stamps on every table, the existing rows count as created now
+		ID: "0003_stamps",
+		Migrate: func(tx *gorm.DB) error {
+			now := tx.NowFunc()
+			for _, table := range baseTables {
+				migrator := tx.Table(table).Migrator()
+				for _, field := range []string{"CreatedAt", "UpdatedAt", "CreatedBy", "UpdatedBy"} {
+					if migrator.HasColumn(&stamps{}, field) {
+						continue
+					}
+					if err := migrator.AddColumn(&stamps{}, field); err != nil {
+						return err
+					}
+				}
+				// Named after the table, CreateIndex would name it after stamps
+				index := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%[1]s_updated_at ON %[1]s (updated_at)", table)
+				if err := tx.Exec(index).Error; err != nil {
+					return err
+				}
+
+				err := tx.Table(table).Where("created_at IS NULL").Updates(map[string]any{
+					"created_at": now,
+					"updated_at": now,
+					"created_by": "",
+					"updated_by": "",
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Indexes of package search: full-text and trigram on Postgres, FTS5 on SQLite
+		ID: "0004_search",
+		Migrate: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() == "sqlite" {
+				return sqliteSearch(tx)
+			}
+			return postgresSearch(tx)
+		},
+	},
+	{
+		ID: "0005_customer_redirects",
+		Migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&customerRedirect{})
+		},
+	},
+	{
+		// E.164 phone numbers with their types, emails unique whatever their case
+		ID:      "0006_contact_normalization",
+		Migrate: normalizeContacts,
+	},
+	{
+		// Verification of the identifications, the existing ones are pending
+		ID: "0007_identification_verification",
+		Migrate: func(tx *gorm.DB) error {
+			identification := &identificationVerification{}
+			for _, field := range []string{"VerificationStatus", "ReviewedBy", "ReviewedAt"} {
+				if !tx.Migrator().HasColumn(identification, field) {
+					if err := tx.Migrator().AddColumn(identification, field); err != nil {
+						return err
+					}
+				}
+			}
+			for _, field := range []string{"VerificationStatus", "IDExpiryDate"} {
+				if !tx.Migrator().HasIndex(identification, field) {
+					if err := tx.Migrator().CreateIndex(identification, field); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		ID: "0008_documents",
+		Migrate: func(tx *gorm.DB) error {
+			if tx.Migrator().HasTable(&document{}) {
+				return nil
+			}
+			return tx.Migrator().CreateTable(&document{})
+		},
+	},
+	{
+		// The stock ledger, the quantities of the existing products are its opening balances
+		ID:      "0009_stock_movements",
+		Migrate: stockMovements,
+	},
+	{
+		// The columns the duplicate candidates are looked up by, the TINs and
+		// emails are unique and indexed already
+		ID: "0010_duplicate_candidates",
+		Migrate: func(tx *gorm.DB) error {
+			// The pattern operators let Postgres serve LIKE 'prefix%'
+			lastName := "last_name varchar_pattern_ops"
+			if tx.Dialector.Name() == "sqlite" {
+				lastName = "last_name"
+			}
+			return execAll(tx, []string{
+				"CREATE INDEX IF NOT EXISTS idx_customers_last_name ON customers (" + lastName + ")",
+				"CREATE INDEX IF NOT EXISTS idx_contacts_owner_phone_number ON contacts (owner_phone_number)",
+				"CREATE INDEX IF NOT EXISTS idx_contacts_owner_other_phone_number ON contacts (owner_other_phone_number)",
+			})
+		},
+	},
+}
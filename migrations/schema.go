@@ -0,0 +1,207 @@
+package migrations
+
+import "time"
+
+// The tables as each migration left them. The migrations never use the
+// models, which keep changing: a shipped migration must build the same
+// schema whenever it runs. A column added to a model goes in a new migration
+// with its own snapshot.
+
+// The schema of 0001_initial_schema
+type (
+	baseCustomer struct {
+		ID                           uint                 `gorm:"primaryKey;autoIncrement"`
+		Title                        string               `gorm:"size:10"`
+		FullName                     string               `gorm:"size:100;not null"`
+		LastName                     string               `gorm:"size:100;not null"`
+		OwnerGender                  string               `gorm:"size:10"`
+		DateOfBirth                  time.Time            `gorm:"not null"`
+		PlaceOfBirth                 string               `gorm:"size:100"`
+		Job                          string               `gorm:"size:50"`
+		TaxpayerIdentificationNumber string               `gorm:"size:20;unique"`
+		Addresses                    []baseAddress        `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;"`
+		Identifications              []baseIdentification `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;"`
+		Contacts                     []baseContact        `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;"`
+		Merchant                     []baseMerchant       `gorm:"foreignKey:CustomerID;constraint:OnDelete:CASCADE;"`
+	}
+
+	baseAddress struct {
+		ID           uint   `gorm:"primaryKey;autoIncrement"`
+		CustomerID   int    `gorm:"index;not null"`
+		Address      string `gorm:"size:255"`
+		Region       string `gorm:"size:50"`
+		Province     string `gorm:"size:50"`
+		Municipality string `gorm:"size:50"`
+		Barangays    string `gorm:"size:50"`
+		PostalCode   string `gorm:"size:10"`
+	}
+
+	baseIdentification struct {
+		ID           uint   `gorm:"primaryKey;autoIncrement"`
+		CustomerID   int    `gorm:"index;not null"`
+		IDType       string `gorm:"size:50"`
+		IDNumber     string `gorm:"size:50;unique"`
+		IDExpiryDate time.Time
+	}
+
+	baseContact struct {
+		ID                    uint   `gorm:"primaryKey;autoIncrement"`
+		CustomerID            int    `gorm:"index;not null"`
+		OwnerPhoneNumber      string `gorm:"size:15"`
+		OwnerOtherPhoneNumber string `gorm:"size:15"`
+		Email                 string `gorm:"size:100;unique"`
+	}
+
+	baseMerchant struct {
+		ID              uint                  `gorm:"primaryKey;autoIncrement"`
+		CustomerID      int                   `gorm:"index;not null"`
+		Name            string                `gorm:"size:50"`
+		Product         []baseProduct         `gorm:"foreignKey:MerchantID;constraint:OnDelete:CASCADE;"`
+		AddressMerchant []baseAddressMerchant `gorm:"foreignKey:MerchantID;constraint:OnDelete:CASCADE;"`
+		ContactMerchant []baseContactMerchant `gorm:"foreignKey:MerchantID;constraint:OnDelete:CASCADE;"`
+	}
+
+	baseProduct struct {
+		ID          uint      `gorm:"primaryKey;autoIncrement"`
+		MerchantID  int       `gorm:"index;not null"`
+		Name        string    `gorm:"size:20"`
+		Quantity    int       `gorm:"size:100;not null"`
+		DeliverDate time.Time `gorm:"not null"`
+	}
+
+	baseAddressMerchant struct {
+		ID           uint   `gorm:"primaryKey;autoIncrement"`
+		MerchantID   int    `gorm:"index;not null"`
+		Address      string `gorm:"size:255"`
+		Region       string `gorm:"size:50"`
+		Province     string `gorm:"size:50"`
+		Municipality string `gorm:"size:50"`
+		Barangays    string `gorm:"size:50"`
+		PostalCode   string `gorm:"size:10"`
+	}
+
+	baseContactMerchant struct {
+		ID                  uint   `gorm:"primaryKey;autoIncrement"`
+		MerchantID          int    `gorm:"index;not null"`
+		MerchantPhoneNumber string `gorm:"size:20"`
+		MerchantEmail       string `gorm:"size:100;unique"`
+	}
+)
+
+func (baseCustomer) TableName() string        { return "customers" }
+func (baseAddress) TableName() string         { return "addresses" }
+func (baseIdentification) TableName() string  { return "identifications" }
+func (baseContact) TableName() string         { return "contacts" }
+func (baseMerchant) TableName() string        { return "merchants" }
+func (baseProduct) TableName() string         { return "products" }
+func (baseAddressMerchant) TableName() string { return "address_merchants" }
+func (baseContactMerchant) TableName() string { return "contact_merchants" }
+
+// baseTables are the tables of 0001_initial_schema, in the order of their
+// foreign keys
+var baseTables = []string{
+	"customers", "addresses", "identifications", "contacts",
+	"merchants", "products", "contact_merchants", "address_merchants",
+}
+
+// auditEntry is the audit log of 0002_audit_log
+type auditEntry struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement"`
+	Actor      string    `gorm:"size:100;index;not null"`
+	RequestID  string    `gorm:"size:64"`
+	Resource   string    `gorm:"size:50;not null;index:idx_audit_log_resource"`
+	ResourceID uint      `gorm:"not null;index:idx_audit_log_resource"`
+	Action     string    `gorm:"size:10;not null"`
+	Diff       string    `gorm:"type:text"`
+	CreatedAt  time.Time `gorm:"not null;index"`
+}
+
+func (auditEntry) TableName() string { return "audit_log" }
+
+// stamps are the columns of audit.Stamps, added to every table by 0003_stamps
+// and part of the tables created after it
+type stamps struct {
+	CreatedAt time.Time
+	UpdatedAt time.Time `gorm:"index"`
+	CreatedBy string    `gorm:"size:100"`
+	UpdatedBy string    `gorm:"size:100"`
+}
+
+// customerRedirect is the table of 0005_customer_redirects
+type customerRedirect struct {
+	FromID uint `gorm:"primaryKey;autoIncrement:false"`
+	ToID   uint `gorm:"index;not null"`
+
+	Stamps stamps `gorm:"embedded"`
+}
+
+func (customerRedirect) TableName() string { return "customer_redirects" }
+
+// The columns of 0006_contact_normalization
+type (
+	contactPhones struct {
+		OwnerPhoneNumber      string `gorm:"size:16"`
+		OwnerPhoneType        string `gorm:"size:10"`
+		OwnerOtherPhoneNumber string `gorm:"size:16"`
+		OwnerOtherPhoneType   string `gorm:"size:10"`
+	}
+
+	contactMerchantPhones struct {
+		MerchantPhoneType string `gorm:"size:10"`
+	}
+)
+
+func (contactPhones) TableName() string         { return "contacts" }
+func (contactMerchantPhones) TableName() string { return "contact_merchants" }
+
+// identificationVerification are the columns of 0007_identification_verification
+type identificationVerification struct {
+	IDExpiryDate       time.Time `gorm:"index"`
+	VerificationStatus string    `gorm:"size:10;not null;default:pending;index"`
+	ReviewedBy         string    `gorm:"size:100"`
+	ReviewedAt         *time.Time
+}
+
+func (identificationVerification) TableName() string { return "identifications" }
+
+// document is the table of 0008_documents
+type document struct {
+	ID               uint   `gorm:"primaryKey;autoIncrement"`
+	IdentificationID uint   `gorm:"not null;uniqueIndex:idx_documents_checksum,priority:1"`
+	FileName         string `gorm:"size:255"`
+	ContentType      string `gorm:"size:100;not null"`
+	Size             int64  `gorm:"not null"`
+	Checksum         string `gorm:"size:64;not null;index;uniqueIndex:idx_documents_checksum,priority:2"`
+	StorageKey       string `gorm:"size:255;not null"`
+
+	Identification *identificationRef `gorm:"constraint:OnDelete:CASCADE"`
+
+	Stamps stamps `gorm:"embedded"`
+}
+
+func (document) TableName() string { return "documents" }
+
+// stockMovement is the table of 0009_stock_movements
+type stockMovement struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement"`
+	ProductID uint   `gorm:"index;not null"`
+	Kind      string `gorm:"size:20;not null"`
+	Quantity  int    `gorm:"not null"`
+	Balance   int    `gorm:"not null"`
+	Reason    string `gorm:"size:255"`
+
+	Product *productRef `gorm:"constraint:OnDelete:CASCADE"`
+
+	Stamps stamps `gorm:"embedded"`
+}
+
+func (stockMovement) TableName() string { return "stock_movements" }
+
+// The rows a foreign key points to, only their primary key is needed
+type (
+	identificationRef struct{ ID uint }
+	productRef        struct{ ID uint }
+)
+
+func (identificationRef) TableName() string { return "identifications" }
+func (productRef) TableName() string        { return "products" }
@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// searchColumns are the columns searched by package search, by table
+var searchColumns = []struct {
+	table   string
+	name    string
+	columns []string
+	text    string
+}{
+	{"customers", "name", []string{"full_name", "last_name"}, "full_name || ' ' || last_name"},
+	{"contacts", "email", []string{"email"}, "email"},
+	{"contacts", "owner_phone_number", []string{"owner_phone_number"}, "owner_phone_number"},
+	{"contacts", "owner_other_phone_number", []string{"owner_other_phone_number"}, "owner_other_phone_number"},
+	{"merchants", "name", []string{"name"}, "name"},
+	{"products", "name", []string{"name"}, "name"},
+}
+
+// postgresSearch creates a full-text and a trigram index on each searched
+// expression. pg_trgm ships with Postgres but needs a user allowed to create
+// extensions.
+func postgresSearch(tx *gorm.DB) error {
+	statements := []string{"CREATE EXTENSION IF NOT EXISTS pg_trgm"}
+	for _, s := range searchColumns {
+		statements = append(statements,
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s_fts ON %s USING gin (to_tsvector('simple', %s))", s.table, s.name, s.table, s.text),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_%s_trgm ON %s USING gin ((%s) gin_trgm_ops)", s.table, s.name, s.table, s.text),
+		)
+	}
+	return execAll(tx, statements)
+}
+
+// sqliteSearch creates an FTS5 table over the searched columns of each table,
+// kept in sync by triggers, and indexes the existing rows
+func sqliteSearch(tx *gorm.DB) error {
+	columns := map[string][]string{}
+	var tables []string
+	for _, s := range searchColumns {
+		if _, ok := columns[s.table]; !ok {
+			tables = append(tables, s.table)
+		}
+		columns[s.table] = append(columns[s.table], s.columns...)
+	}
+
+	var statements []string
+	for _, table := range tables {
+		fts, cols := table+"_fts", columns[table]
+		list := strings.Join(cols, ", ")
+		insert := fmt.Sprintf("INSERT INTO %s(rowid, %s) VALUES (new.id, %s);", fts, list, prefixed("new.", cols))
+		remove := fmt.Sprintf("INSERT INTO %s(%s, rowid, %s) VALUES ('delete', old.id, %s);", fts, fts, list, prefixed("old.", cols))
+		statements = append(statements,
+			fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s, content='%s', content_rowid='id', tokenize='trigram')", fts, list, table),
+			fmt.Sprintf("CREATE TRIGGER %s_insert AFTER INSERT ON %s BEGIN %s END", fts, table, insert),
+			fmt.Sprintf("CREATE TRIGGER %s_delete AFTER DELETE ON %s BEGIN %s END", fts, table, remove),
+			fmt.Sprintf("CREATE TRIGGER %s_update AFTER UPDATE ON %s BEGIN %s %s END", fts, table, remove, insert),
+			fmt.Sprintf("INSERT INTO %s(%s) VALUES ('rebuild')", fts, fts),
+		)
+	}
+	return execAll(tx, statements)
+}
+
+func prefixed(prefix string, columns []string) string {
+	list := make([]string, len(columns))
+	for i, col := range columns {
+		list[i] = prefix + col
+	}
+	return strings.Join(list, ", ")
+}
+
+func execAll(tx *gorm.DB, statements []string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+// stockMovements creates the stock ledger and opens it with a receipt of the
+// quantity of every product in stock, as new products are: the kind and
+// reason are spelled as the models spelled them then. The table is
+// created on its own: AutoMigrate would migrate the products too, and
+// altering them recreates the table on SQLite without its search triggers.
+func stockMovements(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&stockMovement{}) {
+		if err := tx.Migrator().CreateTable(&stockMovement{}); err != nil {
+			return err
+		}
+	}
+
+	now := tx.NowFunc()
+	return tx.Exec(`INSERT INTO stock_movements (product_id, kind, quantity, balance, reason, created_at, updated_at, created_by, updated_by)
+		SELECT id, ?, quantity, quantity, ?, ?, ?, '', '' FROM products
+		WHERE quantity <> 0 AND NOT EXISTS (SELECT 1 FROM stock_movements WHERE product_id = products.id)`,
+		"receipt", "opening stock", now, now).Error
+}
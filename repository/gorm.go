@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sample/custom"
+	"sample/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Gorm is the GORM implementation of Repository
+type Gorm[T any] struct {
+	DB *gorm.DB
+}
+
+// NewGorm creates a Repository for T on db
+func NewGorm[T any](db *gorm.DB) *Gorm[T] {
+	return &Gorm[T]{DB: db}
+}
+
+func (r *Gorm[T]) List(ctx context.Context, opts ListOptions) ([]T, error) {
+	db, err := r.query(r.Conn(ctx), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []T
+	if err := preload(db, opts.Preloads).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// query adds the filters, order and page of opts to db
+func (r *Gorm[T]) query(db *gorm.DB, opts ListOptions) (*gorm.DB, error) {
+	stmt := &gorm.Statement{DB: r.DB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return nil, err
+	}
+	column := func(name string) (clause.Column, error) {
+		field := stmt.Schema.LookUpField(name)
+		if field == nil || field.DBName == "" {
+			return clause.Column{}, fmt.Errorf("%s has no column for field %s", stmt.Schema.Name, name)
+		}
+		return clause.Column{Table: clause.CurrentTable, Name: field.DBName}, nil
+	}
+
+	for _, f := range opts.Filters {
+		col, err := column(f.Field)
+		if err != nil {
+			return nil, err
+		}
+		switch f.Op {
+		case OpEq:
+			db = db.Where(clause.Eq{Column: col, Value: f.Value})
+		case OpNe:
+			db = db.Where(clause.Neq{Column: col, Value: f.Value})
+		case OpGt:
+			db = db.Where(clause.Gt{Column: col, Value: f.Value})
+		case OpGte:
+			db = db.Where(clause.Gte{Column: col, Value: f.Value})
+		case OpLt:
+			db = db.Where(clause.Lt{Column: col, Value: f.Value})
+		case OpLte:
+			db = db.Where(clause.Lte{Column: col, Value: f.Value})
+		case OpLike:
+			db = db.Where("LOWER(?) LIKE ?", col, "%"+strings.ToLower(fmt.Sprint(f.Value))+"%")
+		case OpIn:
+			values, _ := f.Value.([]any)
+			db = db.Where(clause.IN{Column: col, Values: values})
+		default:
+			return nil, fmt.Errorf("unknown filter operator %q", f.Op)
+		}
+	}
+
+	sorts := opts.Sort
+	if len(sorts) == 0 {
+		sorts = []Sort{{Field: "ID"}}
+	}
+	for _, s := range sorts {
+		col, err := column(s.Field)
+		if err != nil {
+			return nil, err
+		}
+		db = db.Order(clause.OrderByColumn{Column: col, Desc: s.Desc})
+	}
+
+	if opts.Limit > 0 {
+		db = db.Limit(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		db = db.Offset(opts.Offset)
+	}
+	return db, nil
+}
+
+func (r *Gorm[T]) Each(ctx context.Context, opts ListOptions, size int, fn func(batch []T) error) error {
+	db, err := r.query(r.Conn(ctx).Model(new(T)), opts)
+	if err != nil {
+		return err
+	}
+	rows, err := db.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	batch := make([]T, 0, size)
+	flush := func() error {
+		if err := r.preloadBatch(ctx, batch, opts.Preloads); err != nil {
+			return err
+		}
+		err := fn(batch)
+		batch = make([]T, 0, size)
+		return err
+	}
+	for rows.Next() {
+		var item T
+		if err := db.ScanRows(rows, &item); err != nil {
+			return err
+		}
+		if batch = append(batch, item); len(batch) == size {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// preloadBatch loads the relations of the rows of a cursor, which GORM only
+// preloads for Find. The rows are read again by ID with their relations.
+func (r *Gorm[T]) preloadBatch(ctx context.Context, batch []T, preloads []string) error {
+	if len(batch) == 0 || len(preloads) == 0 {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: r.DB}
+	if err := stmt.Parse(new(T)); err != nil {
+		return err
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil {
+		return fmt.Errorf("%s has no primary key to preload by", stmt.Schema.Name)
+	}
+
+	ids := make([]any, len(batch))
+	for i := range batch {
+		ids[i], _ = pk.ValueOf(ctx, reflect.ValueOf(&batch[i]).Elem())
+	}
+	var loaded []T
+	if err := preload(r.Conn(ctx), preloads).Where(clause.IN{Column: clause.PrimaryColumn, Values: ids}).Find(&loaded).Error; err != nil {
+		return err
+	}
+	byID := make(map[any]T, len(loaded))
+	for _, item := range loaded {
+		id, _ := pk.ValueOf(ctx, reflect.ValueOf(&item).Elem())
+		byID[id] = item
+	}
+	for i, id := range ids {
+		if item, ok := byID[id]; ok {
+			batch[i] = item
+		}
+	}
+	return nil
+}
+
+func (r *Gorm[T]) Get(ctx context.Context, id uint, preloads []string) (*T, error) {
+	var item T
+	if err := preload(r.Conn(ctx), preloads).First(&item, id).Error; err != nil {
+		return nil, Error(err)
+	}
+	return &item, nil
+}
+
+func (r *Gorm[T]) Create(ctx context.Context, item *T) error {
+	return r.Tx().Transaction(ctx, func(ctx context.Context) error {
+		if err := hook(ctx, item, CreatingHook.Creating); err != nil {
+			return err
+		}
+		if err := r.Conn(ctx).Create(item).Error; err != nil {
+			return Error(err)
+		}
+		return hook(ctx, item, CreatedHook.Created)
+	})
+}
+
+func (r *Gorm[T]) Update(ctx context.Context, id uint, changes *T, fields []string) (*T, error) {
+	fields = withDerived[T](fields)
+	var updated T
+	err := r.Tx().Transaction(ctx, func(ctx context.Context) error {
+		db := r.Conn(ctx)
+
+		var existing T
+		if err := db.First(&existing, id).Error; err != nil {
+			return Error(err)
+		}
+		if err := hook(ctx, changes, UpdatingHook.Updating); err != nil {
+			return err
+		}
+
+		// Update only the non-zero fields of the input struct, or the selected ones
+		update := db.Model(&existing).Where("id = ?", id)
+		if fields != nil {
+			update = update.Select(fields)
+		}
+		if fields == nil || len(fields) > 0 {
+			if err := update.Updates(changes).Error; err != nil {
+				return Error(err)
+			}
+		}
+
+		if err := db.First(&updated, id).Error; err != nil {
+			return Error(err)
+		}
+		return hook(ctx, &updated, UpdatedHook.Updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (r *Gorm[T]) Delete(ctx context.Context, id uint) error {
+	return r.Tx().Transaction(ctx, func(ctx context.Context) error {
+		db := r.Conn(ctx)
+
+		// The hooks get the row as it was, nested relations are not loaded
+		var existing T
+		hooked := hasHook[T, DeletingHook]() || hasHook[T, DeletedHook]()
+		if hooked {
+			if err := db.First(&existing, id).Error; err != nil {
+				return Error(err)
+			}
+			if err := hook(ctx, &existing, DeletingHook.Deleting); err != nil {
+				return err
+			}
+		}
+
+		result := db.Delete(new(T), id)
+		if result.Error != nil {
+			return Error(result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return custom.ErrNotFound
+		}
+
+		if hooked {
+			return hook(ctx, &existing, DeletedHook.Deleted)
+		}
+		return nil
+	})
+}
+
+// Conn returns the connection for ctx: its transaction, see Transactor, or DB
+func (r *Gorm[T]) Conn(ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return r.DB.WithContext(ctx)
+}
+
+// Tx returns the Transactor the writes of r run in
+func (r *Gorm[T]) Tx() Transactor {
+	return NewGormTransactor(r.DB)
+}
+
+func preload(db *gorm.DB, preloads []string) *gorm.DB {
+	for _, p := range preloads {
+		db = db.Preload(p)
+	}
+	return db
+}
+
+// Error translates GORM errors into the custom package errors
+func Error(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return custom.ErrNotFound
+	case utils.IsUniqueConstraintError(err):
+		return fmt.Errorf("%w: %v", custom.ErrDuplicate, err)
+	}
+	return err
+}
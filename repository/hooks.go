@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// Lifecycle hooks a model implements on its pointer. Gorm and Memory call
+// them on the item and on every nested item saved with it, inside the
+// transaction of the write, so the repositories given ctx see the pending
+// changes. An error, e.g. a custom.ValidationError or custom.ConflictError,
+// aborts and rolls back the write.
+//
+// The names differ from the GORM hooks (BeforeCreate(*gorm.DB) and so on)
+// which would only run for GORM.
+type (
+	CreatingHook interface {
+		Creating(ctx context.Context) error
+	}
+	CreatedHook interface {
+		Created(ctx context.Context) error
+	}
+	UpdatingHook interface {
+		Updating(ctx context.Context) error
+	}
+	UpdatedHook interface {
+		Updated(ctx context.Context) error
+	}
+	DeletingHook interface {
+		Deleting(ctx context.Context) error
+	}
+	DeletedHook interface {
+		Deleted(ctx context.Context) error
+	}
+)
+
+// hook calls call on item and its nested items, depth first
+func hook[H any](ctx context.Context, item any, call func(h H, ctx context.Context) error) error {
+	return walk(reflect.ValueOf(item), func(v reflect.Value) error {
+		if h, ok := v.Interface().(H); ok {
+			return call(h, ctx)
+		}
+		return nil
+	})
+}
+
+// hasHook reports whether T implements H, to skip loading rows for nothing
+func hasHook[T, H any]() bool {
+	_, ok := any(new(T)).(H)
+	return ok
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// walk calls fn with a pointer to v, then to each struct nested in its
+// fields, slices and pointers
+func walk(v reflect.Value, fn func(v reflect.Value) error) error {
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct || v.Elem().Type() == timeType {
+		return nil
+	}
+	if err := fn(v); err != nil {
+		return err
+	}
+
+	s := v.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		if !s.Type().Field(i).IsExported() {
+			continue
+		}
+		f := s.Field(i)
+		switch f.Kind() {
+		case reflect.Struct:
+			if err := walk(f.Addr(), fn); err != nil {
+				return err
+			}
+		case reflect.Pointer:
+			if err := walk(f, fn); err != nil {
+				return err
+			}
+		case reflect.Slice:
+			for j := 0; j < f.Len(); j++ {
+				if f.Index(j).Kind() == reflect.Struct {
+					if err := walk(f.Index(j).Addr(), fn); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
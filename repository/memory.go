@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sample/custom"
+)
+
+// Memory is an in-memory Repository for unit tests. T must have an uint ID field.
+// Preloads are ignored: nested relations are stored as given.
+type Memory[T any] struct {
+	mu     sync.RWMutex
+	items  map[uint]T
+	nextID uint
+
+	// UniqueKeys mirror the unique constraints of the table, two items with
+	// the same non-empty key make Create and Update fail with ErrDuplicate
+	UniqueKeys []func(item *T) string
+}
+
+// NewMemory creates an empty in-memory Repository
+func NewMemory[T any](uniqueKeys ...func(item *T) string) *Memory[T] {
+	return &Memory[T]{items: make(map[uint]T), UniqueKeys: uniqueKeys}
+}
+
+func (r *Memory[T]) List(ctx context.Context, opts ListOptions) ([]T, error) {
+	for _, f := range opts.Filters {
+		if !hasField[T](f.Field) {
+			return nil, fmt.Errorf("%T has no field %s", *new(T), f.Field)
+		}
+	}
+	for _, s := range opts.Sort {
+		if !hasField[T](s.Field) {
+			return nil, fmt.Errorf("%T has no field %s", *new(T), s.Field)
+		}
+	}
+
+	items := r.Where(func(item *T) bool {
+		for _, f := range opts.Filters {
+			if !matches(reflect.ValueOf(item).Elem().FieldByName(f.Field).Interface(), f.Op, f.Value) {
+				return false
+			}
+		}
+		return true
+	})
+
+	// Where already orders by ID, a stable sort keeps it as the tie-breaker
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, s := range opts.Sort {
+			a := reflect.ValueOf(&items[i]).Elem().FieldByName(s.Field).Interface()
+			b := reflect.ValueOf(&items[j]).Elem().FieldByName(s.Field).Interface()
+			if c, _ := compare(a, b); c != 0 {
+				return (c < 0) != s.Desc
+			}
+		}
+		return false
+	})
+
+	if opts.Offset > 0 {
+		items = items[min(opts.Offset, len(items)):]
+	}
+	if opts.Limit > 0 {
+		items = items[:min(opts.Limit, len(items))]
+	}
+	return items, nil
+}
+
+func (r *Memory[T]) Each(ctx context.Context, opts ListOptions, size int, fn func(batch []T) error) error {
+	items, err := r.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	for len(items) > 0 {
+		n := min(size, len(items))
+		if err := fn(items[:n:n]); err != nil {
+			return err
+		}
+		items = items[n:]
+	}
+	return nil
+}
+
+// Where returns the items matching match, ordered by ID
+func (r *Memory[T]) Where(match func(item *T) bool) []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]uint, 0, len(r.items))
+	for id := range r.items {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	items := []T{}
+	for _, id := range ids {
+		item := r.items[id]
+		if match(&item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (r *Memory[T]) Get(ctx context.Context, id uint, preloads []string) (*T, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.items[id]
+	if !ok {
+		return nil, custom.ErrNotFound
+	}
+	return &item, nil
+}
+
+// Create stores item. The hooks run without the lock, they may use r.
+func (r *Memory[T]) Create(ctx context.Context, item *T) error {
+	if err := hook(ctx, item, CreatingHook.Creating); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if r.conflicts(item, 0) {
+		r.mu.Unlock()
+		return custom.ErrDuplicate
+	}
+	r.nextID++
+	idField(item).SetUint(uint64(r.nextID))
+	r.items[r.nextID] = *item
+	r.mu.Unlock()
+
+	return hook(ctx, item, CreatedHook.Created)
+}
+
+func (r *Memory[T]) Update(ctx context.Context, id uint, changes *T, fields []string) (*T, error) {
+	fields = withDerived[T](fields)
+	if _, err := r.Get(ctx, id, nil); err != nil {
+		return nil, err
+	}
+	if err := hook(ctx, changes, UpdatingHook.Updating); err != nil {
+		return nil, err
+	}
+	updated, err := r.update(id, changes, fields)
+	if err != nil {
+		return nil, err
+	}
+	if err := hook(ctx, updated, UpdatedHook.Updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func (r *Memory[T]) update(id uint, changes *T, fields []string) (*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.items[id]
+	if !ok {
+		return nil, custom.ErrNotFound
+	}
+
+	// Like GORM Updates with a struct, only non-zero or selected fields are applied
+	target := reflect.ValueOf(&existing).Elem()
+	source := reflect.ValueOf(changes).Elem()
+	for i := 0; i < source.NumField(); i++ {
+		field, name := source.Field(i), source.Type().Field(i).Name
+		if name == "ID" || !field.CanInterface() {
+			continue
+		}
+		if fields == nil && field.IsZero() || fields != nil && !Selected(fields, name) {
+			continue
+		}
+		target.Field(i).Set(field)
+	}
+
+	if r.conflicts(&existing, id) {
+		return nil, custom.ErrDuplicate
+	}
+	r.items[id] = existing
+	return &existing, nil
+}
+
+func (r *Memory[T]) Delete(ctx context.Context, id uint) error {
+	existing, err := r.Get(ctx, id, nil)
+	if err != nil {
+		return err
+	}
+	if err := hook(ctx, existing, DeletingHook.Deleting); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	if _, ok := r.items[id]; !ok {
+		r.mu.Unlock()
+		return custom.ErrNotFound
+	}
+	delete(r.items, id)
+	r.mu.Unlock()
+
+	return hook(ctx, existing, DeletedHook.Deleted)
+}
+
+// conflicts reports whether item shares a unique key with a stored item other than self
+func (r *Memory[T]) conflicts(item *T, self uint) bool {
+	for _, key := range r.UniqueKeys {
+		value := key(item)
+		if value == "" {
+			continue
+		}
+		for id, other := range r.items {
+			if id != self && key(&other) == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasField[T any](name string) bool {
+	_, ok := reflect.TypeFor[T]().FieldByName(name)
+	return ok
+}
+
+// matches reports whether value compares to operand with op, like the SQL the Gorm repository builds
+func matches(value any, op Op, operand any) bool {
+	switch op {
+	case OpLike:
+		return strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(fmt.Sprint(operand)))
+	case OpIn:
+		operands, _ := operand.([]any)
+		for _, o := range operands {
+			if c, ok := compare(value, o); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	c, ok := compare(value, operand)
+	if !ok {
+		return false
+	}
+	switch op {
+	case OpEq:
+		return c == 0
+	case OpNe:
+		return c != 0
+	case OpGt:
+		return c > 0
+	case OpGte:
+		return c >= 0
+	case OpLt:
+		return c < 0
+	case OpLte:
+		return c <= 0
+	}
+	return false
+}
+
+// compare orders two values of the same kind, ok is false if they cannot be compared
+func compare(a, b any) (c int, ok bool) {
+	if ta, isTime := a.(time.Time); isTime {
+		tb, isTime := b.(time.Time)
+		return ta.Compare(tb), isTime
+	}
+
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	switch {
+	case va.CanInt() && vb.CanInt():
+		return cmp.Compare(va.Int(), vb.Int()), true
+	case va.CanUint() && vb.CanUint():
+		return cmp.Compare(va.Uint(), vb.Uint()), true
+	case va.CanInt() && vb.CanUint():
+		return cmp.Compare(float64(va.Int()), float64(vb.Uint())), true
+	case va.CanUint() && vb.CanInt():
+		return cmp.Compare(float64(va.Uint()), float64(vb.Int())), true
+	case va.CanFloat() && vb.CanFloat():
+		return cmp.Compare(va.Float(), vb.Float()), true
+	case va.Kind() == reflect.String && vb.Kind() == reflect.String:
+		return cmp.Compare(va.String(), vb.String()), true
+	case va.Kind() == reflect.Bool && vb.Kind() == reflect.Bool:
+		if va.Bool() == vb.Bool() {
+			return 0, true
+		}
+		if vb.Bool() {
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+func idField[T any](item *T) reflect.Value {
+	field := reflect.ValueOf(item).Elem().FieldByName("ID")
+	if !field.IsValid() || !field.CanSet() {
+		panic("repository.Memory: " + reflect.TypeOf(item).Elem().String() + " has no settable ID field")
+	}
+	return field
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"slices"
+)
+
+// Op is a comparison operator of a Filter
+type Op string
+
+const (
+	OpEq   Op = "eq"
+	OpNe   Op = "ne"
+	OpGt   Op = "gt"
+	OpGte  Op = "gte"
+	OpLt   Op = "lt"
+	OpLte  Op = "lte"
+	OpLike Op = "like" // case-insensitive substring match on strings
+	OpIn   Op = "in"   // Value is a []any
+)
+
+// Filter keeps the rows whose struct field Field compares to Value with Op.
+// Value has the Go type of the field.
+type Filter struct {
+	Field string
+	Op    Op
+	Value any
+}
+
+// Sort orders the rows by the struct field Field
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ListOptions narrows down a List call
+type ListOptions struct {
+	Preloads []string // relations to load with each row, ignored by Memory
+	Filters  []Filter // all must match
+	Sort     []Sort   // by ID when empty
+	Limit    int      // 0 means no limit
+	Offset   int
+}
+
+// Repository is the storage of one resource type. GORM backs it in the app
+// and Memory in unit tests. Errors are custom.ErrNotFound, custom.ErrDuplicate
+// or whatever the storage returned.
+type Repository[T any] interface {
+	List(ctx context.Context, opts ListOptions) ([]T, error)
+	// Each calls fn with the rows List would return, size at a time. They are
+	// read from a cursor, so they are never all in memory.
+	Each(ctx context.Context, opts ListOptions, size int, fn func(batch []T) error) error
+	Get(ctx context.Context, id uint, preloads []string) (*T, error)
+	Create(ctx context.Context, item *T) error
+	// Update writes changes to the row with id and returns the result. With
+	// fields nil only the non-zero fields are written, otherwise exactly the
+	// named struct fields, zero or not.
+	Update(ctx context.Context, id uint, changes *T, fields []string) (*T, error)
+	Delete(ctx context.Context, id uint) error
+}
+
+// Selected reports whether an Update with fields explicitly writes the struct field name
+func Selected(fields []string, name string) bool {
+	return slices.Contains(fields, name)
+}
+
+// DerivedFields is implemented by models whose hooks compute fields from
+// others, e.g. the type of a phone number from the number. An Update
+// selecting a field writes the fields derived from it too, the hooks set
+// them on changes but they would not be written and would go stale.
+type DerivedFields interface {
+	// DerivedFields maps a struct field to the struct fields derived from it
+	DerivedFields() map[string][]string
+}
+
+// withDerived returns fields with the fields of T derived from them, see
+// DerivedFields. A nil fields, the non-zero fields, is returned as is.
+func withDerived[T any](fields []string) []string {
+	model, ok := any(new(T)).(DerivedFields)
+	if !ok || fields == nil {
+		return fields
+	}
+	derived := model.DerivedFields()
+	all := slices.Clone(fields)
+	for _, field := range fields {
+		for _, name := range derived[field] {
+			if !slices.Contains(all, name) {
+				all = append(all, name)
+			}
+		}
+	}
+	return all
+}
@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Transactor runs fn in a transaction. The repositories given the ctx of fn
+// take part in it; an error returned by fn rolls it back.
+type Transactor interface {
+	Transaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// GormTransactor opens transactions on DB, or a savepoint when ctx is
+// already in one
+type GormTransactor struct {
+	DB *gorm.DB
+}
+
+// NewGormTransactor creates the Transactor of the Gorm repositories on db
+func NewGormTransactor(db *gorm.DB) *GormTransactor {
+	return &GormTransactor{DB: db}
+}
+
+func (t *GormTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	db := t.DB
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		db = tx
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, txKey{}, tx))
+	})
+}
+
+// NopTransactor runs fn as is, for the Memory repositories which cannot roll back
+type NopTransactor struct{}
+
+func (NopTransactor) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -1,8 +1,44 @@
 package response
 
+import (
+	"strings"
+
+	"sample/codec"
+	"sample/logger"
+
+	"github.com/gofiber/fiber/v3"
+)
+
 // ErrorModel is the structure for API error responses
 type ErrorModel struct {
-	RetCode any `json:"ret_code"` // Return Code
-	Message any `json:"message"`  // Error Message
-	Data    any `json:"data"`     // Error details
+	RetCode   any    `json:"ret_code"`             // Return Code
+	Message   any    `json:"message"`              // Error Message
+	Data      any    `json:"data"`                 // Error details
+	RequestID string `json:"request_id,omitempty"` // Request ID, see middleware.RequestID
+}
+
+// Send writes body with the given status, stamping the request ID of c. The
+// body is encoded in the media type of the Accept header, JSON, XML or
+// MessagePack, and answered 406 in JSON when none of them is acceptable.
+func Send(c fiber.Ctx, status int, body ErrorModel) error {
+	body.RequestID = logger.RequestID(c.UserContext())
+	c.Vary(fiber.HeaderAccept)
+
+	media := c.Accepts(codec.Accepted...)
+	if media == "" {
+		status, media = fiber.StatusNotAcceptable, codec.JSON
+		body = ErrorModel{
+			RetCode:   string(NotAcceptable),
+			Message:   "Not Acceptable",
+			Data:      "accept one of " + strings.Join(codec.Offers, ", "),
+			RequestID: body.RequestID,
+		}
+	}
+
+	raw, err := codec.Marshal(media, body)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderContentType, media)
+	return c.Status(status).Send(raw)
 }
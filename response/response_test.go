@@ -0,0 +1,133 @@
+package response_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"sample/codec"
+	"sample/custom"
+	"sample/response"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func newApp() *fiber.App {
+	app := fiber.New()
+	app.Get("/ok", func(c fiber.Ctx) error {
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "OK",
+			Data:    map[string]any{"name": "Sari", "quantity": 5},
+		})
+	})
+	app.Get("/error", func(c fiber.Ctx) error {
+		return custom.SendErrorResponse(c, custom.NewHttpError("Gone fishing", fiber.StatusServiceUnavailable))
+	})
+	return app
+}
+
+// get requests path accepting accept and returns the status, the media
+// type and the body
+func get(t *testing.T, app *fiber.App, path, accept string) (int, string, []byte) {
+	t.Helper()
+	req := httptest.NewRequest(fiber.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set(fiber.HeaderAccept, accept)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, resp.Header.Get(fiber.HeaderContentType), body
+}
+
+func TestSendNegotiatesTheFormat(t *testing.T) {
+	app := newApp()
+
+	tests := []struct {
+		accept string
+		media  string
+		decode func([]byte) (map[string]any, error)
+	}{
+		{"", codec.JSON, decodeJSON},
+		{"*/*", codec.JSON, decodeJSON},
+		{"application/json", codec.JSON, decodeJSON},
+		{"text/xml", "text/xml", decodeXML},
+		{"application/xml;q=0.9, application/json;q=0.5", codec.XML, decodeXML},
+		{"application/x-msgpack", "application/x-msgpack", decodeMsgPack},
+	}
+	for _, tt := range tests {
+		status, media, body := get(t, app, "/ok", tt.accept)
+		if status != fiber.StatusOK || media != tt.media {
+			t.Errorf("Accept %q: %d %s, want 200 %s", tt.accept, status, media, tt.media)
+			continue
+		}
+		doc, err := tt.decode(body)
+		if err != nil {
+			t.Errorf("Accept %q: %v in %s", tt.accept, err, body)
+			continue
+		}
+		if doc["ret_code"] != "200" || doc["message"] != "OK" {
+			t.Errorf("Accept %q: body = %v, want ret_code 200 and message OK", tt.accept, doc)
+		}
+	}
+
+	status, media, body := get(t, app, "/ok", "text/html")
+	if doc, _ := decodeJSON(body); status != fiber.StatusNotAcceptable || media != codec.JSON || doc["ret_code"] != "406" {
+		t.Errorf("Accept text/html: %d %s %s, want a 406 in JSON", status, media, body)
+	}
+}
+
+func TestSendErrorResponseNegotiatesTheFormat(t *testing.T) {
+	status, media, body := get(t, newApp(), "/error", codec.MsgPack)
+	if status != fiber.StatusServiceUnavailable || media != codec.MsgPack {
+		t.Fatalf("error response: %d %s, want 503 %s", status, media, codec.MsgPack)
+	}
+	doc, err := decodeMsgPack(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc["ret_code"] != "503" || doc["message"] != "Gone fishing" {
+		t.Errorf("error body = %v, want ret_code 503 and the message", doc)
+	}
+}
+
+func decodeJSON(body []byte) (map[string]any, error) {
+	var doc map[string]any
+	err := json.Unmarshal(body, &doc)
+	return doc, err
+}
+
+func decodeMsgPack(body []byte) (map[string]any, error) {
+	var doc map[string]any
+	err := msgpack.Unmarshal(body, &doc)
+	return doc, err
+}
+
+// decodeXML reads the text of the elements under the root
+func decodeXML(body []byte) (map[string]any, error) {
+	var root struct {
+		XMLName  xml.Name
+		Elements []struct {
+			XMLName xml.Name
+			Text    string `xml:",chardata"`
+		} `xml:",any"`
+	}
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, err
+	}
+	doc := map[string]any{}
+	for _, e := range root.Elements {
+		doc[e.XMLName.Local] = e.Text
+	}
+	return doc, nil
+}
@@ -8,15 +8,26 @@ const (
     // Success Codes
     SuccessOK              RetCode = "200"
     SuccessCreated         RetCode = "201"
+    MultiStatus            RetCode = "207"
+
+    // Redirection Codes
+    PermanentRedirect      RetCode = "308"
     
     // Client Error Codes
     BadRequest             RetCode = "400"
     Unauthorized           RetCode = "401"
     Forbidden              RetCode = "403"
     NotFound               RetCode = "404"
+    NotAcceptable          RetCode = "406"
+    Conflict               RetCode = "409"
+    PayloadTooLarge        RetCode = "413"
+    UnsupportedMediaType   RetCode = "415"
     UnprocessableEntity    RetCode = "422"
+    FailedDependency       RetCode = "424"
+    TooManyRequests        RetCode = "429"
     
     // Server Error Codes
     InternalServerError    RetCode = "500"
     ServiceUnavailable     RetCode = "503"
+    GatewayTimeout         RetCode = "504"
 )
@@ -1,42 +1,139 @@
 package routes
 
 import (
+	"log/slog"
+	"sample/script"
+	"time"
 	merchantcontroller "sample/merchant/controller"
 	"sample/middleware"
+	"sample/audit"
+	auditcontroller "sample/audit/controller"
+	"sample/geo"
+	geocontroller "sample/geo/controller"
+	"sample/search"
+	searchcontroller "sample/search/controller"
+	"sample/repository"
 	customercontroller "sample/customer/controller"
+	customermodel "sample/customer/model"
+	customerservice "sample/customer/service"
+	merchantmodel "sample/merchant/model"
+	merchantservice "sample/merchant/service"
 
 	"github.com/gofiber/fiber/v3"
-	"gorm.io/gorm"
 )
 
+// Dependencies are what the routes are built from, see server.New
+type Dependencies struct {
+	Log     *slog.Logger
+	Limiter *middleware.RateLimiter
+
+	Customers *customerservice.Service
+	Merchants *merchantservice.MerchantService
+	Products  *merchantservice.ProductService
+	Tx        repository.Transactor
+	Audit     *audit.Log
+	Search    search.Searcher
+	Documents *customerservice.Documents
+	Downloads customercontroller.Downloads
+
+	// AuditReaders are the actors allowed to read /api/audit
+	AuditReaders []string
+}
+
 // SetupRoutes initializes the routes for the Fiber app
-func SetupRoutes(app *fiber.App, db *gorm.DB) {
-
-	// Group routes for persons under /api/person
-	customerGroup := app.Group("/api/customer", middleware.HeadersMiddleware())
-	{
-		customerGroup.Post("/", customercontroller.Createcustomer(db))
-		customerGroup.Get("/", customercontroller.GetAllcustomers(db))
-		customerGroup.Get("/:id", customercontroller.GetcustomerByID(db))
-		customerGroup.Put("/:id", customercontroller.Updatecustomer(db))
-		customerGroup.Delete("/:id", customercontroller.Deletecustomer(db))
-	}
+func SetupRoutes(app fiber.Router, deps Dependencies) {
+	log, limiter := deps.Log, deps.Limiter
 
-	// Group routes for persons under /api/person
-	merchantGroup := app.Group("/api/merchant", middleware.HeadersMiddleware())
-	{
-		merchantGroup.Post("/", merchantcontroller.CreateMerchant(db))
-		merchantGroup.Get("/", merchantcontroller.GetAllMerchant(db))
-		merchantGroup.Get("/:id", merchantcontroller.GetMerchantByID(db))
-		merchantGroup.Put("/:id", merchantcontroller.UpdateMerchant(db))
-		merchantGroup.Delete("/:id", merchantcontroller.DeleteMerchant(db))
+	// Token bucket per client and route, the list endpoints preload every relation so they get less
+	readLimit := middleware.PerMinute(120)
+	writeLimit := middleware.PerMinute(60)
+	listLimit := middleware.PerMinute(30)
+	bulkLimit := middleware.PerMinute(10)
+	importLimit := middleware.PerMinute(2)
+	exportLimit := middleware.PerMinute(5)
+
+	// The list endpoints get longer than script.DefaultStatementTimeout for their preloads
+	listTimeout := script.Timeout(30 * time.Second)
+	// A bulk request writes up to script.DefaultMaxBulkSize items in one go
+	bulkTimeout := script.Timeout(60 * time.Second)
+	// An import runs batch after batch until the file is done
+	importTimeout := script.Timeout(10 * time.Minute)
+	// An export streams every row, bound it like an import
+	exportTimeout := script.Timeout(10 * time.Minute)
+
+	// The same limits, transactions and audit log for every resource, children inherit them
+	limits := map[script.Action][]fiber.Handler{
+		script.ActionList:   {limiter.Limit(listLimit), listTimeout},
+		script.ActionGet:    {limiter.Limit(readLimit)},
+		script.ActionCreate: {limiter.Limit(writeLimit)},
+		script.ActionUpdate: {limiter.Limit(writeLimit)},
+		script.ActionPatch:  {limiter.Limit(writeLimit)},
+		script.ActionDelete: {limiter.Limit(writeLimit)},
+		script.ActionBulk:   {limiter.Limit(bulkLimit), bulkTimeout},
+		script.ActionImport: {limiter.Limit(importLimit), importTimeout},
+		script.ActionExport: {limiter.Limit(exportLimit), exportTimeout},
 	}
 
-	// Group routes for persons under /api/person
-	productGroup := app.Group("/api/product", middleware.HeadersMiddleware())
-	{
-		productGroup.Post("/", merchantcontroller.CreateProduct(db))
-		productGroup.Get("/", merchantcontroller.GetAllProduct(db))
+	customers, merchants, products := resources(deps)
+	customers.Middleware, merchants.Middleware, products.Middleware = limits, limits, limits
+
+	// Duplicate customers and their merge, /duplicates before /:id would take it for an ID
+	app.Get("/api/customer/duplicates", customercontroller.Duplicates(deps.Customers, log), middleware.HeadersMiddleware(), limiter.Limit(listLimit), listTimeout)
+	app.Post("/api/customer/:id/merge", customercontroller.Merge(deps.Customers, deps.Tx, deps.Audit, log), middleware.HeadersMiddleware(), limiter.Limit(writeLimit))
+
+	// Verification of the identifications, /expiring-ids before /:id too
+	app.Get("/api/customer/expiring-ids", customercontroller.Expiring(deps.Customers, log), middleware.HeadersMiddleware(), limiter.Limit(listLimit), listTimeout)
+	app.Post("/api/customer/:id/identifications/:identification_id/review", customercontroller.Review(deps.Customers, deps.Tx, deps.Audit, log), middleware.HeadersMiddleware(), limiter.Limit(writeLimit))
+
+	// Scans of the identifications, downloaded from signed URLs
+	documents := "/api/customer/:id/identifications/:identification_id/documents"
+	app.Post(documents, customercontroller.UploadDocument(deps.Documents, deps.Downloads, deps.Tx, deps.Audit, log), middleware.HeadersMiddleware(), limiter.Limit(writeLimit))
+	app.Get(documents, customercontroller.ListDocuments(deps.Documents, deps.Downloads, log), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+	app.Get(documents+"/:document_id", customercontroller.GetDocument(deps.Documents, deps.Downloads, log), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+	app.Delete(documents+"/:document_id", customercontroller.DeleteDocument(deps.Documents, deps.Tx, deps.Audit, log), middleware.HeadersMiddleware(), limiter.Limit(writeLimit))
+	app.Get("/api/documents/:id/content", customercontroller.DocumentContent(deps.Documents, deps.Downloads, log), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+
+	// The stock ledger of the products, the only way their quantity changes
+	app.Post("/api/product/:id/stock", merchantcontroller.Move(deps.Products, deps.Tx, deps.Audit, log), middleware.HeadersMiddleware(), limiter.Limit(writeLimit))
+	app.Get("/api/product/:id/stock", merchantcontroller.Movements(deps.Products, log), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+
+	script.Register(app.Group("/api/customer", middleware.HeadersMiddleware()), log, customers)
+	script.Register(app.Group("/api/merchant", middleware.HeadersMiddleware()), log, merchants)
+	script.Register(app.Group("/api/product", middleware.HeadersMiddleware()), log, products)
+
+	// Who changed what, for compliance, the sensitive values redacted
+	app.Get("/api/audit", auditcontroller.List(deps.Audit, log), middleware.HeadersMiddleware(), limiter.Limit(listLimit), middleware.RequireActor(deps.AuditReaders...))
+
+	// Look customers, merchants and products up by name, email or phone
+	app.Get("/api/search", searchcontroller.Search(deps.Search, log), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+
+	// The PSGC areas addresses are checked against, top down
+	app.Get("/api/geo/regions", geocontroller.Regions(), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+	app.Get("/api/geo/regions/:code/provinces", geocontroller.Children(geo.Province), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+	app.Get("/api/geo/provinces/:code/municipalities", geocontroller.Children(geo.Municipality), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+	app.Get("/api/geo/municipalities/:code/barangays", geocontroller.Children(geo.Barangay), middleware.HeadersMiddleware(), limiter.Limit(readLimit))
+}
+
+// Importers are the resources a spreadsheet can be imported into, by name,
+// for the import command
+func Importers(deps Dependencies) map[string]script.Importer {
+	customers, merchants, _ := resources(deps)
+	return map[string]script.Importer{
+		"customer": script.NewImporter(deps.Log, customers),
+		"merchant": script.NewImporter(deps.Log, merchants),
 	}
+}
+
+// resources are the definitions of the API with the transactions and audit
+// log of deps, children inherit them
+func resources(deps Dependencies) (script.Resource[customermodel.Customer], script.Resource[merchantmodel.Merchant], script.Resource[merchantmodel.Product]) {
+	customers := customercontroller.Resource(deps.Customers, deps.Merchants, deps.Products)
+	customers.Tx, customers.Audit = deps.Tx, deps.Audit
+
+	merchants := merchantcontroller.MerchantResource(deps.Merchants, deps.Products)
+	merchants.Tx, merchants.Audit = deps.Tx, deps.Audit
 
+	products := merchantcontroller.ProductResource(deps.Products)
+	products.Tx, products.Audit = deps.Tx, deps.Audit
+	return customers, merchants, products
 }
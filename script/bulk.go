@@ -0,0 +1,232 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"sample/custom"
+	"sample/response"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// DefaultMaxBulkSize caps the items of a bulk request when Resource.MaxBulkSize is not set
+const DefaultMaxBulkSize = 500
+
+// BulkResult is the outcome of the item at Index of a bulk request. Status
+// and Error are what the single-item endpoint would have answered.
+type BulkResult struct {
+	Index  int                  `json:"index"`
+	Status int                  `json:"status"`
+	ID     uint                 `json:"id,omitempty"`
+	Error  *response.ErrorModel `json:"error,omitempty"`
+}
+
+// BulkResponse is the data of a bulk response. Committed is false when an
+// atomic request was rolled back, its items that succeeded are answered 424.
+type BulkResponse struct {
+	Atomic    bool         `json:"atomic"`
+	Committed bool         `json:"committed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// bulkItem writes one item of a bulk request and returns its ID
+type bulkItem func(ctx context.Context, raw json.RawMessage) (uint, error)
+
+// errRollback rolls an atomic bulk request back once an item failed
+var errRollback = errors.New("bulk item failed")
+
+// invalidItem is an item that could not be decoded, answered 400 like the
+// body of a single-item request
+type invalidItem struct{ err error }
+
+func (e *invalidItem) Error() string { return e.err.Error() }
+
+// bulkCreate creates each item of an array, like POST /
+func (h *handlers[T]) bulkCreate(c fiber.Ctx) error {
+	return h.bulk(c, "Could not create resource", reflect.TypeFor[[]T](), func(ctx context.Context, raw json.RawMessage) (uint, error) {
+		input := new(T)
+		if err := json.Unmarshal(raw, input); err != nil {
+			return 0, &invalidItem{err}
+		}
+		if err := h.setParent(c, input); err != nil {
+			return 0, &invalidItem{err}
+		}
+		if err := h.createItem(ctx, input); err != nil {
+			return 0, err
+		}
+		return itemID(input), nil
+	})
+}
+
+func (h *handlers[T]) bulkUpdate(c fiber.Ctx) error {
+	return h.bulkWrite(c, false)
+}
+
+func (h *handlers[T]) bulkPatch(c fiber.Ctx) error {
+	return h.bulkWrite(c, true)
+}
+
+// bulkWrite updates each item of an array by its id, like PUT or PATCH /:id
+func (h *handlers[T]) bulkWrite(c fiber.Ctx, patch bool) error {
+	return h.bulk(c, "Could not update resource", reflect.TypeFor[[]T](), func(ctx context.Context, raw json.RawMessage) (uint, error) {
+		input := new(T)
+		if err := json.Unmarshal(raw, input); err != nil {
+			return 0, &invalidItem{err}
+		}
+		id := itemID(input)
+		if id == 0 {
+			return 0, custom.NewValidationError("id", "is required")
+		}
+		var fields []string
+		if patch {
+			var err error
+			if fields, err = h.patchFields(raw); err != nil {
+				return id, err
+			}
+		}
+		if err := h.setParent(c, input); err != nil {
+			return id, &invalidItem{err}
+		}
+		_, err := h.updateItem(ctx, c, id, input, fields)
+		return id, err
+	})
+}
+
+// bulkDelete deletes the items of an array of IDs, like DELETE /:id
+func (h *handlers[T]) bulkDelete(c fiber.Ctx) error {
+	return h.bulk(c, "Could not delete resource", reflect.TypeFor[[]uint](), func(ctx context.Context, raw json.RawMessage) (uint, error) {
+		var id uint
+		if err := json.Unmarshal(raw, &id); err != nil || id == 0 {
+			return 0, &invalidItem{fmt.Errorf("%s is not an ID", raw)}
+		}
+		return id, h.deleteItem(ctx, c, id)
+	})
+}
+
+// bulk runs write for each item of the array body, read as t. With atomic=true,
+// the default, the items run in one transaction that is rolled back when any
+// fails; otherwise each item commits on its own. Every item is attempted and
+// answered, 200 when all succeeded and 207 Multi-Status otherwise.
+func (h *handlers[T]) bulk(c fiber.Ctx, message string, t reflect.Type, write bulkItem) error {
+	atomic, err := strconv.ParseBool(c.Query("atomic", "true"))
+	if err != nil {
+		return badRequest(c, "Invalid query", fmt.Errorf("atomic must be true or false"))
+	}
+	body, err := bodyJSON(c, t)
+	if err != nil {
+		return BodyErrorResponse(c, err)
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(body, &items); err != nil {
+		return badRequest(c, "Invalid request body", errors.New("must be an array"))
+	}
+	maxSize := h.res.MaxBulkSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxBulkSize
+	}
+	if len(items) == 0 || len(items) > maxSize {
+		return badRequest(c, "Invalid request body", fmt.Errorf("must have between 1 and %d items", maxSize))
+	}
+
+	ctx, cancel := QueryContext(c)
+	defer cancel()
+
+	data := BulkResponse{Atomic: atomic, Committed: true}
+	if atomic {
+		// Each item runs in a savepoint, so a failed one does not abort the
+		// transaction and the following items are still checked
+		err = h.transaction(ctx, func(ctx context.Context) error {
+			data.Results = h.bulkResults(ctx, c, message, items, write)
+			for _, result := range data.Results {
+				if result.Error != nil {
+					return errRollback
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errRollback) {
+			data.Committed = false
+			rolledBack(data.Results)
+		} else if err != nil {
+			return ErrorResponse(c, ctx, h.log, err, message)
+		}
+	} else {
+		data.Results = h.bulkResults(ctx, c, message, items, write)
+	}
+
+	status, retCode, msg := fiber.StatusOK, response.SuccessOK, "Bulk success"
+	for _, result := range data.Results {
+		if result.Error != nil {
+			status, retCode, msg = fiber.StatusMultiStatus, response.MultiStatus, "Multi-Status"
+			break
+		}
+	}
+	return response.Send(c, status, response.ErrorModel{
+		RetCode: string(retCode),
+		Message: msg,
+		Data:    data,
+	})
+}
+
+// bulkResults writes the items one by one and answers each like the
+// single-item endpoint would
+func (h *handlers[T]) bulkResults(ctx context.Context, c fiber.Ctx, message string, items []json.RawMessage, write bulkItem) []BulkResult {
+	results := make([]BulkResult, len(items))
+	for i, raw := range items {
+		results[i].Index = i
+		// Once the statement timeout is hit the remaining items cannot run
+		if ctx.Err() != nil {
+			status, body := contextErrorModel(ctx)
+			results[i].Status, results[i].Error = status, &body
+			continue
+		}
+
+		id, err := write(ctx, raw)
+		results[i].ID = id
+		if err == nil {
+			results[i].Status = fiber.StatusOK
+			continue
+		}
+
+		var invalid *invalidItem
+		var status int
+		var body response.ErrorModel
+		switch {
+		case ctx.Err() != nil:
+			status, body = contextErrorModel(ctx)
+		case errors.As(err, &invalid):
+			status, body = fiber.StatusBadRequest, response.ErrorModel{
+				RetCode: string(response.BadRequest),
+				Message: "Invalid request body",
+				Data:    invalid.Error(),
+			}
+		default:
+			status, body = errorModel(err, message)
+			if status == fiber.StatusInternalServerError {
+				h.log.ErrorContext(c.UserContext(), message, "error", err, "index", i)
+			}
+		}
+		results[i].Status, results[i].Error = status, &body
+	}
+	return results
+}
+
+// rolledBack answers 424 Failed Dependency for the items of a rolled back
+// atomic request that succeeded on their own
+func rolledBack(results []BulkResult) {
+	for i := range results {
+		if results[i].Error == nil {
+			results[i].Status = fiber.StatusFailedDependency
+			results[i].Error = &response.ErrorModel{
+				RetCode: string(response.FailedDependency),
+				Message: "Rolled back",
+				Data:    "another item of the atomic request failed",
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package script_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	customermodel "sample/customer/model"
+	merchantmodel "sample/merchant/model"
+	"sample/servertest"
+)
+
+func TestBulkCreate(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	customer := &customermodel.Customer{FullName: "Juan", LastName: "Dela Cruz", DateOfBirth: time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if err := s.Customers.Create(ctx, customer); err != nil {
+		t.Fatal(err)
+	}
+	merchant := &merchantmodel.Merchant{CustomerID: int(customer.ID), Name: "Sari"}
+	if err := s.Merchants.Create(ctx, merchant); err != nil {
+		t.Fatal(err)
+	}
+	product := func(name string, quantity int) map[string]any {
+		return map[string]any{"merchant_id": merchant.ID, "name": name, "quantity": quantity, "date_of_delivery": "2024-05-01T00:00:00Z"}
+	}
+	items := []map[string]any{product("Rice", 5), product("Bread", -1), product("Sugar", 3)}
+	stored := func() int64 {
+		var count int64
+		if err := s.DB.Model(&merchantmodel.Product{}).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	tests := []struct {
+		path      string
+		committed bool
+		statuses  []float64
+		stored    int64
+	}{
+		// The failed item rolls the others back, they are answered 424
+		{"/api/product/bulk", false, []float64{424, 422, 424}, 0},
+		{"/api/product/bulk?atomic=false", true, []float64{200, 422, 200}, 2},
+	}
+	for _, tt := range tests {
+		status, body := servertest.Do(t, s.App, "POST", tt.path, items)
+		if status != 207 {
+			t.Fatalf("%s: %d %v, want 207", tt.path, status, body)
+		}
+		data := body["data"].(map[string]any)
+		if data["committed"] != tt.committed {
+			t.Errorf("%s: committed = %v, want %v", tt.path, data["committed"], tt.committed)
+		}
+		results := data["results"].([]any)
+		for i, r := range results {
+			result := r.(map[string]any)
+			if result["index"] != float64(i) || result["status"] != tt.statuses[i] {
+				t.Errorf("%s: result %d = %v, want status %v", tt.path, i, result, tt.statuses[i])
+			}
+		}
+		if n := stored(); n != tt.stored {
+			t.Errorf("%s: %d products stored, want %d", tt.path, n, tt.stored)
+		}
+	}
+
+	status, body := servertest.Do(t, s.App, "POST", "/api/product/bulk", items[:1])
+	if status != 200 || body["data"].(map[string]any)["committed"] != true {
+		t.Errorf("bulk of valid items: %d %v, want 200 and committed", status, body)
+	}
+	if status, _ := servertest.Do(t, s.App, "POST", "/api/product/bulk", []any{}); status != 400 {
+		t.Errorf("empty bulk: %d, want 400", status)
+	}
+}
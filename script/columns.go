@@ -0,0 +1,100 @@
+package script
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// columnPlan resolves the field paths of the columns of a file, see
+// Mapping, to the item and the has-many relations holding them
+type columnPlan struct {
+	root    *columnNode
+	nodes   []*columnNode // parents first
+	columns []*fileColumn
+	keyCol  *fileColumn // see Mapping.Key
+
+	byPath map[string]*columnNode
+	types  map[*columnNode]reflect.Type
+}
+
+// columnNode is the item, or a has-many relation of it, and the columns of
+// its fields
+type columnNode struct {
+	path     string // empty for the item
+	name     string // struct field in the parent
+	parent   *columnNode
+	columns  []*fileColumn
+	children []*columnNode
+}
+
+type fileColumn struct {
+	index  int
+	header string
+	path   string
+	field  field
+}
+
+func newColumnPlan(t reflect.Type) *columnPlan {
+	root := &columnNode{}
+	return &columnPlan{
+		root:   root,
+		nodes:  []*columnNode{root},
+		byPath: map[string]*columnNode{"": root},
+		types:  map[*columnNode]reflect.Type{root: t},
+	}
+}
+
+// add resolves the path of the column at index, e.g. merchant.product.name
+func (p *columnPlan) add(index int, header, path string) (*fileColumn, error) {
+	for _, col := range p.columns {
+		if col.path == path {
+			return nil, fmt.Errorf("%s is mapped twice", path)
+		}
+	}
+
+	segments := strings.Split(path, ".")
+	node := p.root
+	for _, name := range segments[:len(segments)-1] {
+		prefix := strings.TrimPrefix(node.path+"."+name, ".")
+		if child, ok := p.byPath[prefix]; ok {
+			node = child
+			continue
+		}
+		f, ok := typeFields(p.types[node])[name]
+		if !ok || !f.Relation || f.Type.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("%s: %s is not a list", path, name)
+		}
+		child := &columnNode{path: prefix, name: f.Name, parent: node}
+		node.children = append(node.children, child)
+		p.byPath[prefix], p.types[child] = child, f.Type.Elem()
+		p.order()
+		node = child
+	}
+
+	name := segments[len(segments)-1]
+	f, ok := typeFields(p.types[node])[name]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("%s is not a field", path)
+	case f.Relation:
+		return nil, fmt.Errorf("%s is a relation, map its fields", path)
+	}
+	col := &fileColumn{index: index, header: header, path: path, field: f}
+	node.columns = append(node.columns, col)
+	p.columns = append(p.columns, col)
+	return col, nil
+}
+
+// order lists the nodes parents first
+func (p *columnPlan) order() {
+	p.nodes = p.nodes[:0]
+	var walk func(*columnNode)
+	walk = func(n *columnNode) {
+		p.nodes = append(p.nodes, n)
+		for _, child := range n.children {
+			walk(child)
+		}
+	}
+	walk(p.root)
+}
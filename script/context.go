@@ -0,0 +1,89 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"sample/response"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// DefaultStatementTimeout bounds the queries of a request when its route does
+// not set its own with Timeout
+var DefaultStatementTimeout = 10 * time.Second
+
+const timeoutLocal = "statement_timeout"
+
+// Timeout overrides DefaultStatementTimeout for the route it is attached to
+func Timeout(d time.Duration) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		c.Locals(timeoutLocal, d)
+		return c.Next()
+	}
+}
+
+const shutdownLocal = "shutdown_context"
+
+// Shutdown hands ctx to the queries of the requests, see QueryContext. The
+// server cancels it once its shutdown deadline passes: until then the
+// requests in flight drain as usual.
+func Shutdown(ctx context.Context) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		c.Locals(shutdownLocal, ctx)
+		return c.Next()
+	}
+}
+
+// QueryContext derives the context for the queries of a request from its
+// user context, which carries the request ID and span. It ends at the
+// route's statement timeout, when the client disconnects, see
+// DisconnectInterval, or when the context given to Shutdown is cancelled.
+func QueryContext(c fiber.Ctx) (context.Context, context.CancelFunc) {
+	timeout := DefaultStatementTimeout
+	if d, ok := c.Locals(timeoutLocal).(time.Duration); ok {
+		timeout = d
+	}
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(c.UserContext(), timeout)
+	} else {
+		ctx, cancel = context.WithCancel(c.UserContext())
+	}
+
+	stopWatch := watchDisconnect(c.Context().Conn(), cancel)
+	stopShutdown := func() bool { return false }
+	if shutdown, ok := c.Locals(shutdownLocal).(context.Context); ok {
+		stopShutdown = context.AfterFunc(shutdown, cancel)
+	}
+	return ctx, func() {
+		stopWatch()
+		stopShutdown()
+		cancel()
+	}
+}
+
+// ContextErrorResponse answers 504 when the statement timeout of ctx was hit
+// and 503 when it was cancelled. Only call it once ctx.Err() is set.
+func ContextErrorResponse(c fiber.Ctx, ctx context.Context) error {
+	status, body := contextErrorModel(ctx)
+	return response.Send(c, status, body)
+}
+
+func contextErrorModel(ctx context.Context) (int, response.ErrorModel) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fiber.StatusGatewayTimeout, response.ErrorModel{
+			RetCode: string(response.GatewayTimeout),
+			Message: "Query timed out",
+			Data:    ctx.Err().Error(),
+		}
+	}
+	return fiber.StatusServiceUnavailable, response.ErrorModel{
+		RetCode: string(response.ServiceUnavailable),
+		Message: "Request cancelled",
+		Data:    ctx.Err().Error(),
+	}
+}
@@ -0,0 +1,52 @@
+package script
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+)
+
+// DisconnectInterval is how often QueryContext checks that the client of a
+// request is still connected, 0 turns the checks off
+var DisconnectInterval = time.Second
+
+// watchDisconnect calls cancel once the client has closed conn, checking
+// every DisconnectInterval until stop is called. fasthttp does not read the
+// connection while a handler runs, so nothing else would notice. Only plain
+// TCP connections can be checked: behind TLS, or in app.Test, a client that
+// goes away is only let go at the statement timeout.
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) (stop func()) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok || DisconnectInterval <= 0 {
+		return func() {}
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(DisconnectInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if peerClosed(raw) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	// Waits for the watcher, the connection may serve another request or be
+	// closed once the handler returns
+	return func() {
+		close(done)
+		<-exited
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package script
+
+import "syscall"
+
+// peerClosed cannot peek at a socket here, the requests of the clients that
+// went away run until their statement timeout
+func peerClosed(raw syscall.RawConn) bool {
+	return false
+}
@@ -0,0 +1,69 @@
+package script
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// tcpPair returns the server and client ends of a local TCP connection
+func tcpPair(t *testing.T) (server, client net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server, err = ln.Accept(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+	return server, client
+}
+
+func TestWatchDisconnect(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("no disconnect checks on " + runtime.GOOS)
+	}
+	interval := DisconnectInterval
+	DisconnectInterval = 10 * time.Millisecond
+	defer func() { DisconnectInterval = interval }()
+
+	// A pipelined request waiting is not a disconnect
+	server, client := tcpPair(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := watchDisconnect(server, cancel)
+	if _, err := client.Write([]byte("GET / HTTP/1.1\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	stop()
+	if ctx.Err() != nil {
+		t.Error("cancelled while the client is connected")
+	}
+	// Nor was it consumed
+	buf := make([]byte, 3)
+	if _, err := server.Read(buf); err != nil || string(buf) != "GET" {
+		t.Errorf("read after the checks = %q, %v, want the request", buf, err)
+	}
+
+	server, client = tcpPair(t)
+	ctx, cancel = context.WithCancel(context.Background())
+	stop = watchDisconnect(server, cancel)
+	defer stop()
+	client.Close()
+	select {
+	case <-ctx.Done():
+	case <-time.After(2 * time.Second):
+		t.Error("not cancelled once the client closed the connection")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin
+
+package script
+
+import (
+	"errors"
+	"syscall"
+)
+
+// peerClosed peeks at the socket without consuming what it holds: reading
+// the end of the stream means the client closed it, a pipelined request is
+// data and keeps it open. A client that only shut down its writing side
+// counts as gone too.
+func peerClosed(raw syscall.RawConn) bool {
+	closed := false
+	err := raw.Control(func(fd uintptr) {
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = (n == 0 && err == nil) || errors.Is(err, syscall.ECONNRESET)
+	})
+	return err == nil && closed
+}
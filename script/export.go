@@ -0,0 +1,163 @@
+package script
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"sample/repository"
+	"sample/spreadsheet"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// exportBatchSize is the number of rows an export reads from the cursor at a time
+const exportBatchSize = 500
+
+// export serves GET /export?format=csv|ndjson|xlsx: the rows list would
+// answer, filtered and sorted the same way, streamed from a cursor. The
+// columns are ?columns= or Resource.Export, field paths like those of a
+// Mapping, with one line per element of the has-many relations, see flatten.
+// Once streaming started an error can only cut the file short, it is logged.
+func (h *handlers[T]) export(c fiber.Ctx) error {
+	format, err := spreadsheet.ParseFormat(c.Query("format", string(spreadsheet.CSV)))
+	if err != nil {
+		return badRequest(c, "Invalid query", err)
+	}
+	opts, err := h.listOptions(c)
+	if err != nil {
+		return badRequest(c, "Invalid query", err)
+	}
+	paths := h.res.Export
+	if raw := c.Query("columns"); raw != "" {
+		paths = nil
+		for _, path := range strings.Split(raw, ",") {
+			if path = strings.TrimSpace(path); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	plan, err := planExport(reflect.TypeFor[T](), paths)
+	if err != nil {
+		return badRequest(c, "Invalid query", err)
+	}
+	opts.Preloads = plan.preloads()
+	if h.scope != nil {
+		parent, err := h.parentID(c)
+		if err != nil {
+			return badRequest(c, "invalid id", err)
+		}
+		opts.Filters = append(opts.Filters, repository.Filter{Field: h.scope.field.Name, Op: repository.OpEq, Value: parent})
+	}
+
+	// The body is written once the handler returned, c must not be used by then
+	ctx, cancel := QueryContext(c)
+	name := h.res.Name
+	if name == "" {
+		name = "export"
+	}
+	c.Set(fiber.HeaderContentType, format.ContentType())
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+		if err := h.writeExport(ctx, w, format, plan, opts); err != nil {
+			h.log.ErrorContext(ctx, "export stopped", "error", err)
+		}
+	})
+	return nil
+}
+
+func (h *handlers[T]) writeExport(ctx context.Context, w io.Writer, format spreadsheet.Format, plan *columnPlan, opts repository.ListOptions) error {
+	header := make([]string, len(plan.columns))
+	for i, col := range plan.columns {
+		header[i] = col.header
+	}
+	out, err := spreadsheet.NewWriter(w, format, header)
+	if err != nil {
+		return err
+	}
+	err = h.res.Service.Each(ctx, opts, exportBatchSize, func(batch []T) error {
+		for i := range batch {
+			for _, row := range plan.flatten(plan.root, reflect.ValueOf(&batch[i]).Elem()) {
+				if err := out.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	// What was read is still written out
+	return errors.Join(err, out.Close())
+}
+
+// planExport resolves the export columns, by default the columns of T
+func planExport(t reflect.Type, paths []string) (*columnPlan, error) {
+	if len(paths) == 0 {
+		paths = columnsOf(t)
+	}
+	plan := newColumnPlan(t)
+	for i, path := range paths {
+		if _, err := plan.add(i, path, path); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// preloads are the relations the columns of the plan read
+func (p *columnPlan) preloads() []string {
+	var preloads []string
+	for _, node := range p.nodes {
+		if node == p.root {
+			continue
+		}
+		names := []string{}
+		for n := node; n != p.root; n = n.parent {
+			names = append([]string{n.name}, names...)
+		}
+		preloads = append(preloads, strings.Join(names, "."))
+	}
+	return preloads
+}
+
+// flatten lays out elem and its relations on lines of the plan's columns.
+// Its fields are repeated on every line, the elements of each relation
+// follow each other on the lines, and the relations side by side, so an
+// import keyed on the item reads the lines back into the same item.
+func (p *columnPlan) flatten(node *columnNode, elem reflect.Value) [][]any {
+	var children [][][]any
+	lines := 1
+	for _, child := range node.children {
+		list := elem.FieldByName(child.name)
+		var rows [][]any
+		for i := 0; i < list.Len(); i++ {
+			rows = append(rows, p.flatten(child, list.Index(i))...)
+		}
+		children = append(children, rows)
+		lines = max(lines, len(rows))
+	}
+
+	out := make([][]any, lines)
+	for i := range out {
+		row := make([]any, len(p.columns))
+		for _, col := range node.columns {
+			row[col.index] = elem.FieldByName(col.field.Name).Interface()
+		}
+		for _, rows := range children {
+			if i >= len(rows) {
+				continue
+			}
+			for j, v := range rows[i] {
+				if v != nil {
+					row[j] = v
+				}
+			}
+		}
+		out[i] = row
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package script_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	customermodel "sample/customer/model"
+	merchantmodel "sample/merchant/model"
+	"sample/servertest"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// get sends GET path to app and returns the response and its body
+func get(t *testing.T, app *fiber.App, path string) (*http.Response, string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestExport(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	customer := &customermodel.Customer{FullName: "Juan", LastName: "Dela Cruz", DateOfBirth: time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if err := s.Customers.Create(ctx, customer); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, merchant := range []*merchantmodel.Merchant{
+		{CustomerID: int(customer.ID), Name: "Sari", Product: []merchantmodel.Product{
+			{Name: "Rice", Quantity: 5, DeliverDate: day}, {Name: "Sugar", Quantity: 3, DeliverDate: day},
+		}},
+		{CustomerID: int(customer.ID), Name: "Bakery", Product: []merchantmodel.Product{{Name: "Bread", Quantity: 7, DeliverDate: day}}},
+		{CustomerID: int(customer.ID), Name: "Carinderia"},
+	} {
+		if err := s.Merchants.Create(ctx, merchant); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// One line per product, the merchant repeated on each
+	resp, body := get(t, s.App, "/api/merchant/export?format=csv&columns=name,product.name,product.quantity&sort=name")
+	want := "name,product.name,product.quantity\nBakery,Bread,7\nCarinderia,,\nSari,Rice,5\nSari,Sugar,3\n"
+	if resp.StatusCode != 200 || body != want {
+		t.Errorf("CSV export: %d\n%s\nwant\n%s", resp.StatusCode, body, want)
+	}
+	if !slices.Contains(resp.TransferEncoding, "chunked") {
+		t.Errorf("CSV export transfer encoding %v, want it streamed in chunks", resp.TransferEncoding)
+	}
+	if got := resp.Header.Get(fiber.HeaderContentDisposition); got != `attachment; filename="merchant.csv"` {
+		t.Errorf("CSV export disposition %q", got)
+	}
+
+	// The filters of the list apply
+	resp, body = get(t, s.App, "/api/merchant/export?format=ndjson&filter[name]=Sari")
+	if resp.StatusCode != 200 {
+		t.Fatalf("NDJSON export: %d %s", resp.StatusCode, body)
+	}
+	var products []string
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("NDJSON line %s: %v", scanner.Text(), err)
+		}
+		if line["name"] != "Sari" || line["product.date_of_delivery"] != "2024-05-01T00:00:00Z" {
+			t.Errorf("NDJSON line %v, want Sari with its product delivered on 2024-05-01", line)
+		}
+		products = append(products, line["product.name"].(string))
+	}
+	if !slices.Equal(products, []string{"Rice", "Sugar"}) {
+		t.Errorf("NDJSON products %v, want Rice and Sugar", products)
+	}
+
+	for _, query := range []string{"format=pdf", "columns=name,owner", "filter[owner]=Juan"} {
+		if resp, body := get(t, s.App, "/api/merchant/export?"+query); resp.StatusCode != 400 {
+			t.Errorf("export with %s: %d %s, want 400", query, resp.StatusCode, body)
+		}
+	}
+}
+
+// TestExportReadsInBatches exports more rows than a batch of the cursor
+func TestExportReadsInBatches(t *testing.T) {
+	s := servertest.New(t)
+	customer := &customermodel.Customer{FullName: "Juan", LastName: "Dela Cruz", DateOfBirth: time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if err := s.Customers.Create(context.Background(), customer); err != nil {
+		t.Fatal(err)
+	}
+	merchants := make([]merchantmodel.Merchant, 1200)
+	for i := range merchants {
+		merchants[i] = merchantmodel.Merchant{CustomerID: int(customer.ID), Name: "Sari"}
+	}
+	if err := s.DB.CreateInBatches(merchants, 200).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := get(t, s.App, "/api/merchant/export?columns=id")
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	if resp.StatusCode != 200 || len(lines) != len(merchants)+1 || lines[len(lines)-1] != "1200" {
+		t.Errorf("export: %d, %d lines ending with %s, want the header and %d IDs", resp.StatusCode, len(lines), lines[len(lines)-1], len(merchants))
+	}
+}
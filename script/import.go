@@ -0,0 +1,507 @@
+package script
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sample/custom"
+	"sample/response"
+	"sample/spreadsheet"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// DefaultImportBatchSize is the number of items imported per transaction
+// when ImportOptions.BatchSize is not set
+const DefaultImportBatchSize = 100
+
+// Mapping maps the columns of an import file to the fields of a resource
+type Mapping struct {
+	// Columns maps a header to a field path: the JSON name of a field, e.g.
+	// full_name, behind the has-many relations holding it, e.g.
+	// address.region or merchant.product.name. Without Columns every header
+	// is its own path. Columns that are not mapped are ignored.
+	Columns map[string]string `json:"columns"`
+
+	// Key is the path of the field grouping consecutive rows into one item,
+	// e.g. a merchant on several rows with one product each. Without it
+	// every row is an item.
+	Key string `json:"key"`
+}
+
+// ImportOptions tune an Import
+type ImportOptions struct {
+	DryRun    bool // create every batch and roll it back, reporting what would fail
+	BatchSize int  // items per transaction, DefaultImportBatchSize when zero
+}
+
+// RowError is an item of an import that was not created
+type RowError struct {
+	Row     int    `json:"row"`              // line of the file, the header is line 1
+	Column  string `json:"column,omitempty"` // header of the cell at fault, when known
+	Field   string `json:"field,omitempty"`
+	Status  int    `json:"status"` // what a single create would have answered
+	Message string `json:"message"`
+}
+
+// ImportReport sums up an import. Items spanning several rows are reported
+// on their first row.
+type ImportReport struct {
+	DryRun   bool       `json:"dry_run"`
+	Rows     int        `json:"rows"`     // data rows read, blank ones skipped
+	Items    int        `json:"items"`    // items built from the rows
+	Imported int        `json:"imported"` // items created, or that would have been on a dry run
+	Failed   int        `json:"failed"`
+	Errors   []RowError `json:"errors"`
+}
+
+// WriteCSV writes the errors of the report as a CSV file, one per line
+func (r *ImportReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"row", "column", "field", "status", "message"})
+	for _, e := range r.Errors {
+		cw.Write([]string{strconv.Itoa(e.Row), e.Column, e.Field, strconv.Itoa(e.Status), e.Message})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Importer creates the items of a spreadsheet, see NewImporter
+type Importer interface {
+	// Import reads the rows, header first, into items and creates them like
+	// POST / does, in transactions of opts.BatchSize items. Items that fail
+	// are reported and skipped, the others are created. The error is a
+	// custom.ValidationError for a file or mapping that cannot be read, and
+	// the storage error that stopped the import otherwise.
+	Import(ctx context.Context, rows spreadsheet.Reader, mapping Mapping, opts ImportOptions) (*ImportReport, error)
+}
+
+// NewImporter imports items of res, with its hooks, transactions and audit
+// log. A dry run needs res.Tx to roll the batches back.
+func NewImporter[T any](log *slog.Logger, res Resource[T]) Importer {
+	return newHandlers(res, log, "id", nil)
+}
+
+// errDryRun rolls a batch of a dry run back
+var errDryRun = errors.New("dry run")
+
+func (h *handlers[T]) Import(ctx context.Context, rows spreadsheet.Reader, mapping Mapping, opts ImportOptions) (*ImportReport, error) {
+	if opts.DryRun && h.res.Tx == nil {
+		return nil, errors.New("a dry run needs a transactor to roll back")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+
+	header, err := rows.Next()
+	if errors.Is(err, io.EOF) {
+		return nil, custom.NewValidationError("file", "is empty")
+	}
+	if err != nil {
+		return nil, custom.NewValidationError("file", err.Error())
+	}
+	plan, err := planImport(reflect.TypeFor[T](), header, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ImportReport{DryRun: opts.DryRun, Errors: []RowError{}}
+	var batch []*importItem
+	var item *importItem
+	// done queues the item being built and imports the batch once full
+	done := func() error {
+		if item == nil {
+			return nil
+		}
+		report.Items++
+		if item.err != nil {
+			report.fail(*item.err)
+		} else {
+			batch = append(batch, item)
+		}
+		item = nil
+		if len(batch) < opts.BatchSize {
+			return nil
+		}
+		err := h.importBatch(ctx, batch, plan, opts.DryRun, report)
+		batch = batch[:0]
+		return err
+	}
+
+	for line := 2; ; line++ {
+		cells, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, custom.NewValidationError("file", err.Error())
+		}
+		if blank(cells) {
+			continue
+		}
+		report.Rows++
+
+		key := plan.key(cells)
+		if item == nil || key == "" || key != item.key {
+			if err := done(); err != nil {
+				return report, err
+			}
+			item = newImportItem[T](line, key)
+		}
+		if item.err == nil {
+			item.err = plan.fill(item, cells, line)
+		}
+	}
+	if err := done(); err != nil {
+		return report, err
+	}
+	if len(batch) > 0 {
+		if err := h.importBatch(ctx, batch, plan, opts.DryRun, report); err != nil {
+			return report, err
+		}
+	}
+	// Rows that could not be read are reported before their batch ran
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Row < report.Errors[j].Row })
+	return report, nil
+}
+
+// importBatch creates the items of a batch in one transaction, each in a
+// savepoint so a failed one is skipped without aborting the others
+func (h *handlers[T]) importBatch(ctx context.Context, batch []*importItem, plan *columnPlan, dryRun bool, report *ImportReport) error {
+	var imported int
+	var failed []RowError
+	err := h.transaction(ctx, func(ctx context.Context) error {
+		imported, failed = 0, nil
+		for _, item := range batch {
+			err := h.createItem(ctx, item.value.Addr().Interface().(*T))
+			if err == nil {
+				imported++
+				continue
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+			failed = append(failed, h.rowError(ctx, plan, item.row, err))
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return err
+	}
+	report.Imported += imported
+	for _, e := range failed {
+		report.fail(e)
+	}
+	return nil
+}
+
+// rowError reports err like ErrorResponse would answer it
+func (h *handlers[T]) rowError(ctx context.Context, plan *columnPlan, row int, err error) RowError {
+	status, body := errorModel(err, "Could not import row")
+	e := RowError{Row: row, Status: status, Message: err.Error()}
+	var validationErr *custom.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		e.Field, e.Message = validationErr.Field, validationErr.Message
+		e.Column = plan.header(validationErr.Field)
+	case status == fiber.StatusInternalServerError:
+		h.log.ErrorContext(ctx, "could not import row", "row", row, "error", err)
+		e.Message = body.Message.(string)
+	}
+	return e
+}
+
+func (r *ImportReport) fail(e RowError) {
+	r.Failed++
+	r.Errors = append(r.Errors, e)
+}
+
+func blank(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func planImport(t reflect.Type, header []string, mapping Mapping) (*columnPlan, error) {
+	invalid := func(format string, args ...any) error {
+		return custom.NewValidationError("mapping", fmt.Sprintf(format, args...))
+	}
+
+	columns := mapping.Columns
+	if len(columns) == 0 {
+		columns = map[string]string{}
+		for _, h := range header {
+			if h = strings.TrimSpace(h); h != "" {
+				columns[h] = h
+			}
+		}
+	}
+
+	plan := newColumnPlan(t)
+	mapped := map[string]bool{}
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		path, ok := columns[h]
+		if !ok {
+			continue
+		}
+		mapped[h] = true
+		col, err := plan.add(i, h, path)
+		if err != nil {
+			return nil, invalid("%s", err)
+		}
+		if col.field.ReadOnly || col.field.Name == "ID" {
+			return nil, invalid("%s is read-only", path)
+		}
+		if path == mapping.Key {
+			plan.keyCol = col
+		}
+	}
+
+	for h := range mapping.Columns {
+		if !mapped[h] {
+			return nil, invalid("column %q is not in the file", h)
+		}
+	}
+	if len(plan.columns) == 0 {
+		return nil, invalid("no column of the file is mapped")
+	}
+	if mapping.Key != "" && (plan.keyCol == nil || strings.Contains(mapping.Key, ".")) {
+		return nil, invalid("key %s is not a mapped field of the item", mapping.Key)
+	}
+	return plan, nil
+}
+
+// key is the grouping value of a row, empty without Mapping.Key
+func (p *columnPlan) key(cells []string) string {
+	if p.keyCol == nil {
+		return ""
+	}
+	return cell(cells, p.keyCol.index)
+}
+
+// header names the column of a field reported by a validation error
+func (p *columnPlan) header(field string) string {
+	for _, col := range p.columns {
+		if col.path == field || strings.HasSuffix(col.path, "."+field) {
+			return col.header
+		}
+	}
+	return ""
+}
+
+func cell(cells []string, i int) string {
+	if i >= len(cells) {
+		return ""
+	}
+	return strings.TrimSpace(cells[i])
+}
+
+// importItem is an item being built from its rows
+type importItem struct {
+	row   int // first line
+	key   string
+	value reflect.Value // addressable T
+	rows  int
+	err   *RowError
+
+	// current is the element of each relation the next rows add to, and
+	// values the cells it was filled from
+	current map[*columnNode]reflect.Value
+	values  map[*columnNode]string
+}
+
+func newImportItem[T any](row int, key string) *importItem {
+	return &importItem{
+		row:     row,
+		key:     key,
+		value:   reflect.New(reflect.TypeFor[T]()).Elem(),
+		current: map[*columnNode]reflect.Value{},
+		values:  map[*columnNode]string{},
+	}
+}
+
+// fill adds a row to an item. The fields of the item are taken from its
+// first row. A relation gets a new element when the row has values for it
+// that differ from its current element, so rows repeating a merchant add
+// their products to the same one.
+func (p *columnPlan) fill(item *importItem, cells []string, line int) *RowError {
+	item.rows++
+	for _, node := range p.nodes {
+		var elem reflect.Value
+		if node == p.root {
+			if item.rows > 1 {
+				continue
+			}
+			elem = item.value
+		} else {
+			values := make([]string, len(node.columns))
+			for i, col := range node.columns {
+				values[i] = cell(cells, col.index)
+			}
+			sig := strings.Join(values, "\x1f")
+			cur, ok := item.current[node]
+			switch {
+			case !p.hasValues(node, cells):
+				continue
+			case blank(values) && ok:
+				continue
+			case ok && sig == item.values[node]:
+				continue
+			}
+			// A new element of the relation, its own relations start over
+			parent := item.value
+			if node.parent != p.root {
+				parent = item.current[node.parent]
+			}
+			list := parent.FieldByName(node.name)
+			list.Set(reflect.Append(list, reflect.New(list.Type().Elem()).Elem()))
+			cur = list.Index(list.Len() - 1)
+			item.current[node], item.values[node] = cur, sig
+			p.reset(item, node)
+			elem = cur
+		}
+
+		for _, col := range node.columns {
+			raw := cell(cells, col.index)
+			if raw == "" {
+				continue
+			}
+			if err := setCell(elem.FieldByName(col.field.Name), col.field.Type, raw); err != nil {
+				return &RowError{Row: line, Column: col.header, Field: col.path, Status: fiber.StatusUnprocessableEntity, Message: err.Error()}
+			}
+		}
+	}
+	return nil
+}
+
+// hasValues reports whether the row has a value for node or its relations
+func (p *columnPlan) hasValues(node *columnNode, cells []string) bool {
+	for _, col := range node.columns {
+		if cell(cells, col.index) != "" {
+			return true
+		}
+	}
+	for _, child := range node.children {
+		if p.hasValues(child, cells) {
+			return true
+		}
+	}
+	return false
+}
+
+// reset forgets the current elements below node once it got a new one
+func (p *columnPlan) reset(item *importItem, node *columnNode) {
+	for _, child := range node.children {
+		delete(item.current, child)
+		delete(item.values, child)
+		p.reset(item, child)
+	}
+}
+
+func setCell(v reflect.Value, t reflect.Type, raw string) error {
+	var value any
+	var err error
+	if t == reflect.TypeFor[time.Time]() {
+		value, err = spreadsheet.ParseTime(raw)
+	} else {
+		value, err = parseValue(t, raw)
+	}
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(value).Convert(t))
+	return nil
+}
+
+// importFile serves POST /import: a multipart form with the spreadsheet in
+// file and an optional JSON Mapping in mapping. ?dry_run=true rolls back,
+// ?batch_size= sets ImportOptions.BatchSize and ?report=csv answers the
+// errors as a CSV download instead of the JSON report. The status is 200
+// when every item was imported and 207 otherwise.
+func (h *handlers[T]) importFile(c fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		return badRequest(c, "Invalid request body", errors.New("needs the spreadsheet in a file field"))
+	}
+	format, err := spreadsheet.FormatOf(file.Filename)
+	if name := c.FormValue("format"); name != "" {
+		format, err = spreadsheet.ParseFormat(name)
+	}
+	if err != nil {
+		return badRequest(c, "Invalid request body", err)
+	}
+	var mapping Mapping
+	if raw := c.FormValue("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			return badRequest(c, "Invalid mapping", err)
+		}
+	}
+
+	var opts ImportOptions
+	if opts.DryRun, err = strconv.ParseBool(c.Query("dry_run", "false")); err != nil {
+		return badRequest(c, "Invalid query", errors.New("dry_run must be true or false"))
+	}
+	if opts.BatchSize, err = strconv.Atoi(c.Query("batch_size", "0")); err != nil || opts.BatchSize < 0 {
+		return badRequest(c, "Invalid query", errors.New("batch_size must be a positive integer"))
+	}
+	csvReport := false
+	switch c.Query("report", "json") {
+	case "json":
+	case "csv":
+		csvReport = true
+	default:
+		return badRequest(c, "Invalid query", errors.New("report must be json or csv"))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return badRequest(c, "Invalid request body", err)
+	}
+	defer src.Close()
+	rows, err := spreadsheet.NewReader(src, format)
+	if err != nil {
+		return badRequest(c, "Invalid file", err)
+	}
+	defer rows.Close()
+
+	ctx, cancel := QueryContext(c)
+	defer cancel()
+
+	report, err := h.Import(ctx, rows, mapping, opts)
+	if err != nil {
+		return ErrorResponse(c, ctx, h.log, err, "Could not import file")
+	}
+
+	status, retCode, message := fiber.StatusOK, response.SuccessOK, "Import done"
+	if report.Failed > 0 {
+		status, retCode, message = fiber.StatusMultiStatus, response.MultiStatus, "Multi-Status"
+	}
+	if csvReport {
+		c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="import-errors.csv"`)
+		c.Status(status)
+		return report.WriteCSV(c.Response().BodyWriter())
+	}
+	return response.Send(c, status, response.ErrorModel{
+		RetCode: string(retCode),
+		Message: message,
+		Data:    report,
+	})
+}
@@ -0,0 +1,122 @@
+package script_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"sample/custom"
+	merchantmodel "sample/merchant/model"
+	merchantrepository "sample/merchant/repository"
+	"sample/repository"
+	"sample/script"
+	"sample/spreadsheet"
+)
+
+func importMerchants(t *testing.T, file string, mapping script.Mapping) (*script.ImportReport, repository.Repository[merchantmodel.Merchant], error) {
+	t.Helper()
+	merchants := merchantrepository.NewMemoryMerchants(merchantrepository.NewMemoryProducts())
+	importer := script.NewImporter(slog.New(slog.NewTextHandler(io.Discard, nil)), script.Resource[merchantmodel.Merchant]{
+		Service: merchants,
+	})
+	rows, err := spreadsheet.NewReader(strings.NewReader(file), spreadsheet.CSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := importer.Import(context.Background(), rows, mapping, script.ImportOptions{BatchSize: 2})
+	return report, merchants, err
+}
+
+func TestImportGroupsRowsByKey(t *testing.T) {
+	file := `customer_id,name,product.name,product.quantity,product.date_of_delivery
+1,Sari,Rice,5,2024-05-01
+1,Sari,Eggs,12,2024-05-01
+2,Bakery,Bread,3,2024-05-02
+,,,,
+1,Sari,Milk,1,2024-05-03
+3,Karinderya,Rice,-2,2024-05-03
+4,Store,Soap,many,2024-05-03
+`
+	report, merchants, err := importMerchants(t, file, script.Mapping{Key: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The blank row is skipped, and Sari after Bakery is another merchant
+	if report.Rows != 6 || report.Items != 5 || report.Imported != 3 || report.Failed != 2 {
+		t.Errorf("report = %+v, want 6 rows, 5 items, 3 imported, 2 failed", report)
+	}
+	want := []script.RowError{
+		{Row: 7, Column: "product.quantity", Field: "quantity", Status: 422, Message: "cannot be negative"},
+		{Row: 8, Column: "product.quantity", Field: "product.quantity", Status: 422},
+	}
+	if len(report.Errors) != len(want) {
+		t.Fatalf("errors = %+v, want %d", report.Errors, len(want))
+	}
+	for i, e := range report.Errors {
+		w := want[i]
+		if e.Row != w.Row || e.Column != w.Column || e.Field != w.Field || e.Status != w.Status || w.Message != "" && e.Message != w.Message {
+			t.Errorf("error %d = %+v, want %+v", i, e, w)
+		}
+	}
+
+	listed, err := merchants.List(context.Background(), repository.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	products := map[string][]string{}
+	for _, m := range listed {
+		var names []string
+		for _, p := range m.Product {
+			names = append(names, p.Name)
+		}
+		products[m.Name] = append(products[m.Name], strings.Join(names, "+"))
+	}
+	if got := strings.Join(products["Sari"], ", "); got != "Rice+Eggs, Milk" {
+		t.Errorf("products of Sari = %s, want Rice+Eggs, Milk", got)
+	}
+	if got := strings.Join(products["Bakery"], ", "); got != "Bread" {
+		t.Errorf("products of Bakery = %s, want Bread", got)
+	}
+}
+
+func TestImportWithoutKeyMakesAnItemPerRow(t *testing.T) {
+	file := `Owner,Merchant,Product
+1,Sari,Rice
+1,Sari,Eggs
+`
+	mapping := script.Mapping{Columns: map[string]string{
+		"Owner":    "customer_id",
+		"Merchant": "name",
+		"Product":  "product.name",
+	}}
+	report, _, err := importMerchants(t, file, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Items != 2 {
+		t.Errorf("items = %d, want 2", report.Items)
+	}
+}
+
+func TestImportRejectsBadMappings(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping script.Mapping
+	}{
+		{"unknown column", script.Mapping{Columns: map[string]string{"Shop": "name"}}},
+		{"unknown field", script.Mapping{Columns: map[string]string{"name": "title"}}},
+		{"read-only id", script.Mapping{Columns: map[string]string{"name": "id"}}},
+		{"nested key", script.Mapping{Key: "product.name"}},
+	}
+	for _, tt := range tests {
+		_, _, err := importMerchants(t, "name,product.name\nSari,Rice\n", tt.mapping)
+		var validationErr *custom.ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != "mapping" {
+			t.Errorf("%s: err = %v, want a validation error of the mapping", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package script
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sample/audit"
+	"sample/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// DefaultMaxPageSize caps page_size when Resource.MaxPageSize is not set
+const DefaultMaxPageSize = 100
+
+// field is a struct field of a resource, addressed by its JSON name in the API
+type field struct {
+	Name     string
+	Type     reflect.Type
+	Relation bool // has-many or belongs-to, not a column
+	ReadOnly bool // set by the server, see audit.Stamps
+}
+
+// fieldsOf indexes the top-level fields of T by JSON name, including the
+// fields of embedded structs as encoding/json does
+func fieldsOf[T any]() map[string]field {
+	return typeFields(reflect.TypeFor[T]())
+}
+
+func typeFields(t reflect.Type) map[string]field {
+	fields := map[string]field{}
+	addFields(fields, t, false)
+	return fields
+}
+
+func addFields(fields map[string]field, t reflect.Type, readOnly bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(fields, f.Type, readOnly || f.Type == reflect.TypeFor[audit.Stamps]())
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = field{Name: f.Name, Type: f.Type, Relation: isRelation(f.Type), ReadOnly: readOnly}
+	}
+}
+
+// columnsOf lists the JSON names of the columns of t, not its relations, in
+// struct order
+func columnsOf(t reflect.Type) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+		case f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct:
+			names = append(names, columnsOf(f.Type)...)
+		case isRelation(f.Type):
+		case name == "":
+			names = append(names, f.Name)
+		default:
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func isRelation(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != reflect.TypeFor[time.Time]()
+}
+
+// parseValue converts a query string value to the Go type of a field
+func parseValue(t reflect.Type, raw string) (any, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeFor[time.Time]() {
+		for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+			if ts, err := time.Parse(layout, raw); err == nil {
+				return ts, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not an RFC 3339 time or a date", raw)
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a positive integer", raw)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, t.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	default:
+		return nil, fmt.Errorf("cannot filter on %s", t)
+	}
+	return v.Interface(), nil
+}
+
+var filterKey = regexp.MustCompile(`^filter\[([^\]]+)\](?:\[([^\]]+)\])?$`)
+
+// listOptions reads the include, filter[field][op], sort, page and page_size
+// query parameters of a list request
+func (h *handlers[T]) listOptions(c fiber.Ctx) (repository.ListOptions, error) {
+	preloads, err := h.preloads(c)
+	if err != nil {
+		return repository.ListOptions{}, err
+	}
+	opts := repository.ListOptions{Preloads: preloads}
+
+	for key, raw := range c.Queries() {
+		m := filterKey.FindStringSubmatch(key)
+		if m == nil {
+			continue
+		}
+		name, op := m[1], repository.Op(m[2])
+		if op == "" {
+			op = repository.OpEq
+		}
+		f, ok := h.filters[name]
+		if !ok {
+			return opts, fmt.Errorf("cannot filter on %s", name)
+		}
+
+		var value any
+		switch op {
+		case repository.OpEq, repository.OpNe, repository.OpGt, repository.OpGte, repository.OpLt, repository.OpLte:
+			value, err = parseValue(f.Type, raw)
+		case repository.OpLike:
+			if f.Type.Kind() != reflect.String {
+				return opts, fmt.Errorf("like needs a text field, %s is not", name)
+			}
+			value = raw
+		case repository.OpIn:
+			var values []any
+			for _, part := range strings.Split(raw, ",") {
+				v, perr := parseValue(f.Type, strings.TrimSpace(part))
+				if perr != nil {
+					err = perr
+					break
+				}
+				values = append(values, v)
+			}
+			value = values
+		default:
+			return opts, fmt.Errorf("unknown filter operator %s", op)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("filter[%s]: %w", name, err)
+		}
+		opts.Filters = append(opts.Filters, repository.Filter{Field: f.Name, Op: op, Value: value})
+	}
+
+	// sort=-date_of_birth,id sorts descending on a leading minus
+	if sort := c.Query("sort"); sort != "" {
+		for _, name := range strings.Split(sort, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			f, ok := h.sorts[name]
+			if !ok {
+				return opts, fmt.Errorf("cannot sort on %s", name)
+			}
+			opts.Sort = append(opts.Sort, repository.Sort{Field: f.Name, Desc: desc})
+		}
+	}
+
+	// Without page or page_size the whole list is returned, like before paging existed
+	if c.Query("page") != "" || c.Query("page_size") != "" {
+		maxSize := h.res.MaxPageSize
+		if maxSize <= 0 {
+			maxSize = DefaultMaxPageSize
+		}
+		page, err := strconv.Atoi(c.Query("page", "1"))
+		if err != nil || page < 1 {
+			return opts, fmt.Errorf("page must be a positive integer")
+		}
+		size, err := strconv.Atoi(c.Query("page_size", strconv.Itoa(maxSize)))
+		if err != nil || size < 1 || size > maxSize {
+			return opts, fmt.Errorf("page_size must be between 1 and %d", maxSize)
+		}
+		opts.Limit, opts.Offset = size, (page-1)*size
+	}
+	return opts, nil
+}
+
+// preloads maps the include query parameter, e.g. include=address,contact, to
+// the GORM preloads of the resource. Without it DefaultIncludes are loaded.
+func (h *handlers[T]) preloads(c fiber.Ctx) ([]string, error) {
+	names := h.res.DefaultIncludes
+	if include, ok := c.Queries()["include"]; ok {
+		names = nil
+		for _, name := range strings.Split(include, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	preloads := make([]string, 0, len(names))
+	for _, name := range names {
+		preload, ok := h.res.Includes[name]
+		if !ok {
+			return nil, fmt.Errorf("cannot include %s", name)
+		}
+		preloads = append(preloads, preload)
+	}
+	return preloads, nil
+}
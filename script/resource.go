@@ -0,0 +1,670 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sample/audit"
+	"sample/codec"
+	"sample/custom"
+	"sample/repository"
+	"sample/response"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Action is one of the endpoints Register mounts
+type Action string
+
+const (
+	ActionList   Action = "list"   // GET    /
+	ActionGet    Action = "get"    // GET    /:id
+	ActionCreate Action = "create" // POST   /
+	ActionUpdate Action = "update" // PUT    /:id, non-zero fields
+	ActionPatch  Action = "patch"  // PATCH  /:id, the fields present in the body
+	ActionDelete Action = "delete" // DELETE /:id
+	// POST, PUT, PATCH and DELETE /bulk ask the permission of the action of
+	// their items but run the middleware of ActionBulk
+	ActionBulk Action = "bulk"
+	// POST /import, when the resource is Importable, asks the permission of
+	// ActionCreate but runs the middleware of ActionImport
+	ActionImport Action = "import"
+	// GET /export asks the permission of ActionList but runs the middleware
+	// of ActionExport
+	ActionExport Action = "export"
+)
+
+// Hooks are registered around the service call of an endpoint. They run in
+// the transaction of Resource.Tx with the service call, so an error, e.g. a
+// custom.ConflictError, rolls the write back and is answered like a service
+// error, see ErrorResponse. Models can implement the repository hook
+// interfaces instead, those also run for nested items.
+type Hooks[T any] struct {
+	BeforeCreate func(ctx context.Context, item *T) error
+	AfterCreate  func(ctx context.Context, item *T) error
+	// fields is nil for PUT, see repository.Repository Update
+	BeforeUpdate func(ctx context.Context, id uint, changes *T, fields []string) error
+	AfterUpdate  func(ctx context.Context, item *T) error
+	BeforeDelete func(ctx context.Context, id uint) error
+	AfterDelete  func(ctx context.Context, id uint) error
+}
+
+// Resource defines the endpoints of one model. Fields are named by their JSON
+// name, as clients see them.
+type Resource[T any] struct {
+	Service Service[T]
+
+	// Includes maps the names accepted by ?include= to GORM preloads,
+	// DefaultIncludes are loaded when the request has no include parameter
+	Includes        map[string]string
+	DefaultIncludes []string
+
+	// Filters and Sort are the fields accepted by ?filter[field][op]= and ?sort=
+	Filters     []string
+	Sort        []string
+	MaxPageSize int // DefaultMaxPageSize when zero
+	MaxBulkSize int // items of a bulk request, DefaultMaxBulkSize when zero
+
+	// Export are the columns of GET /export, field paths like those of a
+	// Mapping. Without them the columns of the model are exported.
+	Export []string
+
+	// Importable mounts POST /import, which creates the items of a
+	// spreadsheet, see Importer. Nested resources do not get it.
+	Importable bool
+
+	// Children are mounted under /:id, see Nested
+	Children []Child
+	Hooks    Hooks[T]
+
+	// Tx runs the writes with their hooks. Children without their own
+	// inherit it, nil runs them without a transaction.
+	Tx repository.Transactor
+
+	// Audit records every write in its transaction under Name, with the
+	// diff of the columns and first-level includes. Children inherit it.
+	Name  string
+	Audit *audit.Log
+
+	// Moved returns the ID an item that is not found has moved to, e.g. a
+	// merged customer, or custom.ErrNotFound. GET /:id of a moved item is
+	// answered 308 Permanent Redirect; the writes are not, they would reach
+	// an item the client did not name.
+	Moved func(ctx context.Context, id uint) (uint, error)
+
+	// Permission is asked before every action, false answers 403. Nil allows everything.
+	Permission func(c fiber.Ctx, action Action) bool
+
+	// Middleware runs before the handler of an action, e.g. rate limits.
+	// Children without their own inherit it.
+	Middleware map[Action][]fiber.Handler
+}
+
+// Register mounts list, get, create, update, patch and delete of res on
+// router, their bulk versions under /bulk, /export, /import when res is
+// Importable, and the children of res under /:id
+func Register[T any](router fiber.Router, log *slog.Logger, res Resource[T]) {
+	newHandlers(res, log, "id", nil).mount(router)
+}
+
+// Child is a resource mounted under its parent, built with Nested
+type Child interface {
+	mount(parent fiber.Router, log *slog.Logger, parentParam string, inherited inherited)
+}
+
+// inherited are the settings of a parent its children default to
+type inherited struct {
+	middleware map[Action][]fiber.Handler
+	tx         repository.Transactor
+	audit      *audit.Log
+}
+
+type nested[C any] struct {
+	path       string
+	foreignKey string
+	res        Resource[C]
+}
+
+// Nested mounts res under /:id/path of its parent. Its rows are the ones
+// whose foreignKey field holds the parent ID, e.g.
+// Nested("product", "merchant_id", products) serves /api/merchant/1/product.
+func Nested[C any](path, foreignKey string, res Resource[C]) Child {
+	return &nested[C]{path: path, foreignKey: foreignKey, res: res}
+}
+
+func (n *nested[C]) mount(parent fiber.Router, log *slog.Logger, parentParam string, inherited inherited) {
+	fk, ok := fieldsOf[C]()[n.foreignKey]
+	if !ok {
+		panic(fmt.Sprintf("script.Nested: %s has no field %s", reflect.TypeFor[C](), n.foreignKey))
+	}
+	res := n.res
+	if res.Middleware == nil {
+		res.Middleware = inherited.middleware
+	}
+	if res.Tx == nil {
+		res.Tx = inherited.tx
+	}
+	if res.Audit == nil {
+		res.Audit = inherited.audit
+	}
+
+	idParam := strings.NewReplacer("/", "_", "-", "_").Replace(n.path) + "_id"
+	group := parent.Group("/:" + parentParam + "/" + n.path)
+	newHandlers(res, log, idParam, &scope{param: parentParam, field: fk}).mount(group)
+}
+
+// scope restricts nested handlers to the rows of the parent in the URL
+type scope struct {
+	param string
+	field field
+}
+
+type handlers[T any] struct {
+	res     Resource[T]
+	log     *slog.Logger
+	idParam string
+	scope   *scope
+	fields  map[string]field
+	filters map[string]field
+	sorts   map[string]field
+
+	auditPreloads []string
+}
+
+func newHandlers[T any](res Resource[T], log *slog.Logger, idParam string, sc *scope) *handlers[T] {
+	h := &handlers[T]{
+		res:     res,
+		log:     log,
+		idParam: idParam,
+		scope:   sc,
+		fields:  fieldsOf[T](),
+		filters: map[string]field{},
+		sorts:   map[string]field{},
+	}
+	// Typos in a definition are programming errors, fail at start-up
+	for _, name := range res.Filters {
+		h.filters[name] = h.column(name)
+	}
+	for _, name := range res.Sort {
+		h.sorts[name] = h.column(name)
+	}
+	for _, name := range res.DefaultIncludes {
+		if _, ok := res.Includes[name]; !ok {
+			panic(fmt.Sprintf("script.Register: %s default include %s is not in Includes", reflect.TypeFor[T](), name))
+		}
+	}
+	if res.Audit != nil && res.Name == "" {
+		panic(fmt.Sprintf("script.Register: audited %s needs a Name", reflect.TypeFor[T]()))
+	}
+	for name, preload := range res.Includes {
+		if !strings.Contains(name, ".") {
+			h.auditPreloads = append(h.auditPreloads, preload)
+		}
+	}
+	sort.Strings(h.auditPreloads)
+	return h
+}
+
+func (h *handlers[T]) column(name string) field {
+	f, ok := h.fields[name]
+	if !ok || f.Relation {
+		panic(fmt.Sprintf("script.Register: %s has no column %s", reflect.TypeFor[T](), name))
+	}
+	return f
+}
+
+func (h *handlers[T]) mount(router fiber.Router) {
+	id := "/:" + h.idParam
+	// Before /:id, which would take bulk for an ID
+	router.Post("/bulk", h.bulkCreate, h.bulkMiddleware(ActionCreate)...)
+	router.Put("/bulk", h.bulkUpdate, h.bulkMiddleware(ActionUpdate)...)
+	router.Patch("/bulk", h.bulkPatch, h.bulkMiddleware(ActionPatch)...)
+	router.Delete("/bulk", h.bulkDelete, h.bulkMiddleware(ActionDelete)...)
+	if h.res.Importable && h.scope == nil {
+		router.Post("/import", h.importFile, h.permitted(ActionCreate, h.res.Middleware[ActionImport])...)
+	}
+
+	router.Get("/export", h.export, h.permitted(ActionList, h.res.Middleware[ActionExport])...)
+	router.Get("/", h.list, h.middleware(ActionList)...)
+	router.Post("/", h.create, h.middleware(ActionCreate)...)
+	router.Get(id, h.get, h.middleware(ActionGet)...)
+	router.Put(id, h.update, h.middleware(ActionUpdate)...)
+	router.Patch(id, h.patch, h.middleware(ActionPatch)...)
+	router.Delete(id, h.delete, h.middleware(ActionDelete)...)
+
+	for _, child := range h.res.Children {
+		child.mount(router, h.log, h.idParam, inherited{middleware: h.res.Middleware, tx: h.res.Tx, audit: h.res.Audit})
+	}
+}
+
+// middleware prepends the permission check to the middleware of action
+func (h *handlers[T]) middleware(action Action) []fiber.Handler {
+	return h.permitted(action, h.res.Middleware[action])
+}
+
+// bulkMiddleware asks the permission of action, which each item performs,
+// and runs the middleware of ActionBulk
+func (h *handlers[T]) bulkMiddleware(action Action) []fiber.Handler {
+	return h.permitted(action, h.res.Middleware[ActionBulk])
+}
+
+func (h *handlers[T]) permitted(action Action, middleware []fiber.Handler) []fiber.Handler {
+	handlers := []fiber.Handler{func(c fiber.Ctx) error {
+		if h.res.Permission != nil && !h.res.Permission(c, action) {
+			return response.Send(c, fiber.StatusForbidden, response.ErrorModel{
+				RetCode: string(response.Forbidden),
+				Message: "Not allowed",
+				Data:    string(action),
+			})
+		}
+		return c.Next()
+	}}
+	return append(handlers, middleware...)
+}
+
+func (h *handlers[T]) list(c fiber.Ctx) error {
+	opts, err := h.listOptions(c)
+	if err != nil {
+		return badRequest(c, "Invalid query", err)
+	}
+	if h.scope != nil {
+		parent, err := h.parentID(c)
+		if err != nil {
+			return badRequest(c, "invalid id", err)
+		}
+		opts.Filters = append(opts.Filters, repository.Filter{Field: h.scope.field.Name, Op: repository.OpEq, Value: parent})
+	}
+
+	ctx, cancel := QueryContext(c)
+	defer cancel()
+
+	resources, err := h.res.Service.List(ctx, opts)
+	if err != nil {
+		return ErrorResponse(c, ctx, h.log, err, "Could not retrieve resource")
+	}
+
+	if len(resources) == 0 {
+		return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+			RetCode: string(response.NotFound),
+			Message: "No resource found",
+			Data:    resources,
+		})
+	}
+
+	return response.Send(c, fiber.StatusOK, response.ErrorModel{
+		RetCode: string(response.SuccessOK),
+		Message: "success",
+		Data:    resources,
+	})
+}
+
+func (h *handlers[T]) get(c fiber.Ctx) error {
+	resourceID, err := custom.ParseID(c.Params(h.idParam))
+	if err != nil {
+		return badRequest(c, "invalid id", err)
+	}
+	preloads, err := h.preloads(c)
+	if err != nil {
+		return badRequest(c, "Invalid query", err)
+	}
+
+	ctx, cancel := QueryContext(c)
+	defer cancel()
+
+	resource, err := h.res.Service.Get(ctx, uint(resourceID), preloads)
+	if errors.Is(err, custom.ErrNotFound) && h.res.Moved != nil && h.scope == nil {
+		var to uint
+		if to, err = h.res.Moved(ctx, uint(resourceID)); err == nil {
+			return h.redirect(c, to)
+		}
+	}
+	if err == nil {
+		err = h.inScope(c, resource)
+	}
+	if err != nil {
+		return ErrorResponse(c, ctx, h.log, err, "Could not find resource")
+	}
+
+	return response.Send(c, fiber.StatusOK, response.ErrorModel{
+		RetCode: string(response.SuccessOK),
+		Message: "Success",
+		Data:    resource,
+	})
+}
+
+// redirect answers 308 with the URL of the request for the item to
+func (h *handlers[T]) redirect(c fiber.Ctx, to uint) error {
+	path := c.Path()
+	location := path[:strings.LastIndex(path, "/")+1] + strconv.FormatUint(uint64(to), 10)
+	if query := c.Request().URI().QueryString(); len(query) > 0 {
+		location += "?" + string(query)
+	}
+	c.Location(location)
+	return response.Send(c, fiber.StatusPermanentRedirect, response.ErrorModel{
+		RetCode: string(response.PermanentRedirect),
+		Message: "Moved",
+		Data:    to,
+	})
+}
+
+func (h *handlers[T]) create(c fiber.Ctx) error {
+	// Bind the request body to the main input model
+	input := new(T)
+	if _, err := BindBody(c, input); err != nil {
+		return BodyErrorResponse(c, err)
+	}
+	if err := h.setParent(c, input); err != nil {
+		return badRequest(c, "invalid id", err)
+	}
+
+	ctx, cancel := QueryContext(c)
+	defer cancel()
+
+	if err := h.createItem(ctx, input); err != nil {
+		return ErrorResponse(c, ctx, h.log, err, "Could not create resource")
+	}
+
+	return response.Send(c, fiber.StatusOK, response.ErrorModel{
+		RetCode: string(response.SuccessOK),
+		Message: "Success Insert",
+		Data:    reflect.ValueOf(input).Elem().FieldByName("ID").Interface(),
+	})
+}
+
+// createItem creates input with its hooks and audit entry in a transaction,
+// a savepoint when ctx already carries one
+func (h *handlers[T]) createItem(ctx context.Context, input *T) error {
+	// Create the main resource, GORM saves the nested related models with it
+	return h.transaction(ctx, func(ctx context.Context) error {
+		if err := runHook(ctx, h.res.Hooks.BeforeCreate, input); err != nil {
+			return err
+		}
+		if err := h.res.Service.Create(ctx, input); err != nil {
+			return err
+		}
+		if err := runHook(ctx, h.res.Hooks.AfterCreate, input); err != nil {
+			return err
+		}
+		return h.record(ctx, itemID(input), audit.ActionCreate, new(T), input)
+	})
+}
+
+func (h *handlers[T]) update(c fiber.Ctx) error {
+	return h.write(c, false)
+}
+
+func (h *handlers[T]) patch(c fiber.Ctx) error {
+	return h.write(c, true)
+}
+
+// write serves PUT, which updates the non-zero fields of the body, and PATCH,
+// which updates the fields present in the body even when they are zero
+func (h *handlers[T]) write(c fiber.Ctx, patch bool) error {
+	resourceID, err := custom.ParseID(c.Params(h.idParam))
+	if err != nil {
+		return badRequest(c, "Invalid ID", err)
+	}
+	id := uint(resourceID)
+
+	// Parse request body into the input model
+	input := new(T)
+	raw, err := BindBody(c, input)
+	if err != nil {
+		h.log.WarnContext(c.UserContext(), "could not parse update body", "error", err)
+		return BodyErrorResponse(c, err)
+	}
+	var fields []string
+	if patch {
+		if fields, err = h.patchFields(raw); err != nil {
+			return ErrorResponse(c, c.UserContext(), h.log, err, "Could not update resource")
+		}
+	}
+	if err := h.setParent(c, input); err != nil {
+		return badRequest(c, "Invalid ID", err)
+	}
+
+	ctx, cancel := QueryContext(c)
+	defer cancel()
+
+	updated, err := h.updateItem(ctx, c, id, input, fields)
+	if err != nil {
+		return ErrorResponse(c, ctx, h.log, err, "Could not update resource")
+	}
+
+	return response.Send(c, fiber.StatusOK, response.ErrorModel{
+		RetCode: string(response.SuccessOK),
+		Message: "Update success",
+		Data:    updated,
+	})
+}
+
+// updateItem writes input to the item with id with its hooks and audit entry,
+// like createItem
+func (h *handlers[T]) updateItem(ctx context.Context, c fiber.Ctx, id uint, input *T, fields []string) (*T, error) {
+	var updated *T
+	err := h.transaction(ctx, func(ctx context.Context) error {
+		before, err := h.load(ctx, c, id)
+		if err != nil {
+			return err
+		}
+		if h.res.Hooks.BeforeUpdate != nil {
+			if err := h.res.Hooks.BeforeUpdate(ctx, id, input, fields); err != nil {
+				return err
+			}
+		}
+		if updated, err = h.res.Service.Update(ctx, id, input, fields); err != nil {
+			return err
+		}
+		if err := runHook(ctx, h.res.Hooks.AfterUpdate, updated); err != nil {
+			return err
+		}
+		if h.res.Audit == nil {
+			return nil
+		}
+		after, err := h.res.Service.Get(ctx, id, h.auditPreloads)
+		if err != nil {
+			return err
+		}
+		return h.record(ctx, id, audit.ActionUpdate, before, after)
+	})
+	return updated, err
+}
+
+// patchFields returns the struct fields named by the keys of a JSON body
+func (h *handlers[T]) patchFields(raw []byte) ([]string, error) {
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return nil, custom.NewValidationError("body", "must be a JSON object")
+	}
+
+	fields := []string{}
+	for key := range body {
+		f, ok := h.fields[key]
+		switch {
+		case !ok:
+			return nil, custom.NewValidationError(key, "is not a field")
+		case f.Relation:
+			return nil, custom.NewValidationError(key, "cannot be patched, use its own endpoint")
+		case f.ReadOnly:
+			return nil, custom.NewValidationError(key, "is read-only")
+		case f.Name == "ID", h.scope != nil && f.Name == h.scope.field.Name:
+			continue
+		}
+		fields = append(fields, f.Name)
+	}
+	if len(fields) == 0 {
+		return nil, custom.NewValidationError("body", "has no field to update")
+	}
+	return fields, nil
+}
+
+func (h *handlers[T]) delete(c fiber.Ctx) error {
+	resourceID, err := custom.ParseID(c.Params(h.idParam))
+	if err != nil {
+		return badRequest(c, "Invalid ID", err)
+	}
+	id := uint(resourceID)
+
+	ctx, cancel := QueryContext(c)
+	defer cancel()
+
+	if err := h.deleteItem(ctx, c, id); err != nil {
+		return ErrorResponse(c, ctx, h.log, err, "Could not delete resource")
+	}
+
+	return response.Send(c, fiber.StatusOK, response.ErrorModel{
+		RetCode: string(response.SuccessOK),
+		Message: "Deleted Successfully",
+		Data:    resourceID,
+	})
+}
+
+// deleteItem deletes the item with id with its hooks and audit entry, like createItem
+func (h *handlers[T]) deleteItem(ctx context.Context, c fiber.Ctx, id uint) error {
+	// Delete the main resource, GORM cascades to the related models
+	return h.transaction(ctx, func(ctx context.Context) error {
+		before, err := h.load(ctx, c, id)
+		if err != nil {
+			return err
+		}
+		if h.res.Hooks.BeforeDelete != nil {
+			if err := h.res.Hooks.BeforeDelete(ctx, id); err != nil {
+				return err
+			}
+		}
+		if err := h.res.Service.Delete(ctx, id); err != nil {
+			return err
+		}
+		if h.res.Hooks.AfterDelete != nil {
+			if err := h.res.Hooks.AfterDelete(ctx, id); err != nil {
+				return err
+			}
+		}
+		return h.record(ctx, id, audit.ActionDelete, before, nil)
+	})
+}
+
+// transaction runs fn in a transaction of Resource.Tx, if any
+func (h *handlers[T]) transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if h.res.Tx == nil {
+		return fn(ctx)
+	}
+	return h.res.Tx.Transaction(ctx, fn)
+}
+
+// runHook calls an optional item hook
+func runHook[T any](ctx context.Context, hook func(context.Context, *T) error, item *T) error {
+	if hook == nil {
+		return nil
+	}
+	return hook(ctx, item)
+}
+
+// parentID is the parent ID of a nested request, typed like the foreign key
+func (h *handlers[T]) parentID(c fiber.Ctx) (any, error) {
+	id, err := custom.ParseID(c.Params(h.scope.param))
+	if err != nil {
+		return nil, err
+	}
+	return parseValue(h.scope.field.Type, fmt.Sprint(id))
+}
+
+// setParent points the foreign key of a nested item at the parent in the URL
+func (h *handlers[T]) setParent(c fiber.Ctx, item *T) error {
+	if h.scope == nil {
+		return nil
+	}
+	parent, err := h.parentID(c)
+	if err != nil {
+		return err
+	}
+	reflect.ValueOf(item).Elem().FieldByName(h.scope.field.Name).Set(reflect.ValueOf(parent))
+	return nil
+}
+
+// inScope answers ErrNotFound for a nested item of another parent
+func (h *handlers[T]) inScope(c fiber.Ctx, item *T) error {
+	if h.scope == nil {
+		return nil
+	}
+	parent, err := h.parentID(c)
+	if err != nil {
+		return custom.ErrNotFound
+	}
+	if reflect.ValueOf(item).Elem().FieldByName(h.scope.field.Name).Interface() != parent {
+		return custom.ErrNotFound
+	}
+	return nil
+}
+
+// load returns the item with id before a write, for the audit diff and to
+// check the parent of a nested item. It is nil when neither needs it.
+func (h *handlers[T]) load(ctx context.Context, c fiber.Ctx, id uint) (*T, error) {
+	if h.scope == nil && h.res.Audit == nil {
+		return nil, nil
+	}
+	item, err := h.res.Service.Get(ctx, id, h.auditPreloads)
+	if err != nil {
+		return nil, err
+	}
+	return item, h.inScope(c, item)
+}
+
+// record writes the audit entry of a change, if the resource is audited
+func (h *handlers[T]) record(ctx context.Context, id uint, action string, before, after *T) error {
+	if h.res.Audit == nil {
+		return nil
+	}
+	return h.res.Audit.Record(ctx, h.res.Name, id, action, before, after)
+}
+
+func itemID[T any](item *T) uint {
+	return uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+}
+
+// bodyJSON reads the request body as JSON whatever its Content-Type, see
+// codec.ToJSON. t guides the reading of XML, which has no types.
+func bodyJSON(c fiber.Ctx, t reflect.Type) ([]byte, error) {
+	media, err := codec.MediaType(c.Get(fiber.HeaderContentType))
+	if err != nil {
+		return nil, err
+	}
+	return codec.ToJSON(media, c.Body(), t)
+}
+
+// BindBody decodes the request body, JSON, XML or MessagePack, into the
+// pointer v and returns it as JSON. Answer its error with BodyErrorResponse.
+func BindBody(c fiber.Ctx, v any) ([]byte, error) {
+	raw, err := bodyJSON(c, reflect.TypeOf(v).Elem())
+	if err != nil {
+		return nil, err
+	}
+	return raw, json.Unmarshal(raw, v)
+}
+
+// BodyErrorResponse answers 415 for a body of an unsupported media type and
+// 400 for any other unreadable body
+func BodyErrorResponse(c fiber.Ctx, err error) error {
+	if errors.Is(err, codec.ErrUnsupportedMediaType) {
+		return response.Send(c, fiber.StatusUnsupportedMediaType, response.ErrorModel{
+			RetCode: string(response.UnsupportedMediaType),
+			Message: "Unsupported Media Type",
+			Data:    err.Error(),
+		})
+	}
+	return badRequest(c, "Invalid request body", err)
+}
+
+func badRequest(c fiber.Ctx, message string, err error) error {
+	return response.Send(c, fiber.StatusBadRequest, response.ErrorModel{
+		RetCode: string(response.BadRequest),
+		Message: message,
+		Data:    err.Error(),
+	})
+}
@@ -0,0 +1,113 @@
+package script_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	merchantmodel "sample/merchant/model"
+	"sample/repository"
+	"sample/script"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// productList serves the products of a memory repository through Register
+func productList(t *testing.T) *fiber.App {
+	t.Helper()
+	products := repository.NewMemory[merchantmodel.Product]()
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"Rice", "Bread", "Sugar", "Salt", "Rice Flour"} {
+		product := &merchantmodel.Product{MerchantID: 1 + i%2, Name: name, Quantity: (i + 1) * 10, DeliverDate: day.AddDate(0, 0, i)}
+		if err := products.Create(context.Background(), product); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	app := fiber.New()
+	script.Register(app.Group("/product"), slog.New(slog.NewTextHandler(io.Discard, nil)), script.Resource[merchantmodel.Product]{
+		Service:     products,
+		Filters:     []string{"merchant_id", "name", "quantity", "date_of_delivery"},
+		Sort:        []string{"name", "quantity"},
+		MaxPageSize: 3,
+	})
+	return app
+}
+
+func TestRegisterList(t *testing.T) {
+	app := productList(t)
+	tests := []struct {
+		query string
+		want  []string // names of the products, in order
+	}{
+		{"", []string{"Rice", "Bread", "Sugar", "Salt", "Rice Flour"}},
+		{"?filter[merchant_id]=2", []string{"Bread", "Salt"}},
+		{"?filter[quantity][gte]=30&filter[quantity][lt]=50", []string{"Sugar", "Salt"}},
+		{"?filter[name][like]=rice", []string{"Rice", "Rice Flour"}},
+		{"?filter[name][in]=Salt,Sugar", []string{"Sugar", "Salt"}},
+		{"?filter[date_of_delivery][gt]=2024-05-03", []string{"Salt", "Rice Flour"}},
+		{"?sort=name", []string{"Bread", "Rice", "Rice Flour", "Salt", "Sugar"}},
+		{"?sort=-quantity&page_size=2", []string{"Rice Flour", "Salt"}},
+		{"?sort=-quantity&page=2&page_size=2", []string{"Sugar", "Bread"}},
+		{"?page=2", []string{"Salt", "Rice Flour"}},
+	}
+	for _, tt := range tests {
+		status, names := listNames(t, app, "/product"+tt.query)
+		if status != fiber.StatusOK || len(names) != len(tt.want) {
+			t.Errorf("%s: %d %v, want %v", tt.query, status, names, tt.want)
+			continue
+		}
+		for i := range names {
+			if names[i] != tt.want[i] {
+				t.Errorf("%s: %v, want %v", tt.query, names, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRegisterListRejectsBadQueries(t *testing.T) {
+	app := productList(t)
+	for _, query := range []string{
+		"?filter[id]=1",             // not a filter
+		"?filter[quantity]=many",    // not an integer
+		"?filter[quantity][like]=1", // like needs text
+		"?filter[name][between]=a",  // no such operator
+		"?sort=merchant_id",         // not a sort field
+		"?page=0",                   // pages start at 1
+		"?page_size=4",              // above MaxPageSize
+		"?include=merchant",         // not an include
+	} {
+		if status, _ := listNames(t, app, "/product"+query); status != fiber.StatusBadRequest {
+			t.Errorf("%s: %d, want 400", query, status)
+		}
+	}
+	if status, _ := listNames(t, app, "/product?filter[merchant_id]=3"); status != fiber.StatusNotFound {
+		t.Errorf("no match: %d, want 404", status)
+	}
+}
+
+// listNames returns the status of a list request and the names of the products listed
+func listNames(t *testing.T, app *fiber.App, path string) (int, []string) {
+	t.Helper()
+	resp, err := app.Test(httptest.NewRequest("GET", path, nil), 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Data []merchantmodel.Product `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil && resp.StatusCode == fiber.StatusOK {
+		t.Fatalf("%s: %v", path, err)
+	}
+	var names []string
+	for _, p := range body.Data {
+		names = append(names, p.Name)
+	}
+	return resp.StatusCode, names
+}
@@ -0,0 +1,70 @@
+package searchcontroller
+
+import (
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"sample/response"
+	"sample/script"
+	"sample/search"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// MaxPageSize caps page_size on /api/search
+const MaxPageSize = 100
+
+// Search serves /api/search?q=juan&type=customer,merchant&page=1&page_size=20
+// with the customers, merchants and products matching q, best first
+func Search(searcher search.Searcher, logger *slog.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		var types []string
+		if t := c.Query("type"); t != "" {
+			types = strings.Split(t, ",")
+		}
+		query, err := search.ParseQuery(c.Query("q"), types)
+		if err != nil {
+			return badRequest(c, err)
+		}
+		page, err := strconv.Atoi(c.Query("page", "1"))
+		if err != nil || page < 1 {
+			return badRequest(c, errors.New("page must be a positive integer"))
+		}
+		size, err := strconv.Atoi(c.Query("page_size", "20"))
+		if err != nil || size < 1 || size > MaxPageSize {
+			return badRequest(c, errors.New("page_size must be between 1 and "+strconv.Itoa(MaxPageSize)))
+		}
+
+		ctx, cancel := script.QueryContext(c)
+		defer cancel()
+
+		results, err := searcher.Search(ctx, query, size, (page-1)*size)
+		if err != nil {
+			return script.ErrorResponse(c, ctx, logger, err, "Could not search")
+		}
+
+		if len(results.Results) == 0 {
+			return response.Send(c, fiber.StatusNotFound, response.ErrorModel{
+				RetCode: string(response.NotFound),
+				Message: "No resource found",
+				Data:    results,
+			})
+		}
+
+		return response.Send(c, fiber.StatusOK, response.ErrorModel{
+			RetCode: string(response.SuccessOK),
+			Message: "success",
+			Data:    results,
+		})
+	}
+}
+
+func badRequest(c fiber.Ctx, err error) error {
+	return response.Send(c, fiber.StatusBadRequest, response.ErrorModel{
+		RetCode: string(response.BadRequest),
+		Message: "Invalid query",
+		Data:    err.Error(),
+	})
+}
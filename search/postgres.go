@@ -0,0 +1,57 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Postgres matches the words of a query as prefixes of the words of a
+// column, through a full-text index, and finds misspellings and substrings,
+// e.g. of a phone number, through a trigram index. See migration 0004_search
+// for the indexes, whose expressions must be the ones searched here.
+type Postgres struct {
+	db *gorm.DB
+}
+
+var postgresSources = []source{
+	postgresSource(TypeCustomer, "name", "customers", "id", "full_name || ' ' || last_name"),
+	postgresSource(TypeCustomer, "email", "contacts", "customer_id", "email"),
+	postgresSource(TypeCustomer, fieldPhone, "contacts", "customer_id", "owner_phone_number"),
+	postgresSource(TypeCustomer, fieldPhone, "contacts", "customer_id", "owner_other_phone_number"),
+	postgresSource(TypeMerchant, "name", "merchants", "id", "name"),
+	postgresSource(TypeProduct, "name", "products", "id", "name"),
+}
+
+// postgresSource searches the expression text of table, for the resource of
+// type typ whose ID is in the column id
+func postgresSource(typ, field, table, id, text string) source {
+	vector := "to_tsvector('simple', " + text + ")"
+	return source{typ: typ, field: field, query: func(q Query, args *[]any) string {
+		tsquery, like := prefixQuery(q.Terms), "%"+escapeLike(q.Text)+"%"
+		*args = append(*args, tsquery, q.Text, tsquery, q.Text, like)
+		return `SELECT '` + typ + `' AS resource, ` + id + ` AS id, '` + field + `' AS field, ` + text + ` AS matched,
+		ts_rank(` + vector + `, to_tsquery('simple', ?)) + word_similarity(?, ` + text + `) AS score
+	FROM ` + table + `
+	WHERE ` + vector + ` @@ to_tsquery('simple', ?) OR ? <% (` + text + `) OR (` + text + `) ILIKE ?`
+	}}
+}
+
+func (p *Postgres) Search(ctx context.Context, q Query, limit, offset int) (Page, error) {
+	return run(ctx, p.db, q, postgresSources, limit, offset)
+}
+
+// prefixQuery is the tsquery matching words starting with every term
+func prefixQuery(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		term = strings.NewReplacer(`'`, `''`, `\`, `\\`).Replace(term)
+		quoted[i] = "'" + term + "':*"
+	}
+	return strings.Join(quoted, " & ")
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
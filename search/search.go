@@ -0,0 +1,165 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"sample/utils"
+
+	"gorm.io/gorm"
+)
+
+// Types of the results, the resources they link to
+const (
+	TypeCustomer = "customer"
+	TypeMerchant = "merchant"
+	TypeProduct  = "product"
+)
+
+// Types lists every type of result
+var Types = []string{TypeCustomer, TypeMerchant, TypeProduct}
+
+// MinTermLength is the length of the shortest word searched, shorter words
+// of a query are left out. Both engines match on trigrams.
+const MinTermLength = 3
+
+// Result is a resource that matched a query. Field is what matched, e.g. the
+// email of a contact for a customer, and Match its text.
+type Result struct {
+	Type  string  `gorm:"column:resource" json:"type"`
+	ID    uint    `gorm:"column:id" json:"id"`
+	Field string  `gorm:"column:field" json:"field"`
+	Match string  `gorm:"column:matched" json:"match"`
+	Rank  float64 `gorm:"column:score" json:"rank"`
+}
+
+// Page is a page of results, best first, and how many there are in all.
+// Total is zero past the last page.
+type Page struct {
+	Total   int64    `json:"total"`
+	Results []Result `json:"results"`
+}
+
+// Query is a parsed search
+type Query struct {
+	Text  string   // the whole query, trimmed
+	Terms []string // its words of at least MinTermLength
+	Types []string // the types of results, all when empty
+	Phone string   // the query in E.164 when it is a phone number, as they are stored
+}
+
+// ParseQuery splits q into its words and checks the types
+func ParseQuery(q string, types []string) (Query, error) {
+	query := Query{Text: strings.TrimSpace(q)}
+	if phone, ok := utils.NormalizePhone(query.Text); ok {
+		query.Phone = phone
+	}
+	for _, term := range strings.Fields(query.Text) {
+		if utf8.RuneCountInString(term) >= MinTermLength {
+			query.Terms = append(query.Terms, term)
+		}
+	}
+	if len(query.Terms) == 0 {
+		return query, fmt.Errorf("q must have a word of at least %d characters", MinTermLength)
+	}
+	for _, t := range types {
+		if !known(t) {
+			return query, fmt.Errorf("type %q is not one of %s", t, strings.Join(Types, ", "))
+		}
+		query.Types = append(query.Types, t)
+	}
+	return query, nil
+}
+
+func known(t string) bool {
+	for _, known := range Types {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// wants tells whether results of type t are searched
+func (q Query) wants(t string) bool {
+	if len(q.Types) == 0 {
+		return true
+	}
+	for _, want := range q.Types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Searcher finds the resources matching a query, best first
+type Searcher interface {
+	Search(ctx context.Context, q Query, limit, offset int) (Page, error)
+}
+
+// New returns the search of the database: full-text and trigram indexes on
+// Postgres, FTS5 tables on SQLite, see config.Config.DBDriver. Both are
+// created by the migrations.
+func New(db *gorm.DB) Searcher {
+	if db.Dialector.Name() == "sqlite" {
+		return &SQLite{db: db}
+	}
+	return &Postgres{db: db}
+}
+
+// source is a searched column, or expression, of a table
+type source struct {
+	typ   string
+	field string
+	query func(q Query, args *[]any) string // a SELECT of resource, id, field, matched, score
+}
+
+// fieldPhone is the field of the sources of phone numbers, they search a
+// query that is a number as its E.164 form, e.g. "0917 123 4567" as
+// "+639171234567"
+const fieldPhone = "phone"
+
+// run ranks the matches of the sources, keeping the best match of each
+// resource, and returns a page of them
+func run(ctx context.Context, db *gorm.DB, q Query, sources []source, limit, offset int) (Page, error) {
+	var selects []string
+	var args []any
+	for _, s := range sources {
+		if !q.wants(s.typ) {
+			continue
+		}
+		if s.field == fieldPhone && q.Phone != "" {
+			selects = append(selects, s.query(Query{Text: q.Phone, Terms: []string{q.Phone}}, &args))
+			continue
+		}
+		selects = append(selects, s.query(q, &args))
+	}
+
+	sql := `SELECT resource, id, field, matched, score, COUNT(*) OVER () AS total FROM (
+	SELECT resource, id, field, matched, score,
+		ROW_NUMBER() OVER (PARTITION BY resource, id ORDER BY score DESC) AS n
+	FROM (` + strings.Join(selects, "\n\tUNION ALL\n\t") + `) matches
+) best
+WHERE n = 1
+ORDER BY score DESC, resource, id
+LIMIT ? OFFSET ?`
+	args = append(args, limit, offset)
+
+	var rows []struct {
+		Result
+		Total int64 `gorm:"column:total"`
+	}
+	if err := db.WithContext(ctx).Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return Page{}, err
+	}
+
+	page := Page{Results: make([]Result, len(rows))}
+	for i, row := range rows {
+		page.Results[i] = row.Result
+		page.Total = row.Total
+	}
+	return page, nil
+}
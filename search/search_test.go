@@ -0,0 +1,80 @@
+package search_test
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	customermodel "sample/customer/model"
+	merchantmodel "sample/merchant/model"
+	"sample/servertest"
+)
+
+func TestSearch(t *testing.T) {
+	s := servertest.New(t)
+	ctx := context.Background()
+	customer := &customermodel.Customer{
+		FullName: "Juan", LastName: "Dela Cruz", DateOfBirth: time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC),
+		Contacts: []customermodel.Contact{{OwnerPhoneNumber: "0917 123 4567", Email: "juan@example.com"}},
+	}
+	if err := s.Customers.Create(ctx, customer); err != nil {
+		t.Fatal(err)
+	}
+	merchant := &merchantmodel.Merchant{CustomerID: int(customer.ID), Name: "Panaderia ni Juan", Product: []merchantmodel.Product{
+		{Name: "Pandesal", Quantity: 10, DeliverDate: time.Now()},
+	}}
+	if err := s.Merchants.Create(ctx, merchant); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		q, types string
+		want     []string // type and field of the results, best first
+	}{
+		{"juan", "", []string{"customer name", "merchant name"}},
+		{"juan", "merchant", []string{"merchant name"}},
+		{"panaderia", "", []string{"merchant name"}},
+		{"andes", "product", []string{"product name"}},
+		{"example.com", "", []string{"customer email"}},
+		// The numbers are stored in E.164, the way they are typed finds them
+		{"0917 123 4567", "", []string{"customer phone"}},
+		{"+63 917 123 4567", "", []string{"customer phone"}},
+		{"1234567", "", []string{"customer phone"}},
+	}
+	for _, tt := range tests {
+		path := "/api/search?q=" + url.QueryEscape(tt.q) + "&type=" + tt.types
+		status, body := servertest.Do(t, s.App, "GET", path, nil)
+		if status != 200 {
+			t.Errorf("%s: %d %v, want 200", path, status, body)
+			continue
+		}
+		page := body["data"].(map[string]any)
+		results := page["results"].([]any)
+		var got []string
+		for _, r := range results {
+			result := r.(map[string]any)
+			got = append(got, result["type"].(string)+" "+result["field"].(string))
+		}
+		if len(got) != len(tt.want) || page["total"].(float64) != float64(len(tt.want)) {
+			t.Errorf("%s: results %v, want %v", path, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: results %v, want %v", path, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if status, _ := servertest.Do(t, s.App, "GET", "/api/search?q=ramen", nil); status != 404 {
+		t.Errorf("search without match = %d, want 404", status)
+	}
+	if status, _ := servertest.Do(t, s.App, "GET", "/api/search?q=ju", nil); status != 400 {
+		t.Errorf("search of a short word = %d, want 400", status)
+	}
+	if status, _ := servertest.Do(t, s.App, "GET", "/api/search?q=juan&type=shop", nil); status != 400 {
+		t.Errorf("search of an unknown type = %d, want 400", status)
+	}
+}
@@ -0,0 +1,51 @@
+package search
+
+import (
+	"context"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SQLite matches the words of a query anywhere in a column through the FTS5
+// tables of migration 0004_search, tokenized in trigrams and kept in sync
+// with their tables by triggers. The bm25 of different tables cannot be
+// compared, so a match ranks by whether a word starts with the query and how
+// much of the text the query covers.
+type SQLite struct {
+	db *gorm.DB
+}
+
+var sqliteSources = []source{
+	sqliteSource(TypeCustomer, "name", "customers_fts", "customers", "t.id", "full_name last_name", "t.full_name || ' ' || t.last_name"),
+	sqliteSource(TypeCustomer, "email", "contacts_fts", "contacts", "t.customer_id", "email", "t.email"),
+	sqliteSource(TypeCustomer, fieldPhone, "contacts_fts", "contacts", "t.customer_id", "owner_phone_number", "t.owner_phone_number"),
+	sqliteSource(TypeCustomer, fieldPhone, "contacts_fts", "contacts", "t.customer_id", "owner_other_phone_number", "t.owner_other_phone_number"),
+	sqliteSource(TypeMerchant, "name", "merchants_fts", "merchants", "t.id", "name", "t.name"),
+	sqliteSource(TypeProduct, "name", "products_fts", "products", "t.id", "name", "t.name"),
+}
+
+// sqliteSource searches the columns of the FTS table fts over table t, for
+// the resource of type typ whose ID is id
+func sqliteSource(typ, field, fts, table, id, columns, text string) source {
+	return source{typ: typ, field: field, query: func(q Query, args *[]any) string {
+		*args = append(*args, " "+q.Text, q.Text, "{"+columns+"} : ("+phrases(q.Terms)+")")
+		return `SELECT '` + typ + `' AS resource, ` + id + ` AS id, '` + field + `' AS field, ` + text + ` AS matched,
+		(instr(lower(' ' || ` + text + `), lower(?)) > 0) + length(?) * 1.0 / max(length(` + text + `), 1) AS score
+	FROM ` + fts + ` JOIN ` + table + ` t ON t.id = ` + fts + `.rowid
+	WHERE ` + fts + ` MATCH ?`
+	}}
+}
+
+func (s *SQLite) Search(ctx context.Context, q Query, limit, offset int) (Page, error) {
+	return run(ctx, s.db, q, sqliteSources, limit, offset)
+}
+
+// phrases is the FTS5 query matching every term, each as a quoted string
+func phrases(terms []string) string {
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, " AND ")
+}
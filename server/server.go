@@ -0,0 +1,438 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"sample/audit"
+	"sample/config"
+	customercontroller "sample/customer/controller"
+	customermodel "sample/customer/model"
+	customerrepository "sample/customer/repository"
+	customerservice "sample/customer/service"
+	"sample/database"
+	"sample/geo"
+	"sample/health"
+	"sample/logger"
+	merchantmodel "sample/merchant/model"
+	merchantrepository "sample/merchant/repository"
+	merchantservice "sample/merchant/service"
+	"sample/metrics"
+	"sample/middleware"
+	"sample/migrations"
+	"sample/repository"
+	"sample/routes"
+	"sample/script"
+	"sample/search"
+	"sample/storage"
+	"sample/tracing"
+
+	"github.com/gofiber/fiber/v3"
+	"gorm.io/gorm"
+)
+
+// Server wires the configuration, logger, database and routes of the API in
+// one place. Build it with New, then Run it or Start and Shutdown it.
+type Server struct {
+	Config  config.Config
+	Log     *slog.Logger
+	DB      *gorm.DB
+	Clock   func() time.Time
+	Metrics *metrics.Metrics
+	Health  *health.Health
+	Limiter *middleware.RateLimiter
+	App     *fiber.App
+
+	CustomerRepository customerrepository.CustomerRepository
+	MerchantRepository merchantrepository.MerchantRepository
+	ProductRepository  merchantrepository.ProductRepository
+	Tx                 repository.Transactor
+	DocumentRepository customerrepository.DocumentRepository
+	AuditLog           *audit.Log
+	Searcher           search.Searcher
+	Storage            storage.Storage
+
+	Customers *customerservice.Service
+	Merchants *merchantservice.MerchantService
+	Products  *merchantservice.ProductService
+	Documents *customerservice.Documents
+	Downloads customercontroller.Downloads
+
+	ownsDB          bool
+	withoutJobs     bool
+	shutdownTracing func(context.Context) error
+	stopBackground  context.CancelFunc
+	stopRequests    context.CancelFunc
+	listener        net.Listener
+	serveErr        chan error
+}
+
+// Option replaces one of the dependencies New would otherwise build
+type Option func(*Server)
+
+// WithConfig uses cfg instead of config.Load()
+func WithConfig(cfg config.Config) Option {
+	return func(s *Server) { s.Config = cfg }
+}
+
+// WithLogger uses log instead of a JSON logger at the configured level
+func WithLogger(log *slog.Logger) Option {
+	return func(s *Server) { s.Log = log }
+}
+
+// WithDB uses db instead of connecting with InitDB. The caller keeps
+// ownership, Shutdown does not close it.
+func WithDB(db *gorm.DB) Option {
+	return func(s *Server) { s.DB = db }
+}
+
+// WithClock replaces time.Now for the rate limiter buckets, the audit stamps
+// and log, the identification expiry, the deliveries and the download URLs
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) { s.Clock = now }
+}
+
+// WithoutJobs leaves out the background jobs, the recount of the metrics and
+// the identification expiry, for the one-off commands and the tests
+func WithoutJobs() Option {
+	return func(s *Server) { s.withoutJobs = true }
+}
+
+// WithRateLimitStore uses store for the rate limiter buckets instead of memory
+func WithRateLimitStore(store middleware.RateLimitStore) Option {
+	return func(s *Server) { s.Limiter = middleware.NewRateLimiter(store) }
+}
+
+// WithCustomerRepository replaces the GORM customer repository, e.g. with customerrepository.NewMemory(merchants)
+func WithCustomerRepository(repo customerrepository.CustomerRepository) Option {
+	return func(s *Server) { s.CustomerRepository = repo }
+}
+
+// WithMerchantRepository replaces the GORM merchant repository
+func WithMerchantRepository(repo merchantrepository.MerchantRepository) Option {
+	return func(s *Server) { s.MerchantRepository = repo }
+}
+
+// WithProductRepository replaces the GORM product repository
+func WithProductRepository(repo merchantrepository.ProductRepository) Option {
+	return func(s *Server) { s.ProductRepository = repo }
+}
+
+// WithTransactor replaces the GORM transactions of the writes, e.g. with
+// repository.NopTransactor{} next to the memory repositories
+func WithTransactor(tx repository.Transactor) Option {
+	return func(s *Server) { s.Tx = tx }
+}
+
+// WithAuditLog replaces the audit log stored in the database
+func WithAuditLog(log *audit.Log) Option {
+	return func(s *Server) { s.AuditLog = log }
+}
+
+// WithDocumentRepository replaces the GORM document repository
+func WithDocumentRepository(repo customerrepository.DocumentRepository) Option {
+	return func(s *Server) { s.DocumentRepository = repo }
+}
+
+// WithStorage replaces the local directory the documents are stored in
+func WithStorage(store storage.Storage) Option {
+	return func(s *Server) { s.Storage = store }
+}
+
+// WithSearcher replaces the search of the database
+func WithSearcher(searcher search.Searcher) Option {
+	return func(s *Server) { s.Searcher = searcher }
+}
+
+// New builds the server. ctx bounds the start-up work: connecting to the
+// database and applying migrations.
+func New(ctx context.Context, opts ...Option) (*Server, error) {
+	s := &Server{Config: config.Load()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.Log == nil {
+		s.Log = logger.New(s.Config.LogLevel)
+	}
+	if s.Clock == nil {
+		s.Clock = time.Now
+	}
+	if s.Limiter == nil {
+		store := middleware.NewMemoryStore()
+		store.Now = s.Clock
+		s.Limiter = middleware.NewRateLimiter(store)
+	}
+
+	var err error
+	s.shutdownTracing, err = tracing.Init(ctx, s.Config.TracingExporter, s.Config.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.DB == nil {
+		s.DB, err = database.InitDB(ctx, s.Config, s.Log)
+		if err != nil {
+			return nil, errors.Join(err, s.shutdownTracing(ctx))
+		}
+		s.ownsDB = true
+	} else {
+		// Injected connections still get the SQL logging and query counting
+		s.DB = s.DB.Session(&gorm.Session{NewDB: true, Logger: database.NewGormLogger(s.Log, s.Config.SlowQueryThreshold)})
+	}
+	// The stamps and the migrations take their time from db.NowFunc
+	s.DB = s.DB.Session(&gorm.Session{NewDB: true, NowFunc: s.Clock})
+
+	if err := s.setup(ctx); err != nil {
+		return nil, errors.Join(err, s.release(ctx))
+	}
+	return s, nil
+}
+
+func (s *Server) setup(ctx context.Context) error {
+	cfg := s.Config
+
+	if cfg.GeoDataset != "" {
+		directory, err := geo.LoadFile(cfg.GeoDataset)
+		if err != nil {
+			return err
+		}
+		geo.SetDefault(directory)
+	}
+
+	s.Metrics = metrics.New()
+	if err := s.Metrics.InstrumentDB(s.DB, cfg.DBName); err != nil {
+		return err
+	}
+	if err := tracing.InstrumentDB(s.DB); err != nil {
+		return err
+	}
+	if err := audit.InstrumentDB(s.DB); err != nil {
+		return err
+	}
+	if err := merchantrepository.InstrumentDB(s.DB); err != nil {
+		return err
+	}
+
+	if cfg.AutoMigrate {
+		if err := database.Migrate(ctx, s.DB, s.Log, migrations.All); err != nil {
+			return err
+		}
+	}
+
+	var background context.Context
+	background, s.stopBackground = context.WithCancel(context.Background())
+	if !s.withoutJobs {
+		s.Metrics.WatchCounts(background, s.DB, s.Log, cfg.MetricsRefresh, map[string]any{
+			"customer": &customermodel.Customer{},
+			"merchant": &merchantmodel.Merchant{},
+			"product":  &merchantmodel.Product{},
+		})
+	}
+
+	// Repositories default to GORM, services hold the business rules on top
+	if s.CustomerRepository == nil {
+		s.CustomerRepository = customerrepository.NewGorm(s.DB)
+	}
+	if s.MerchantRepository == nil {
+		s.MerchantRepository = merchantrepository.NewGormMerchants(s.DB)
+	}
+	if s.ProductRepository == nil {
+		s.ProductRepository = merchantrepository.NewGormProducts(s.DB)
+	}
+	if s.Tx == nil {
+		s.Tx = repository.NewGormTransactor(s.DB)
+	}
+	if s.AuditLog == nil {
+		s.AuditLog = audit.NewLog(repository.NewGorm[audit.Entry](s.DB), s.Clock)
+	}
+	if s.Searcher == nil {
+		s.Searcher = search.New(s.DB)
+	}
+	if s.DocumentRepository == nil {
+		s.DocumentRepository = customerrepository.NewGormDocuments(s.DB)
+	}
+	if s.Storage == nil {
+		local, err := storage.NewLocal(cfg.DocumentDir)
+		if err != nil {
+			return err
+		}
+		s.Storage = local
+	}
+	signingKey := []byte(cfg.DocumentSigningKey)
+	if len(signingKey) == 0 {
+		signingKey = make([]byte, 32)
+		if _, err := rand.Read(signingKey); err != nil {
+			return err
+		}
+		s.Log.WarnContext(ctx, "DOCUMENT_SIGNING_KEY is not set, the download URLs break on restart and across replicas")
+	}
+	s.Customers = customerservice.New(s.CustomerRepository, s.MerchantRepository, s.Clock)
+	if !s.withoutJobs {
+		s.Customers.WatchExpiry(background, s.Log, cfg.IDExpiryInterval)
+	}
+	s.Merchants = merchantservice.NewMerchantService(s.MerchantRepository, s.CustomerRepository, s.Clock)
+	s.Products = merchantservice.NewProductService(s.ProductRepository, s.MerchantRepository, s.Clock)
+	s.Documents = customerservice.NewDocuments(s.DocumentRepository, s.Customers, s.Storage, int64(cfg.DocumentMaxSize))
+	s.Downloads = customercontroller.Downloads{Signer: storage.NewSigner(signingKey, s.Clock), TTL: cfg.DocumentURLTTL}
+
+	// The queries of the requests still in flight when the shutdown deadline
+	// passes are cancelled, see Shutdown
+	var requests context.Context
+	requests, s.stopRequests = context.WithCancel(context.Background())
+
+	// Idle keep-alive connections are not closed by Shutdown, so they must
+	// time out on their own
+	s.App = fiber.New(fiber.Config{
+		IdleTimeout: cfg.ShutdownTimeout,
+		BodyLimit:   cfg.BodyLimit,
+		// c.IP(), which the rate limits fall back to, reads ProxyHeader
+		// only on the connections of the trusted proxies
+		ProxyHeader:             cfg.ProxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
+	})
+
+	// Tag every request with an ID and a span, log it and count it once it is done
+	s.App.Use(
+		middleware.RequestID(),
+		middleware.Actor(cfg.ActorHeader),
+		tracing.Middleware(),
+		middleware.AccessLog(s.Log, cfg.QueryCountWarn),
+		s.Metrics.Middleware(),
+		script.Timeout(cfg.StatementTimeout),
+		script.Shutdown(requests),
+	)
+	s.App.Get("/metrics", s.Metrics.Handler())
+
+	// Probes for the orchestrator, readiness fails as soon as shutdown starts
+	s.Health = health.New(2 * time.Second)
+	s.Health.Add("database", health.DatabaseCheck(s.DB))
+	s.Health.Add("migrations", health.MigrationsCheck(s.DB, migrations.All))
+	s.App.Get("/healthz", s.Health.Liveness())
+	s.App.Get("/readyz", s.Health.Readiness())
+
+	routes.SetupRoutes(s.App, s.dependencies())
+	return nil
+}
+
+func (s *Server) dependencies() routes.Dependencies {
+	return routes.Dependencies{
+		Log:          s.Log,
+		Limiter:      s.Limiter,
+		Customers:    s.Customers,
+		Merchants:    s.Merchants,
+		Products:     s.Products,
+		Tx:           s.Tx,
+		Audit:        s.AuditLog,
+		AuditReaders: s.Config.AuditReaders,
+		Search:       s.Searcher,
+		Documents:    s.Documents,
+		Downloads:    s.Downloads,
+	}
+}
+
+// Importers are the resources the import command can fill, see routes.Importers
+func (s *Server) Importers() map[string]script.Importer {
+	return routes.Importers(s.dependencies())
+}
+
+// Start listens on addr, ":0" picks a free port, and serves in the background
+func (s *Server) Start(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+	s.serveErr = make(chan error, 1)
+
+	go func() {
+		s.serveErr <- s.App.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true})
+	}()
+	s.Log.Info("listening", "addr", s.Addr())
+	return nil
+}
+
+// Addr returns the address the server listens on, once started
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return ""
+	}
+	return s.listener.Addr().String()
+}
+
+// Run starts the server on addr and blocks until ctx is done, then shuts it
+// down within Config.DrainDelay and Config.ShutdownTimeout
+func (s *Server) Run(ctx context.Context, addr string) error {
+	if err := s.Start(addr); err != nil {
+		return errors.Join(err, s.release(context.Background()))
+	}
+
+	select {
+	case err := <-s.serveErr:
+		return errors.Join(err, s.release(context.Background()))
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.Config.DrainDelay+s.Config.ShutdownTimeout)
+	defer cancel()
+	return s.Shutdown(shutdownCtx)
+}
+
+// Shutdown fails readiness, keeps serving for Config.DrainDelay so that the
+// load balancers see it, stops accepting connections, waits for in-flight
+// requests until ctx is done and then releases the database and tracing
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.Log.InfoContext(ctx, "shutting down")
+	s.Health.Drain()
+	if s.listener != nil && s.Config.DrainDelay > 0 {
+		select {
+		case <-time.After(s.Config.DrainDelay):
+		case <-ctx.Done():
+		}
+	}
+
+	// Past the deadline, the queries still running are cancelled and their
+	// requests answered 503
+	stop := context.AfterFunc(ctx, s.stopRequests)
+	defer stop()
+
+	var errs []error
+	if s.listener != nil {
+		if err := s.App.ShutdownWithContext(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, s.release(ctx))
+	return errors.Join(errs...)
+}
+
+// release stops the background work, closes the database if New opened it
+// and flushes the traces
+func (s *Server) release(ctx context.Context) error {
+	if s.stopBackground != nil {
+		s.stopBackground()
+	}
+	if s.stopRequests != nil {
+		s.stopRequests()
+	}
+
+	var errs []error
+	if s.ownsDB {
+		if err := database.Close(s.DB); err != nil {
+			errs = append(errs, err)
+		} else {
+			s.Log.InfoContext(ctx, "database closed")
+		}
+	}
+	if s.shutdownTracing != nil {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		errs = append(errs, s.shutdownTracing(flushCtx))
+	}
+	return errors.Join(errs...)
+}
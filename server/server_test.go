@@ -0,0 +1,95 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"sample/server"
+	"sample/servertest"
+)
+
+func TestStartAndShutdown(t *testing.T) {
+	ctx := context.Background()
+	s, err := server.New(ctx,
+		server.WithConfig(servertest.Config(t)),
+		server.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		server.WithoutJobs(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + s.Addr()
+
+	for _, probe := range []string{"/healthz", "/readyz"} {
+		resp, err := http.Get(url + probe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", probe, resp.StatusCode)
+		}
+	}
+
+	if err := s.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	client := http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	if resp, err := client.Get(url + "/healthz"); err == nil {
+		resp.Body.Close()
+		t.Errorf("GET /healthz after Shutdown = %d, want the connection refused", resp.StatusCode)
+	}
+	if err := s.DB.Exec("SELECT 1").Error; err == nil {
+		t.Error("the database is still open after Shutdown")
+	}
+}
+
+func TestShutdownFailsReadinessWhileDraining(t *testing.T) {
+	ctx := context.Background()
+	cfg := servertest.Config(t)
+	cfg.DrainDelay = 500 * time.Millisecond
+	s, err := server.New(ctx,
+		server.WithConfig(cfg),
+		server.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		server.WithoutJobs(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Start("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + s.Addr()
+
+	done := make(chan error, 1)
+	go func() { done <- s.Shutdown(ctx) }()
+	// Drain runs first, the server still answers until the delay is over
+	deadline := time.Now().Add(cfg.DrainDelay)
+	draining := false
+	for !draining && time.Now().Before(deadline) {
+		resp, err := http.Get(url + "/readyz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		draining = resp.StatusCode == http.StatusServiceUnavailable
+	}
+	if !draining {
+		t.Error("GET /readyz kept answering 200 while draining, want 503")
+	}
+	if resp, err := http.Get(url + "/healthz"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /healthz while draining = %v, want 200", err)
+	} else {
+		resp.Body.Close()
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
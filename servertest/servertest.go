@@ -0,0 +1,97 @@
+// Package servertest builds a Server on a SQLite database of its own, for
+// the tests of the endpoints and of what needs the real database
+package servertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sample/config"
+	"sample/server"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Config is the configuration of a test server: a migrated SQLite database
+// and the documents in a temporary directory of t, the actor in X-Actor,
+// "auditor" reading the audit log and no drain delay
+func Config(t testing.TB) config.Config {
+	dir := t.TempDir()
+	cfg := config.Load()
+	cfg.DBDriver = "sqlite"
+	cfg.DBName = filepath.Join(dir, "sample.db")
+	cfg.AutoMigrate = true
+	cfg.DrainDelay = 0
+	cfg.ShutdownTimeout = 5 * time.Second
+	cfg.TracingExporter = "none"
+	cfg.ActorHeader = "X-Actor"
+	cfg.AuditReaders = []string{"auditor"}
+	cfg.GeoDataset = ""
+	cfg.DocumentDir = filepath.Join(dir, "documents")
+	cfg.DocumentSigningKey = "test"
+	return cfg
+}
+
+// New builds a Server with Config and without the background jobs, shut
+// down when t ends. opts apply after them.
+func New(t testing.TB, opts ...server.Option) *server.Server {
+	t.Helper()
+	opts = append([]server.Option{
+		server.WithConfig(Config(t)),
+		server.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		server.WithoutJobs(),
+	}, opts...)
+	s, err := server.New(context.Background(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := s.Shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+// Do sends a request with a JSON body, unless body is nil, and headers as
+// name and value pairs, and returns the status and the JSON response
+func Do(t testing.TB, app *fiber.App, method, path string, body any, headers ...string) (int, map[string]any) {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := app.Test(req, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if len(raw) > 0 && json.Unmarshal(raw, &doc) != nil {
+		t.Fatalf("%s %s: %d %s is not a JSON object", method, path, resp.StatusCode, raw)
+	}
+	return resp.StatusCode, doc
+}
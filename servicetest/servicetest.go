@@ -0,0 +1,84 @@
+// Package servicetest wires the customer and merchant services to the memory
+// repositories for their tests, with a clock the tests move
+package servicetest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"sample/custom"
+	customermodel "sample/customer/model"
+	customerrepository "sample/customer/repository"
+	customerservice "sample/customer/service"
+	merchantmodel "sample/merchant/model"
+	merchantrepository "sample/merchant/repository"
+	merchantservice "sample/merchant/service"
+)
+
+// Fixture holds the services and the repositories under them, every
+// service reads the time from Now
+type Fixture struct {
+	Now time.Time
+
+	Customers *customerservice.Service
+	Merchants *merchantservice.MerchantService
+	Products  *merchantservice.ProductService
+
+	CustomerRepo customerrepository.CustomerRepository
+	MerchantRepo merchantrepository.MerchantRepository
+	ProductRepo  merchantrepository.ProductRepository
+}
+
+// New creates a Fixture with empty repositories at 2024-05-01 10:00 UTC
+func New() *Fixture {
+	f := &Fixture{Now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	f.ProductRepo = merchantrepository.NewMemoryProducts()
+	f.MerchantRepo = merchantrepository.NewMemoryMerchants(f.ProductRepo)
+	f.CustomerRepo = customerrepository.NewMemory(f.MerchantRepo)
+
+	f.Customers = customerservice.New(f.CustomerRepo, f.MerchantRepo, f.Clock)
+	f.Merchants = merchantservice.NewMerchantService(f.MerchantRepo, f.CustomerRepo, f.Clock)
+	f.Products = merchantservice.NewProductService(f.ProductRepo, f.MerchantRepo, f.Clock)
+	return f
+}
+
+// Clock returns Now, it is the clock of the services
+func (f *Fixture) Clock() time.Time {
+	return f.Now
+}
+
+// Customer returns a valid customer named name, not stored
+func (f *Fixture) Customer(name string) *customermodel.Customer {
+	return &customermodel.Customer{FullName: name, LastName: "Dela Cruz", DateOfBirth: f.Now.AddDate(-30, 0, 0)}
+}
+
+// CreateCustomer stores customer through the service, or fails t
+func (f *Fixture) CreateCustomer(t testing.TB, customer *customermodel.Customer) *customermodel.Customer {
+	t.Helper()
+	if err := f.Customers.Create(context.Background(), customer); err != nil {
+		t.Fatal(err)
+	}
+	return customer
+}
+
+// CreateMerchant stores a merchant of customerID with products through the
+// service, or fails t
+func (f *Fixture) CreateMerchant(t testing.TB, customerID uint, products ...merchantmodel.Product) *merchantmodel.Merchant {
+	t.Helper()
+	merchant := &merchantmodel.Merchant{CustomerID: int(customerID), Name: "Sari", Product: products}
+	if err := f.Merchants.Create(context.Background(), merchant); err != nil {
+		t.Fatal(err)
+	}
+	return merchant
+}
+
+// ValidationField returns the field of a custom.ValidationError in err, or ""
+func ValidationField(err error) string {
+	var validationErr *custom.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Field
+	}
+	return ""
+}
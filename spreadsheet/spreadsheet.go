@@ -0,0 +1,150 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Format is a file format of the import and export endpoints
+type Format string
+
+const (
+	CSV    Format = "csv"
+	XLSX   Format = "xlsx"
+	NDJSON Format = "ndjson" // one JSON object per line, written only
+)
+
+// ParseFormat accepts csv, xlsx and ndjson, in any case
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case CSV, XLSX, NDJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("%q is not csv, xlsx or ndjson", name)
+}
+
+// ContentType is the media type of a file of the format
+func (f Format) ContentType() string {
+	switch f {
+	case CSV:
+		return "text/csv; charset=utf-8"
+	case XLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case NDJSON:
+		return "application/x-ndjson"
+	}
+	return "application/octet-stream"
+}
+
+// FormatOf picks the format from the extension of a file name
+func FormatOf(filename string) (Format, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(filename), "."))
+}
+
+// Reader reads the rows of a file, the header first. Next returns io.EOF
+// after the last row. Rows can be shorter than the header.
+type Reader interface {
+	Next() ([]string, error)
+	Close() error
+}
+
+// NewReader reads a CSV file as it streams in, or the first sheet of an XLSX
+// file, which needs the whole file in memory
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r), nil
+	case XLSX:
+		return newXLSXReader(r)
+	}
+	return nil, fmt.Errorf("cannot read %s files", format)
+}
+
+type csvReader struct {
+	r *csv.Reader
+}
+
+// utf8BOM starts the CSV files Excel saves as UTF-8
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+func newCSVReader(r io.Reader) *csvReader {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	return &csvReader{r: cr}
+}
+
+func (r *csvReader) Next() ([]string, error) {
+	return r.r.Read()
+}
+
+func (r *csvReader) Close() error {
+	return nil
+}
+
+type xlsxReader struct {
+	file *excelize.File
+	rows *excelize.Rows
+}
+
+func newXLSXReader(r io.Reader) (*xlsxReader, error) {
+	file, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not an XLSX file: %w", err)
+	}
+	sheets := file.GetSheetList()
+	if len(sheets) == 0 {
+		file.Close()
+		return nil, errors.New("the XLSX file has no sheet")
+	}
+	rows, err := file.Rows(sheets[0])
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxReader{file: file, rows: rows}, nil
+}
+
+// Next returns the cells as stored, not as displayed: dates are serial
+// numbers, see ParseTime
+func (r *xlsxReader) Next() ([]string, error) {
+	if !r.rows.Next() {
+		if err := r.rows.Error(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	return r.rows.Columns(excelize.Options{RawCellValue: true})
+}
+
+func (r *xlsxReader) Close() error {
+	return errors.Join(r.rows.Close(), r.file.Close())
+}
+
+// ParseTime reads a time cell: RFC 3339, a date like 2006-01-02, or the
+// serial number of a date in an XLSX file
+func ParseTime(raw string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, nil
+		}
+	}
+	if serial, err := strconv.ParseFloat(raw, 64); err == nil && serial > 0 {
+		return excelize.ExcelDateToTime(serial, false)
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC 3339 time or a date", raw)
+}
@@ -0,0 +1,151 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Writer writes the rows of a file after the header given to NewWriter. A
+// nil value is an empty cell, times are written as RFC 3339, which
+// ParseTime reads back.
+type Writer interface {
+	Write(row []any) error
+	// Close flushes the file, it does not close the underlying io.Writer
+	Close() error
+}
+
+// NewWriter writes a file with header as its first row, or its keys for
+// NDJSON. CSV and NDJSON rows reach w as they are written, XLSX files are
+// kept in a temporary file until Close.
+func NewWriter(w io.Writer, format Format, header []string) (Writer, error) {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		return &csvWriter{w: cw}, cw.Write(header)
+	case NDJSON:
+		return newNDJSONWriter(w, header)
+	case XLSX:
+		return newXLSXWriter(w, header)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (w *csvWriter) Write(row []any) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+		case time.Time:
+			record[i] = v.Format(time.RFC3339Nano)
+		default:
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type ndjsonWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func newNDJSONWriter(w io.Writer, header []string) (*ndjsonWriter, error) {
+	keys := make([][]byte, len(header))
+	for i, name := range header {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = key
+	}
+	return &ndjsonWriter{w: bufio.NewWriter(w), keys: keys}, nil
+}
+
+// Write writes the row as an object with the keys in header order
+func (w *ndjsonWriter) Write(row []any) error {
+	w.w.WriteByte('{')
+	for i, v := range row {
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		w.w.Write(w.keys[i])
+		w.w.WriteByte(':')
+		w.w.Write(value)
+	}
+	w.w.WriteString("}\n")
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.w.Flush()
+}
+
+type xlsxWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer, header []string) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	x := &xlsxWriter{w: w, file: file, stream: stream}
+	cells := make([]any, len(header))
+	for i, name := range header {
+		cells[i] = name
+	}
+	if err := x.Write(cells); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return x, nil
+}
+
+func (w *xlsxWriter) Write(row []any) error {
+	w.row++
+	cells := make([]any, len(row))
+	for i, v := range row {
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(time.RFC3339Nano)
+		}
+		cells[i] = v
+	}
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	return w.stream.SetRow(cell, cells)
+}
+
+func (w *xlsxWriter) Close() error {
+	defer w.file.Close()
+	if err := w.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteTo(w.w)
+	return err
+}
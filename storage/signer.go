@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Errors of Signer.Verify
+var (
+	ErrInvalidSignature = errors.New("the signature does not match the URL")
+	ErrExpired          = errors.New("the URL has expired")
+)
+
+// Signer signs the paths of downloads for a limited time, so that a URL
+// handed to a client works without further authentication until it expires
+type Signer struct {
+	key []byte
+	now func() time.Time
+}
+
+// NewSigner creates a Signer with the secret key, now is its clock
+func NewSigner(key []byte, now func() time.Time) *Signer {
+	return &Signer{key: key, now: now}
+}
+
+// Sign returns path with the expires and signature query parameters of a
+// URL valid for ttl, and when it expires
+func (s *Signer) Sign(path string, ttl time.Duration) (string, time.Time) {
+	expires := s.now().Add(ttl).Truncate(time.Second)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	query.Set("signature", s.signature(path, expires.Unix()))
+	return path + "?" + query.Encode(), expires
+}
+
+// Verify checks the expires and signature query parameters of path
+func (s *Signer) Verify(path, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(path, unix))) {
+		return ErrInvalidSignature
+	}
+	if !s.now().Before(time.Unix(unix, 0)) {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (s *Signer) signature(path string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	signer := NewSigner([]byte("secret"), func() time.Time { return now })
+
+	const path = "/api/documents/7/content"
+	signed, expires := signer.Sign(path, 15*time.Minute)
+	if want := now.Add(15 * time.Minute); !expires.Equal(want) {
+		t.Fatalf("expires = %v, want %v", expires, want)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != path {
+		t.Fatalf("path = %q, want %q", u.Path, path)
+	}
+	query := u.Query()
+
+	if err := signer.Verify(path, query.Get("expires"), query.Get("signature")); err != nil {
+		t.Fatalf("Verify of the signed URL: %v", err)
+	}
+
+	tampered := []struct {
+		name                     string
+		path, expires, signature string
+	}{
+		{"other path", "/api/documents/8/content", query.Get("expires"), query.Get("signature")},
+		{"later expiry", path, "9999999999", query.Get("signature")},
+		{"bad expiry", path, "soon", query.Get("signature")},
+		{"bad signature", path, query.Get("expires"), strings.Repeat("0", 64)},
+	}
+	for _, tt := range tampered {
+		if err := signer.Verify(tt.path, tt.expires, tt.signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: Verify = %v, want ErrInvalidSignature", tt.name, err)
+		}
+	}
+
+	other := NewSigner([]byte("other secret"), func() time.Time { return now })
+	if err := other.Verify(path, query.Get("expires"), query.Get("signature")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Verify with another key = %v, want ErrInvalidSignature", err)
+	}
+
+	now = expires
+	if err := signer.Verify(path, query.Get("expires"), query.Get("signature")); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify at expiry = %v, want ErrExpired", err)
+	}
+}
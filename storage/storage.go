@@ -0,0 +1,17 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// Storage keeps the uploaded files by key. Keys are slash-separated paths
+// without . or .. elements, e.g. documents/ab/abcdef.
+type Storage interface {
+	// Put stores the content of r under key, replacing what was there
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the content stored under key, or custom.ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes key, which need not exist
+	Delete(ctx context.Context, key string) error
+}
@@ -5,5 +5,5 @@ import "strings"
 // Helper function to detect unique constraint violation
 func IsUniqueConstraintError(err error) bool {
 	// Check if error contains specific keywords indicating a unique constraint violation
-	return err != nil && (strings.Contains(err.Error(), "unique constraint"))
+	return err != nil && (strings.Contains(strings.ToLower(err.Error()), "unique constraint"))
 }
\ No newline at end of file
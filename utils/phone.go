@@ -0,0 +1,86 @@
+package utils
+
+import "strings"
+
+// phoneSeparators are the characters people type between the digits of a phone number
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "", "/", "")
+
+// DefaultCallingCode is the country code of the numbers written without
+// one, the Philippines
+const DefaultCallingCode = "63"
+
+// PhoneType is the kind of line of a phone number
+type PhoneType string
+
+const (
+	PhoneMobile   PhoneType = "mobile"
+	PhoneLandline PhoneType = "landline"
+	PhoneUnknown  PhoneType = "" // a number of another country
+)
+
+// NormalizePhone returns phone in E.164, e.g. +639171234567 for 0917 123
+// 4567, 63-917-123-4567 or 9171234567. Numbers without a country code are
+// Philippine ones. ok is false when phone is not a number of the
+// Philippine numbering plan, or of at most 15 digits after a + or 00.
+func NormalizePhone(phone string) (normalized string, ok bool) {
+	s := phoneSeparators.Replace(strings.TrimSpace(phone))
+	if rest, found := strings.CutPrefix(s, "00"); found {
+		s = "+" + rest
+	}
+	international := strings.HasPrefix(s, "+")
+	digits := strings.TrimPrefix(s, "+")
+	if digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return s, false
+	}
+
+	var national string
+	switch {
+	case international && !strings.HasPrefix(digits, DefaultCallingCode):
+		// Another country, only the length of E.164 is checked
+		if len(digits) < 7 || len(digits) > 15 || digits[0] == '0' {
+			return s, false
+		}
+		return "+" + digits, true
+	case international:
+		national = strings.TrimPrefix(digits, DefaultCallingCode)
+	case strings.HasPrefix(digits, "0"):
+		national = digits[1:]
+	case strings.HasPrefix(digits, DefaultCallingCode) && (len(digits) == 11 || len(digits) == 12):
+		national = strings.TrimPrefix(digits, DefaultCallingCode)
+	default:
+		national = digits
+	}
+	if philippineType(national) == PhoneUnknown {
+		return s, false
+	}
+	return "+" + DefaultCallingCode + national, true
+}
+
+// PhoneTypeOf tells mobile numbers from landlines of a number returned by
+// NormalizePhone. The type of a number of another country is unknown.
+func PhoneTypeOf(normalized string) PhoneType {
+	national, ok := strings.CutPrefix(normalized, "+"+DefaultCallingCode)
+	if !ok {
+		return PhoneUnknown
+	}
+	return philippineType(national)
+}
+
+// philippineType checks a Philippine number without its trunk 0: mobile
+// numbers are 10 digits starting with 9, landlines 9 digits with the area
+// code, e.g. 2 8123 4567 in Metro Manila or 32 123 4567 in Cebu
+func philippineType(national string) PhoneType {
+	switch {
+	case len(national) == 10 && national[0] == '9':
+		return PhoneMobile
+	case len(national) == 9 && national[0] >= '2' && national[0] <= '8':
+		return PhoneLandline
+	}
+	return PhoneUnknown
+}
+
+// NormalizeEmail trims an email address and lowercases it, addresses are
+// unique whatever their case
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,53 @@
+package utils
+
+import "testing"
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+		typ   PhoneType
+	}{
+		{"0917 123 4567", "+639171234567", PhoneMobile},
+		{"63-917-123-4567", "+639171234567", PhoneMobile},
+		{"9171234567", "+639171234567", PhoneMobile},
+		{"+63 917 123 4567", "+639171234567", PhoneMobile},
+		{"0063 917 123 4567", "+639171234567", PhoneMobile},
+		{"(02) 8123-4567", "+63281234567", PhoneLandline},
+		{"032 123 4567", "+63321234567", PhoneLandline},
+		{"+1 415 555 2671", "+14155552671", PhoneUnknown},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizePhone(tt.phone)
+		if !ok || got != tt.want {
+			t.Errorf("NormalizePhone(%q) = %q, %v, want %q, true", tt.phone, got, ok, tt.want)
+			continue
+		}
+		if typ := PhoneTypeOf(got); typ != tt.typ {
+			t.Errorf("PhoneTypeOf(%q) = %q, want %q", got, typ, tt.typ)
+		}
+	}
+}
+
+func TestNormalizePhoneInvalid(t *testing.T) {
+	for _, phone := range []string{
+		"",
+		"call me",
+		"0917 123 456",          // a digit short
+		"0917 123 45678",        // a digit over
+		"0117 123 4567",         // no such area code
+		"+0 415 555 2671",       // country codes do not start with 0
+		"+1 415",                // too short for E.164
+		"+1 415 555 2671 99999", // too long for E.164
+	} {
+		if got, ok := NormalizePhone(phone); ok {
+			t.Errorf("NormalizePhone(%q) = %q, want not ok", phone, got)
+		}
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	if got := NormalizeEmail("  Juan.Dela@Example.COM "); got != "juan.dela@example.com" {
+		t.Errorf("NormalizeEmail = %q", got)
+	}
+}